@@ -0,0 +1,49 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	units "github.com/docker/go-units"
+	"github.com/pkg/errors"
+	"github.com/replicatedhq/kots/pkg/upstream/cache"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+func CacheListCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:           "list",
+		Short:         "List the releases in the cache",
+		Long:          `List the releases in the cache, the most recently used first.`,
+		SilenceUsage:  true,
+		SilenceErrors: false,
+		PreRun: func(cmd *cobra.Command, args []string) {
+			viper.BindPFlags(cmd.Flags())
+			viper.BindPFlags(cmd.InheritedFlags())
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			v := viper.GetViper()
+
+			entries, err := cache.New(ExpandDir(v.GetString("cache-dir")), 0).List()
+			if err != nil {
+				return errors.Wrap(err, "failed to list cache")
+			}
+
+			w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+			fmt.Fprintln(w, "UPSTREAM\tCURSOR\tVERSION\tSIZE\tLAST USED\tDIGEST")
+			for _, entry := range entries {
+				digest := entry.Digest
+				if len(digest) > 12 {
+					digest = digest[:12]
+				}
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", entry.URI, entry.Cursor, entry.VersionLabel, units.BytesSize(float64(entry.Size)), entry.LastUsedAt.Format(time.RFC3339), digest)
+			}
+			return w.Flush()
+		},
+	}
+
+	return cmd
+}
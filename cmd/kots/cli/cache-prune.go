@@ -0,0 +1,56 @@
+package cli
+
+import (
+	units "github.com/docker/go-units"
+	"github.com/pkg/errors"
+	"github.com/replicatedhq/kots/pkg/logger"
+	"github.com/replicatedhq/kots/pkg/upstream/cache"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+func CachePruneCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:           "prune",
+		Short:         "Remove releases from the cache",
+		Long:          `Remove cached releases that are corrupted, and then the least recently used releases until the cache is within the size limit.`,
+		SilenceUsage:  true,
+		SilenceErrors: false,
+		PreRun: func(cmd *cobra.Command, args []string) {
+			viper.BindPFlags(cmd.Flags())
+			viper.BindPFlags(cmd.InheritedFlags())
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			v := viper.GetViper()
+
+			maxSize, err := units.RAMInBytes(v.GetString("max-size"))
+			if err != nil {
+				return errors.Wrap(err, "failed to parse max-size")
+			}
+			if v.GetBool("all") {
+				maxSize = 0
+			}
+
+			removed, err := cache.New(ExpandDir(v.GetString("cache-dir")), 0).Prune(maxSize)
+			if err != nil {
+				return errors.Wrap(err, "failed to prune cache")
+			}
+
+			var freed int64
+			for _, entry := range removed {
+				freed += entry.Size
+			}
+
+			log := logger.NewLogger()
+			log.Initialize()
+			log.ActionWithoutSpinner("Removed %d releases (%s) from the cache", len(removed), units.BytesSize(float64(freed)))
+
+			return nil
+		},
+	}
+
+	cmd.Flags().String("max-size", units.BytesSize(float64(cache.DefaultMaxSize)), "the size to shrink the cache to")
+	cmd.Flags().Bool("all", false, "set to true to remove all releases from the cache")
+
+	return cmd
+}
@@ -0,0 +1,37 @@
+package cli
+
+import (
+	"os"
+
+	"github.com/replicatedhq/kots/pkg/upstream/cache"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+func CacheCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:           "cache",
+		Short:         "Manage the local cache of fetched releases",
+		Long:          ``,
+		SilenceUsage:  true,
+		SilenceErrors: false,
+		PreRun: func(cmd *cobra.Command, args []string) {
+			viper.BindPFlags(cmd.Flags())
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) == 0 {
+				cmd.Help()
+				os.Exit(1)
+			}
+
+			return nil
+		},
+	}
+
+	cmd.PersistentFlags().String("cache-dir", cache.DefaultDir(), "directory that fetched releases are cached in")
+
+	cmd.AddCommand(CacheListCmd())
+	cmd.AddCommand(CachePruneCmd())
+
+	return cmd
+}
@@ -0,0 +1,42 @@
+package cli
+
+import (
+	"os"
+
+	"github.com/pkg/errors"
+	"github.com/replicatedhq/kots/pkg/config"
+	"github.com/replicatedhq/kots/pkg/logger"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+func ConfigRotateKeyCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:           "rotate-key [app dir]",
+		Short:         "Replace the encryption key of an application and re-encrypt its password values",
+		Long:          "Generate a new encryption key for an application that was pulled to the local filesystem, re-encrypt all password config values with it, and save it to upstream/userdata/installation.yaml. Nothing is changed if any password value can't be decrypted with the current key.",
+		SilenceUsage:  true,
+		SilenceErrors: false,
+		PreRun: func(cmd *cobra.Command, args []string) {
+			viper.BindPFlags(cmd.Flags())
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) == 0 {
+				cmd.Help()
+				os.Exit(1)
+			}
+
+			log := logger.NewLogger()
+			log.ActionWithSpinner("Rotating encryption key")
+			if err := config.RotateEncryptionKey(ExpandDir(args[0])); err != nil {
+				log.FinishSpinnerWithError()
+				return errors.Wrap(err, "failed to rotate encryption key")
+			}
+			log.FinishSpinner()
+
+			return nil
+		},
+	}
+
+	return cmd
+}
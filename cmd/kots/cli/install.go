@@ -78,10 +78,26 @@ func InstallCmd() *cobra.Command {
 			kotsadm.OverrideRegistry = v.GetString("kotsadm-registry")
 			kotsadm.OverrideNamespace = v.GetString("kotsadm-namespace")
 
+			kubernetesVersion, apiVersions, err := clusterCapabilities(v, v.GetString("kubeconfig"))
+			if err != nil {
+				log.Info("Unable to discover the cluster capabilities, using defaults: %s", err.Error())
+				kubernetesVersion = v.GetString("kubernetes-version")
+				apiVersions = v.GetStringSlice("api-versions")
+			}
+
+			releaseCache, err := upstreamCache(v)
+			if err != nil {
+				return err
+			}
+
 			pullOptions := pull.PullOptions{
-				HelmRepoURI: v.GetString("repo"),
-				RootDir:     rootDir,
-				Namespace:   namespace,
+				HelmRepoURI:          v.GetString("repo"),
+				HelmRepoOptions:      helmRepoOptions(v),
+				HTTPClientOptions:    httpClientOptions(v),
+				Cache:                releaseCache,
+				SkipReleaseSignature: v.GetBool("skip-release-signature"),
+				RootDir:              rootDir,
+				Namespace:            namespace,
 				Downstreams: []string{
 					"this-cluster", // this is the auto-generated operator downstream
 				},
@@ -90,6 +106,10 @@ func InstallCmd() *cobra.Command {
 				ExcludeAdminConsole: true,
 				ExcludeKotsKinds:    true,
 				HelmOptions:         v.GetStringSlice("set"),
+				JsonnetTLAs:         v.GetStringSlice("jsonnet-tla"),
+				JsonnetExtVars:      v.GetStringSlice("jsonnet-ext-var"),
+				KubernetesVersion:   kubernetesVersion,
+				APIVersions:         apiVersions,
 				RewriteImages:       v.GetBool("rewrite-images"),
 				RewriteImageOptions: pull.RewriteImageOptions{
 					Host:      v.GetString("registry-endpoint"),
@@ -109,7 +129,7 @@ func InstallCmd() *cobra.Command {
 			}
 
 			if !v.GetBool("exclude-admin-console") {
-				applicationMetadata, err := pull.PullApplicationMetadata(upstream)
+				applicationMetadata, err := pull.PullApplicationMetadata(upstream, httpClientOptions(v))
 				if err != nil {
 					return errors.Wrap(err, "failed to pull app metadata")
 				}
@@ -241,7 +261,14 @@ func InstallCmd() *cobra.Command {
 	cmd.Flags().MarkHidden("exclude-admin-console")
 
 	cmd.Flags().String("repo", "", "repo uri to use when installing a helm chart")
+	addHelmRepoFlags(cmd)
+	addHTTPClientFlags(cmd)
+	addCacheFlags(cmd)
+	addClusterCapabilitiesFlags(cmd)
+	cmd.Flags().Bool("skip-release-signature", false, "set to true to download releases without verifying their signature against the app public key in the license")
 	cmd.Flags().StringSlice("set", []string{}, "values to pass to helm when running helm template")
+	cmd.Flags().StringSlice("jsonnet-tla", []string{}, "name=value top level arguments to pass to jsonnet")
+	cmd.Flags().StringSlice("jsonnet-ext-var", []string{}, "name=value external variables to pass to jsonnet")
 
 	cmd.Flags().String("kotsadm-tag", "", "set to override the tag of kotsadm. this may create an incompatible deployment because the version of kots and kotsadm are designed to work together")
 	cmd.Flags().String("kotsadm-registry", "", "set to override the registry of kotsadm image. this may create an incompatible deployment because the version of kots and kotsadm are designed to work together")
@@ -6,6 +6,7 @@ import (
 
 	"github.com/replicatedhq/kots/pkg/logger"
 	"github.com/replicatedhq/kots/pkg/pull"
+	"github.com/replicatedhq/kots/pkg/validate"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
@@ -14,7 +15,7 @@ func PullCmd() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:           "pull [upstream uri]",
 		Short:         "Pull Kubernetes manifests from remote upstream to the local filesystem",
-		Long:          `Pull Kubernetes manifests from the remote upstream and save them to the local filesystem, so they can be edited before deploying them to a cluster.`,
+		Long:          `Pull Kubernetes manifests from the remote upstream and save them to the local filesystem, so they can be edited before deploying them to a cluster. The upstream uri can also be the path to a kots.io/v1beta1 Composition file, which lists several upstreams to pull into one application.`,
 		SilenceUsage:  true,
 		SilenceErrors: false,
 		PreRun: func(cmd *cobra.Command, args []string) {
@@ -31,19 +32,45 @@ func PullCmd() *cobra.Command {
 			// registry host should not have the scheme (https).  need to
 			// strip it if included or else the rewrite images will fail
 
+			kubernetesVersion, apiVersions, err := clusterCapabilities(v, ExpandDir(v.GetString("kubeconfig")))
+			if err != nil {
+				return err
+			}
+
+			releaseCache, err := upstreamCache(v)
+			if err != nil {
+				return err
+			}
+
+			cursors, err := componentCursors(v)
+			if err != nil {
+				return err
+			}
+
 			pullOptions := pull.PullOptions{
-				HelmRepoURI:         v.GetString("repo"),
-				RootDir:             ExpandDir(v.GetString("rootdir")),
-				Namespace:           v.GetString("namespace"),
-				Downstreams:         v.GetStringSlice("downstream"),
-				LocalPath:           ExpandDir(v.GetString("local-path")),
-				LicenseFile:         ExpandDir(v.GetString("license-file")),
-				ExcludeKotsKinds:    v.GetBool("exclude-kots-kinds"),
-				ExcludeAdminConsole: v.GetBool("exclude-admin-console"),
-				SharedPassword:      v.GetString("shared-password"),
-				CreateAppDir:        true,
-				HelmOptions:         v.GetStringSlice("set"),
-				RewriteImages:       v.GetBool("rewrite-images"),
+				HelmRepoURI:          v.GetString("repo"),
+				HelmRepoOptions:      helmRepoOptions(v),
+				HTTPClientOptions:    httpClientOptions(v),
+				Cache:                releaseCache,
+				SkipReleaseSignature: v.GetBool("skip-release-signature"),
+				RootDir:              ExpandDir(v.GetString("rootdir")),
+				Namespace:            v.GetString("namespace"),
+				Downstreams:          v.GetStringSlice("downstream"),
+				ComponentCursors:     cursors,
+				LocalPath:            ExpandDir(v.GetString("local-path")),
+				LicenseFile:          ExpandDir(v.GetString("license-file")),
+				ExcludeKotsKinds:     v.GetBool("exclude-kots-kinds"),
+				ExcludeAdminConsole:  v.GetBool("exclude-admin-console"),
+				SharedPassword:       v.GetString("shared-password"),
+				CreateAppDir:         true,
+				HelmOptions:          v.GetStringSlice("set"),
+				JsonnetTLAs:          v.GetStringSlice("jsonnet-tla"),
+				JsonnetExtVars:       v.GetStringSlice("jsonnet-ext-var"),
+				KubernetesVersion:    kubernetesVersion,
+				APIVersions:          apiVersions,
+				Validate:             v.GetBool("validate"),
+				SchemaDir:            ExpandDir(v.GetString("schema-dir")),
+				RewriteImages:        v.GetBool("rewrite-images"),
 				RewriteImageOptions: pull.RewriteImageOptions{
 					Host:      v.GetString("registry-endpoint"),
 					Namespace: v.GetString("image-namespace"),
@@ -72,10 +99,21 @@ func PullCmd() *cobra.Command {
 	}
 
 	cmd.Flags().StringSlice("set", []string{}, "values to pass to helm when running helm template")
+	cmd.Flags().StringSlice("jsonnet-tla", []string{}, "name=value top level arguments to pass to jsonnet")
+	cmd.Flags().StringSlice("jsonnet-ext-var", []string{}, "name=value external variables to pass to jsonnet")
 	cmd.Flags().String("repo", "", "repo uri to use when downloading a helm chart")
+	addHelmRepoFlags(cmd)
+	addHTTPClientFlags(cmd)
+	addCacheFlags(cmd)
+	addClusterCapabilitiesFlags(cmd)
+	cmd.Flags().Bool("validate", false, "set to true to validate the rendered resources against the schemas of the kubernetes version before writing them")
+	cmd.Flags().String("schema-dir", validate.DefaultSchemaDir(), "directory with a <kubernetes version>/swagger.json schema for each kubernetes version to validate against")
+	cmd.Flags().Bool("skip-release-signature", false, "set to true to download releases without verifying their signature against the app public key in the license")
+	cmd.Flags().String("kubeconfig", "", "the kubeconfig of the target cluster, used to discover the kubernetes version and api versions to render for")
 	cmd.Flags().String("rootdir", homeDir(), "root directory that will be used to write the yaml to")
 	cmd.Flags().StringP("namespace", "n", "default", "namespace to render the upstream to in the base")
 	cmd.Flags().StringSlice("downstream", []string{}, "the list of any downstreams to create/update")
+	cmd.Flags().StringSlice("component-cursor", []string{}, "name=cursor of an upstream in a composition to pull at that cursor (defaults to the cursor it was last pulled at)")
 	cmd.Flags().String("local-path", "", "specify a local-path to pull a locally available replicated app (only supported on replicated app types currently)")
 	cmd.Flags().String("license-file", "", "path to a license file to use when download a replicated app")
 	cmd.Flags().Bool("exclude-kots-kinds", true, "set to true to exclude rendering kots custom objects to the base directory")
@@ -0,0 +1,105 @@
+package cli
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/replicatedhq/kots/pkg/base"
+	"github.com/replicatedhq/kots/pkg/downstream"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+func RenderCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:           "render [dir]",
+		Short:         "Render the final Kubernetes manifests for a downstream",
+		Long:          `Build the kustomization of a downstream in an application directory created by kots pull, without a separate kustomize binary, and print the manifests as a single yaml stream or write them to a directory. Namespaces and CustomResourceDefinitions are first, so the output can be applied in order. Hooks are rendered for one event at a time with --hook, or are all written to the output dir.`,
+		SilenceUsage:  true,
+		SilenceErrors: false,
+		PreRun: func(cmd *cobra.Command, args []string) {
+			viper.BindPFlags(cmd.Flags())
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			v := viper.GetViper()
+
+			if len(args) == 0 {
+				cmd.Help()
+				os.Exit(1)
+			}
+
+			renderDir := ExpandDir(args[0])
+			downstreamName := v.GetString("downstream")
+
+			hooks, err := downstream.ListHooks(renderDir, downstreamName)
+			if err != nil {
+				return err
+			}
+
+			outputDir := ExpandDir(v.GetString("output-dir"))
+			if outputDir == "" {
+				var files []base.BaseFile
+				if hook := v.GetString("hook"); hook != "" {
+					files, err = downstream.RenderHook(renderDir, downstreamName, hook)
+				} else {
+					files, err = downstream.Render(renderDir, downstreamName)
+				}
+				if err != nil {
+					return err
+				}
+
+				if _, err := cmd.OutOrStdout().Write(downstream.Manifest(files)); err != nil {
+					return err
+				}
+
+				if v.GetString("hook") == "" && len(hooks) > 0 {
+					fmt.Fprintf(cmd.ErrOrStderr(), "The application has %s hooks that are not included, render them with --hook\n", strings.Join(hooks, ", "))
+				}
+				return nil
+			}
+
+			// all of the hooks are written to the output dir, in a dir for each event
+			files, err := downstream.Render(renderDir, downstreamName)
+			if err != nil {
+				return err
+			}
+			if err := writeRenderedFiles(outputDir, files); err != nil {
+				return err
+			}
+
+			for _, hook := range hooks {
+				files, err := downstream.RenderHook(renderDir, downstreamName, hook)
+				if err != nil {
+					return err
+				}
+				if err := writeRenderedFiles(filepath.Join(outputDir, base.HooksDir, hook), files); err != nil {
+					return err
+				}
+			}
+
+			return nil
+		},
+	}
+
+	cmd.Flags().String("downstream", "", "the downstream to render (defaults to the midstream)")
+	cmd.Flags().String("hook", "", "the hook event, such as pre-install, to render the hooks for instead of the application")
+	cmd.Flags().String("output-dir", "", "directory to write a file for each manifest to, in the order they are applied, with the hooks for each event in hooks/<event> (defaults to printing a single yaml stream)")
+
+	return cmd
+}
+
+func writeRenderedFiles(dir string, files []base.BaseFile) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return errors.Wrap(err, "failed to create output dir")
+	}
+	for _, file := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, file.Path), file.Content, 0644); err != nil {
+			return errors.Wrapf(err, "failed to write %s", file.Path)
+		}
+	}
+	return nil
+}
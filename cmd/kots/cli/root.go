@@ -22,9 +22,13 @@ func RootCmd() *cobra.Command {
 
 	cmd.AddCommand(PullCmd())
 	cmd.AddCommand(InstallCmd())
+	cmd.AddCommand(ValidateCmd())
+	cmd.AddCommand(RenderCmd())
 	cmd.AddCommand(UploadCmd())
 	cmd.AddCommand(DownloadCmd())
 	cmd.AddCommand(UpstreamCmd())
+	cmd.AddCommand(ConfigCmd())
+	cmd.AddCommand(CacheCmd())
 	cmd.AddCommand(AdminConsoleCmd())
 	cmd.AddCommand(ResetPasswordCmd())
 	cmd.AddCommand(VersionCmd())
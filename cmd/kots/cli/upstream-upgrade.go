@@ -7,10 +7,12 @@ import (
 	"net/http"
 	"os"
 	"strings"
+	"text/tabwriter"
 
 	"github.com/pkg/errors"
 	"github.com/replicatedhq/kots/pkg/k8sutil"
 	"github.com/replicatedhq/kots/pkg/logger"
+	"github.com/replicatedhq/kots/pkg/pull"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
@@ -33,6 +35,10 @@ func UpstreamUpgradeCmd() *cobra.Command {
 				os.Exit(1)
 			}
 
+			if v.GetBool("list") {
+				return listUpstreamUpdates(args[0], v)
+			}
+
 			log := logger.NewLogger()
 			log.ActionWithSpinner("Checking for application updates")
 
@@ -132,5 +138,63 @@ func UpstreamUpgradeCmd() *cobra.Command {
 	cmd.Flags().StringP("namespace", "n", "default", "the namespace where the admin console is running")
 	cmd.Flags().Bool("deploy", false, "when set, automatically deploy the latest version downloads")
 
+	cmd.Flags().Bool("list", false, "when set, list the pending releases with their release notes instead of downloading them")
+	cmd.Flags().String("license-file", "", "path to the license file to list pending releases with")
+	cmd.Flags().String("current-cursor", "", "the cursor of the installed release, only later releases are listed")
+	cmd.Flags().StringSlice("component-cursor", []string{}, "name=cursor of the installed release of an upstream in a composition")
+	cmd.Flags().String("app-dir", "", "the dir that a composition was pulled to, to list the releases after the cursors its upstreams were pulled at")
+	addHTTPClientFlags(cmd)
+
 	return cmd
 }
+
+// listUpstreamUpdates prints the releases that are pending from the upstream, without the admin console
+func listUpstreamUpdates(upstreamURI string, v *viper.Viper) error {
+	if v.GetString("license-file") == "" {
+		return errors.New("--license-file is required to list releases")
+	}
+
+	cursors, err := componentCursors(v)
+	if err != nil {
+		return err
+	}
+
+	getUpdatesOptions := pull.GetUpdatesOptions{
+		LicenseFile:       ExpandDir(v.GetString("license-file")),
+		CurrentCursor:     v.GetString("current-cursor"),
+		ComponentCursors:  cursors,
+		AppDir:            ExpandDir(v.GetString("app-dir")),
+		HTTPClientOptions: httpClientOptions(v),
+		Silent:            true,
+	}
+	updates, err := pull.GetUpdates(pull.RewriteUpstream(upstreamURI), getUpdatesOptions)
+	if err != nil {
+		return errors.Wrap(err, "failed to list releases")
+	}
+
+	if len(updates) == 0 {
+		fmt.Println("There are no application updates available")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tCURSOR\tCHANNEL\tCREATED\tREQUIRED\tBLOCKED")
+	for _, update := range updates {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%t\t%t\n", update.VersionLabel, update.Cursor, update.ChannelName, update.CreatedAt, update.IsRequired, update.IsBlocked)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	for _, update := range updates {
+		if update.ReleaseNotes == "" {
+			continue
+		}
+		fmt.Printf("\nRelease notes for %s:\n", update.VersionLabel)
+		for _, line := range strings.Split(strings.TrimSpace(update.ReleaseNotes), "\n") {
+			fmt.Printf("  %s\n", line)
+		}
+	}
+
+	return nil
+}
@@ -4,6 +4,15 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
+
+	units "github.com/docker/go-units"
+	"github.com/pkg/errors"
+	"github.com/replicatedhq/kots/pkg/k8sutil"
+	"github.com/replicatedhq/kots/pkg/upstream"
+	"github.com/replicatedhq/kots/pkg/upstream/cache"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
 )
 
 func ExpandDir(input string) string {
@@ -27,3 +36,101 @@ func defaultKubeConfig() string {
 	}
 	return filepath.Join(homeDir(), ".kube", "config")
 }
+
+func addHelmRepoFlags(cmd *cobra.Command) {
+	cmd.Flags().String("repo-config", "", "path to a repositories config file (helm's repositories.yaml format) with the helm repo aliases, credentials and tls files to use")
+	cmd.Flags().String("repo-username", "", "username to use when downloading from the helm repo")
+	cmd.Flags().String("repo-password", "", "password to use when downloading from the helm repo")
+	cmd.Flags().String("repo-cert-file", "", "client certificate file to use when downloading from the helm repo")
+	cmd.Flags().String("repo-key-file", "", "client key file to use when downloading from the helm repo")
+	cmd.Flags().String("repo-ca-file", "", "ca bundle file to use to verify the helm repo certificate")
+}
+
+func helmRepoOptions(v *viper.Viper) upstream.HelmRepoOptions {
+	return upstream.HelmRepoOptions{
+		ConfigFile: ExpandDir(v.GetString("repo-config")),
+		Username:   v.GetString("repo-username"),
+		Password:   v.GetString("repo-password"),
+		CertFile:   ExpandDir(v.GetString("repo-cert-file")),
+		KeyFile:    ExpandDir(v.GetString("repo-key-file")),
+		CAFile:     ExpandDir(v.GetString("repo-ca-file")),
+	}
+}
+
+func addClusterCapabilitiesFlags(cmd *cobra.Command) {
+	cmd.Flags().String("kubernetes-version", "", "the kubernetes version to render the application for (defaults to the version of the cluster when a kubeconfig is used)")
+	cmd.Flags().StringSlice("api-versions", []string{}, "the api versions available in the target cluster, such as networking.k8s.io/v1 (defaults to the api versions of the cluster when a kubeconfig is used)")
+}
+
+// clusterCapabilities returns the kubernetes version and api versions to render for. Values that are not
+// set with flags are discovered from the cluster when a kubeconfig is provided.
+func clusterCapabilities(v *viper.Viper, kubeconfig string) (string, []string, error) {
+	kubernetesVersion := v.GetString("kubernetes-version")
+	apiVersions := v.GetStringSlice("api-versions")
+
+	if kubeconfig == "" || (kubernetesVersion != "" && len(apiVersions) > 0) {
+		return kubernetesVersion, apiVersions, nil
+	}
+
+	clusterVersion, clusterAPIVersions, err := k8sutil.GetClusterCapabilities(kubeconfig)
+	if err != nil {
+		return "", nil, errors.Wrap(err, "failed to get cluster capabilities")
+	}
+
+	if kubernetesVersion == "" {
+		kubernetesVersion = clusterVersion
+	}
+	if len(apiVersions) == 0 {
+		apiVersions = clusterAPIVersions
+	}
+
+	return kubernetesVersion, apiVersions, nil
+}
+
+func addHTTPClientFlags(cmd *cobra.Command) {
+	cmd.Flags().String("upstream-ca-file", "", "ca bundle to trust in addition to the system roots when connecting to the upstream, such as the ca of a tls intercepting proxy")
+	cmd.Flags().Duration("upstream-timeout", 30*time.Second, "time to wait for a connection to the upstream, for its responses to start, and for more data while downloading")
+	cmd.Flags().Bool("insecure-skip-tls-verify", false, "set to true to skip verifying the certificate of the upstream (insecure)")
+}
+
+func httpClientOptions(v *viper.Viper) upstream.HTTPClientOptions {
+	return upstream.HTTPClientOptions{
+		Timeout:               v.GetDuration("upstream-timeout"),
+		CAFile:                ExpandDir(v.GetString("upstream-ca-file")),
+		InsecureSkipTLSVerify: v.GetBool("insecure-skip-tls-verify"),
+	}
+}
+
+func addCacheFlags(cmd *cobra.Command) {
+	cmd.Flags().String("cache-dir", cache.DefaultDir(), "directory to cache fetched releases in, so that they can be rendered again without downloading them")
+	cmd.Flags().String("cache-max-size", units.BytesSize(float64(cache.DefaultMaxSize)), "the size limit of the cache, the least recently used releases are removed when it's exceeded")
+	cmd.Flags().Bool("no-cache", false, "set to true to always download the release, without caching it")
+}
+
+// upstreamCache returns nil when caching is disabled
+func upstreamCache(v *viper.Viper) (*cache.Cache, error) {
+	if v.GetBool("no-cache") {
+		return nil, nil
+	}
+
+	maxSize, err := units.RAMInBytes(v.GetString("cache-max-size"))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse cache-max-size")
+	}
+
+	return cache.New(ExpandDir(v.GetString("cache-dir")), maxSize), nil
+}
+
+// componentCursors parses the name=cursor values of the component-cursor flag, which pin the
+// upstreams of a composition to a cursor
+func componentCursors(v *viper.Viper) (map[string]string, error) {
+	cursors := map[string]string{}
+	for _, value := range v.GetStringSlice("component-cursor") {
+		parts := strings.SplitN(value, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return nil, errors.Errorf("invalid component cursor %q, expected name=cursor", value)
+		}
+		cursors[parts[0]] = parts[1]
+	}
+	return cursors, nil
+}
@@ -0,0 +1,99 @@
+package cli
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+	"github.com/replicatedhq/kots/pkg/base"
+	"github.com/replicatedhq/kots/pkg/logger"
+	"github.com/replicatedhq/kots/pkg/validate"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+func ValidateCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:           "validate [dir]",
+		Short:         "Validate Kubernetes manifests against the schemas of a Kubernetes version",
+		Long:          `Validate each of the Kubernetes manifests in a directory, such as a base or the upstream of a release, against the OpenAPI schema of a Kubernetes version and the schemas of any CustomResourceDefinitions in the directory. Schemas are read from the schema dir, so validation works without access to a cluster.`,
+		SilenceUsage:  true,
+		SilenceErrors: false,
+		PreRun: func(cmd *cobra.Command, args []string) {
+			viper.BindPFlags(cmd.Flags())
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			v := viper.GetViper()
+
+			if len(args) == 0 {
+				cmd.Help()
+				os.Exit(1)
+			}
+
+			files, err := readBaseFiles(ExpandDir(args[0]))
+			if err != nil {
+				return err
+			}
+
+			validationErrors, err := validate.ValidateFiles(files, validate.Options{
+				SchemaDir:         ExpandDir(v.GetString("schema-dir")),
+				KubernetesVersion: v.GetString("kubernetes-version"),
+			})
+			if err != nil {
+				return err
+			}
+
+			log := logger.NewLogger()
+			log.Initialize()
+			if len(validationErrors) == 0 {
+				log.Info("All resources in %s are valid", args[0])
+				return nil
+			}
+
+			for _, validationError := range validationErrors {
+				log.Info("%s", validationError.Error())
+			}
+			return errors.Errorf("%d resources failed validation", len(validationErrors))
+		},
+	}
+
+	cmd.Flags().String("kubernetes-version", "", "the kubernetes version to validate against (not required when there is only one version in the schema dir)")
+	cmd.Flags().String("schema-dir", validate.DefaultSchemaDir(), "directory with a <kubernetes version>/swagger.json schema for each kubernetes version to validate against")
+
+	return cmd
+}
+
+// readBaseFiles reads each of the files in dir, with paths relative to dir
+func readBaseFiles(dir string) ([]base.BaseFile, error) {
+	files := []base.BaseFile{}
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+
+		content, err := ioutil.ReadFile(path)
+		if err != nil {
+			return errors.Wrapf(err, "failed to read %s", path)
+		}
+
+		relPath, err := filepath.Rel(dir, path)
+		if err != nil {
+			return errors.Wrap(err, "failed to get relative path")
+		}
+
+		files = append(files, base.BaseFile{
+			Path:    filepath.ToSlash(relPath),
+			Content: content,
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to walk %s", dir)
+	}
+
+	return files, nil
+}
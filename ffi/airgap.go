@@ -11,9 +11,11 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/mholt/archiver"
 	"github.com/pkg/errors"
+	"github.com/replicatedhq/kots/pkg/objectstore"
 	"github.com/replicatedhq/kots/pkg/pull"
 )
 
@@ -39,6 +41,17 @@ func PullFromAirgap(socket, licenseData, airgapDir, downstream, namespace, outpu
 		}
 		defer os.RemoveAll(workspace)
 
+		// the bundle can be read straight from object storage, instead of a dir that it was extracted to
+		if objectstore.IsURL(airgapDir) {
+			downloadedDir, err := downloadAirgapFromObjectStore(workspace, airgapDir)
+			if err != nil {
+				fmt.Printf("failed to download airgap bundle: %s\n", err)
+				ffiResult = NewFFIResult(1).WithError(err)
+				return
+			}
+			airgapDir = downloadedDir
+		}
+
 		// releaseDir is the contents of the release tar (yaml, no images)
 		releaseDir, err := extractAppRelease(workspace, airgapDir)
 		if err != nil {
@@ -90,6 +103,7 @@ func PullFromAirgap(socket, licenseData, airgapDir, downstream, namespace, outpu
 				Password:   password,
 			},
 		}
+		pullOptions.KubernetesVersion, pullOptions.APIVersions = clusterCapabilities()
 
 		if _, err := pull.Pull(fmt.Sprintf("replicated://%s", license.Spec.AppSlug), pullOptions); err != nil {
 			fmt.Printf("failed to pull upstream: %s\n", err.Error())
@@ -136,9 +150,64 @@ func downloadAirgapAchive(workspace string, airgapURL string) (string, error) {
 	}
 	defer resp.Body.Close()
 
-	gzipReader, err := gzip.NewReader(resp.Body)
+	if err := extractAirgapArchive(resp.Body, destDir); err != nil {
+		return "", err
+	}
+
+	return destDir, nil
+}
+
+// downloadAirgapFromObjectStore downloads an airgap bundle from s3 compatible storage, either
+// a bundle archive or a prefix that contains the extracted bundle, and returns the local dir
+func downloadAirgapFromObjectStore(workspace string, airgapURI string) (string, error) {
+	location, err := objectstore.ParseURL(airgapURI)
 	if err != nil {
-		return "", errors.Wrapf(err, "unexpected status code: %v", resp.StatusCode)
+		return "", errors.Wrap(err, "failed to parse airgap uri")
+	}
+
+	client, err := objectstore.NewClient()
+	if err != nil {
+		return "", errors.Wrap(err, "failed to create s3 client")
+	}
+
+	destDir := filepath.Join(workspace, "extracted-airgap")
+	if err := os.Mkdir(destDir, 0744); err != nil {
+		return "", errors.Wrap(err, "failed to create tmp dir")
+	}
+
+	if !isAirgapArchive(location.Key) {
+		if err := objectstore.DownloadPrefix(client, location.Bucket, location.Key, destDir); err != nil {
+			return "", errors.Wrap(err, "failed to download airgap bundle")
+		}
+		return destDir, nil
+	}
+
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(objectstore.Download(client, location.Bucket, location.Key, "", pw))
+	}()
+	defer pr.Close()
+
+	if err := extractAirgapArchive(pr, destDir); err != nil {
+		return "", errors.Wrap(err, "failed to extract airgap bundle")
+	}
+
+	return destDir, nil
+}
+
+func isAirgapArchive(key string) bool {
+	for _, ext := range []string{".airgap", ".tar.gz", ".tgz"} {
+		if strings.HasSuffix(strings.ToLower(key), ext) {
+			return true
+		}
+	}
+	return false
+}
+
+func extractAirgapArchive(r io.Reader, destDir string) error {
+	gzipReader, err := gzip.NewReader(r)
+	if err != nil {
+		return errors.Wrap(err, "failed to create gzip reader")
 	}
 	defer gzipReader.Close()
 
@@ -149,7 +218,7 @@ func downloadAirgapAchive(workspace string, airgapURL string) (string, error) {
 			break // End of archive
 		}
 		if err != nil {
-			return "", errors.Wrap(err, "failed to expand archive")
+			return errors.Wrap(err, "failed to expand archive")
 		}
 
 		if hdr.Typeflag != tar.TypeReg {
@@ -159,7 +228,7 @@ func downloadAirgapAchive(workspace string, airgapURL string) (string, error) {
 
 		fileName := filepath.Join(destDir, hdr.Name)
 		if err := os.MkdirAll(filepath.Dir(fileName), 0744); err != nil {
-			return "", errors.Wrapf(err, "failed to create path %q", filepath.Dir(fileName))
+			return errors.Wrapf(err, "failed to create path %q", filepath.Dir(fileName))
 		}
 
 		err = func() error { // func so we can defer close files... /shrug
@@ -175,11 +244,11 @@ func downloadAirgapAchive(workspace string, airgapURL string) (string, error) {
 			return nil
 		}()
 		if err != nil {
-			return "", err
+			return err
 		}
 	}
 
-	return destDir, nil
+	return nil
 }
 
 func extractAppRelease(workspace string, airgapDir string) (string, error) {
@@ -4,6 +4,9 @@ import "C"
 
 import (
 	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 
 	"github.com/replicatedhq/kots/pkg/config"
 	"github.com/replicatedhq/kots/pkg/logger"
@@ -18,3 +21,61 @@ func TemplateConfig(configSpecData string, configValuesData string) *C.char {
 	}
 	return C.CString(rendered)
 }
+
+//export RotateEncryptionKey
+func RotateEncryptionKey(socket, archivePath string) {
+	go func() {
+		var ffiResult *FFIResult
+
+		statusClient, err := connectToStatusServer(socket)
+		if err != nil {
+			fmt.Printf("failed to connect to status server: %s\n", err)
+			return
+		}
+		defer func() {
+			statusClient.end(ffiResult)
+		}()
+
+		tmpRoot, err := ioutil.TempDir("", "kots")
+		if err != nil {
+			fmt.Printf("failed to create temp path: %s\n", err.Error())
+			ffiResult = NewFFIResult(-1).WithError(err)
+			return
+		}
+		defer os.RemoveAll(tmpRoot)
+
+		tarGz, err := extractArchive(tmpRoot, archivePath)
+		if err != nil {
+			fmt.Printf("failed to extract archive: %s\n", err.Error())
+			ffiResult = NewFFIResult(-1).WithError(err)
+			return
+		}
+
+		if err := config.RotateEncryptionKey(tmpRoot); err != nil {
+			fmt.Printf("failed to rotate encryption key: %s\n", err.Error())
+			ffiResult = NewFFIResult(-1).WithError(err)
+			return
+		}
+
+		paths := []string{
+			filepath.Join(tmpRoot, "upstream"),
+			filepath.Join(tmpRoot, "base"),
+			filepath.Join(tmpRoot, "overlays"),
+		}
+
+		err = os.Remove(archivePath)
+		if err != nil {
+			fmt.Printf("failed to delete archive to replace: %s\n", err.Error())
+			ffiResult = NewFFIResult(-1).WithError(err)
+			return
+		}
+
+		if err := tarGz.Archive(paths, archivePath); err != nil {
+			fmt.Printf("failed to write archive: %s\n", err.Error())
+			ffiResult = NewFFIResult(-1).WithError(err)
+			return
+		}
+
+		ffiResult = NewFFIResult(0)
+	}()
+}
@@ -13,6 +13,7 @@ import (
 	"github.com/pkg/errors"
 	kotsv1beta1 "github.com/replicatedhq/kots/kotskinds/apis/kots/v1beta1"
 	kotsscheme "github.com/replicatedhq/kots/kotskinds/client/kotsclientset/scheme"
+	"github.com/replicatedhq/kots/pkg/k8sutil"
 	"github.com/replicatedhq/kots/pkg/pull"
 	"github.com/replicatedhq/kots/pkg/upstream"
 	"github.com/replicatedhq/kots/pkg/version"
@@ -73,7 +74,9 @@ func UpdateCheck(socket, fromArchivePath, namespace string) {
 			ExcludeKotsKinds:    true,
 			ExcludeAdminConsole: true,
 			CreateAppDir:        false,
+			HTTPClientOptions:   upstream.HTTPClientOptionsFromEnv(),
 		}
+		pullOptions.KubernetesVersion, pullOptions.APIVersions = clusterCapabilities()
 
 		if _, err := pull.Pull(fmt.Sprintf("replicated://%s", license.Spec.AppSlug), pullOptions); err != nil {
 			fmt.Printf("failed to pull upstream: %s\n", err.Error())
@@ -237,3 +240,16 @@ func parseConfigValuesFromFile(filename string) (*kotsv1beta1.ConfigValues, erro
 }
 
 func main() {}
+
+// clusterCapabilities returns the kubernetes version and api versions of the cluster that kotsadm
+// is running in. They're empty when the cluster can't be discovered, so that the application is
+// rendered for the cluster in its installation.
+func clusterCapabilities() (string, []string) {
+	kubernetesVersion, apiVersions, err := k8sutil.GetCurrentClusterCapabilities()
+	if err != nil {
+		fmt.Printf("failed to discover cluster capabilities: %s\n", err.Error())
+		return "", nil
+	}
+
+	return kubernetesVersion, apiVersions
+}
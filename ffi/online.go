@@ -14,6 +14,8 @@ import (
 	kotsv1beta1 "github.com/replicatedhq/kots/kotskinds/apis/kots/v1beta1"
 	"github.com/replicatedhq/kots/pkg/pull"
 	"github.com/replicatedhq/kots/pkg/rewrite"
+	"github.com/replicatedhq/kots/pkg/upstream"
+	"github.com/replicatedhq/kots/pkg/upstream/cache"
 	serializer "k8s.io/apimachinery/pkg/runtime/serializer/json"
 	"k8s.io/client-go/kubernetes/scheme"
 )
@@ -57,14 +59,18 @@ func PullFromLicense(socket string, licenseData string, downstream string, names
 		defer os.RemoveAll(tmpRoot)
 
 		pullOptions := pull.PullOptions{
-			Downstreams:         []string{downstream},
-			LicenseFile:         licenseFile,
-			Namespace:           namespace,
-			ExcludeKotsKinds:    true,
-			RootDir:             tmpRoot,
-			ExcludeAdminConsole: true,
-			CreateAppDir:        false,
+			Downstreams:          []string{downstream},
+			LicenseFile:          licenseFile,
+			Namespace:            namespace,
+			ExcludeKotsKinds:     true,
+			RootDir:              tmpRoot,
+			ExcludeAdminConsole:  true,
+			CreateAppDir:         false,
+			HTTPClientOptions:    upstream.HTTPClientOptionsFromEnv(),
+			Cache:                cache.FromEnv(),
+			SkipReleaseSignature: os.Getenv("KOTS_SKIP_RELEASE_SIGNATURE") == "true",
 		}
+		pullOptions.KubernetesVersion, pullOptions.APIVersions = clusterCapabilities()
 
 		if _, err := pull.Pull(fmt.Sprintf("replicated://%s", license.Spec.AppSlug), pullOptions); err != nil {
 			fmt.Printf("failed to pull upstream: %s\n", err.Error())
@@ -209,24 +215,27 @@ func RewriteVersion(socket, fromArchivePath, outputFile, downstreamsStr, k8sName
 		}
 
 		options := rewrite.RewriteOptions{
-			RootDir:           tmpRoot,
-			UpstreamURI:       fmt.Sprintf("replicated://%s", license.Spec.AppSlug),
-			UpstreamPath:      filepath.Join(tmpRoot, "upstream"),
-			Installation:      installation,
-			Downstreams:       donwstreams,
-			Silent:            true,
-			CreateAppDir:      false,
-			ExcludeKotsKinds:  true,
-			License:           license,
-			ConfigValues:      configValues,
-			K8sNamespace:      k8sNamespace,
-			ReportWriter:      statusClient.getOutputWriter(),
-			CopyImages:        copyImages,
-			RegistryEndpoint:  registryInfo.Host,
-			RegistryUsername:  registryInfo.Username,
-			RegistryPassword:  registryInfo.Password,
-			RegistryNamespace: registryInfo.Namespace,
+			RootDir:              tmpRoot,
+			UpstreamURI:          fmt.Sprintf("replicated://%s", license.Spec.AppSlug),
+			UpstreamPath:         filepath.Join(tmpRoot, "upstream"),
+			Installation:         installation,
+			Downstreams:          donwstreams,
+			Silent:               true,
+			CreateAppDir:         false,
+			ExcludeKotsKinds:     true,
+			License:              license,
+			ConfigValues:         configValues,
+			K8sNamespace:         k8sNamespace,
+			ReportWriter:         statusClient.getOutputWriter(),
+			CopyImages:           copyImages,
+			RegistryEndpoint:     registryInfo.Host,
+			RegistryUsername:     registryInfo.Username,
+			RegistryPassword:     registryInfo.Password,
+			RegistryNamespace:    registryInfo.Namespace,
+			Cache:                cache.FromEnv(),
+			SkipReleaseSignature: os.Getenv("KOTS_SKIP_RELEASE_SIGNATURE") == "true",
 		}
+		options.KubernetesVersion, options.APIVersions = clusterCapabilities()
 
 		if err := rewrite.Rewrite(options); err != nil {
 			fmt.Printf("failed to pull upstream: %s\n", err.Error())
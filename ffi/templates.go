@@ -52,6 +52,7 @@ func RenderFile(socket string, filePath string, archivePath string) {
 
 		builder := template.Builder{}
 		builder.AddCtx(template.StaticCtx{})
+		builder.AddCtx(template.ClusterCtx{})
 
 		// look for config
 		config, values, license, installation, err := findConfig(tmpRoot)
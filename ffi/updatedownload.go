@@ -12,6 +12,8 @@ import (
 	"github.com/pkg/errors"
 	"github.com/replicatedhq/kots/pkg/cursor"
 	"github.com/replicatedhq/kots/pkg/pull"
+	"github.com/replicatedhq/kots/pkg/upstream"
+	"github.com/replicatedhq/kots/pkg/upstream/cache"
 )
 
 //export UpdateDownload
@@ -72,17 +74,21 @@ func UpdateDownload(socket, fromArchivePath, namespace, registryJson, cursor str
 		}
 
 		pullOptions := pull.PullOptions{
-			LicenseFile:         expectedLicenseFile,
-			Namespace:           namespace,
-			ConfigFile:          filepath.Join(tmpRoot, "upstream", "userdata", "config.yaml"),
-			InstallationFile:    installationFilePath,
-			UpdateCursor:        cursor,
-			RootDir:             tmpRoot,
-			ExcludeKotsKinds:    true,
-			ExcludeAdminConsole: true,
-			CreateAppDir:        false,
-			ReportWriter:        statusClient.getOutputWriter(),
-		}
+			LicenseFile:          expectedLicenseFile,
+			Namespace:            namespace,
+			ConfigFile:           filepath.Join(tmpRoot, "upstream", "userdata", "config.yaml"),
+			InstallationFile:     installationFilePath,
+			UpdateCursor:         cursor,
+			RootDir:              tmpRoot,
+			ExcludeKotsKinds:     true,
+			ExcludeAdminConsole:  true,
+			CreateAppDir:         false,
+			ReportWriter:         statusClient.getOutputWriter(),
+			HTTPClientOptions:    upstream.HTTPClientOptionsFromEnv(),
+			Cache:                cache.FromEnv(),
+			SkipReleaseSignature: os.Getenv("KOTS_SKIP_RELEASE_SIGNATURE") == "true",
+		}
+		pullOptions.KubernetesVersion, pullOptions.APIVersions = clusterCapabilities()
 
 		if registryInfo.Host != "" {
 			pullOptions.RewriteImages = true
@@ -230,6 +236,7 @@ func UpdateDownloadFromAirgap(socket, fromArchivePath, namespace, registryJson,
 				Password:  registryInfo.Password,
 			},
 		}
+		pullOptions.KubernetesVersion, pullOptions.APIVersions = clusterCapabilities()
 
 		if _, err := pull.Pull(fmt.Sprintf("replicated://%s", license.Spec.AppSlug), pullOptions); err != nil {
 			fmt.Printf("failed to pull upstream: %s\n", err.Error())
@@ -41,9 +41,10 @@ func ListUpdates(socket, licenseData, currentCursor string) {
 		defer os.Remove(licenseFile)
 
 		getUpdatesOptions := pull.GetUpdatesOptions{
-			LicenseFile:   licenseFile,
-			CurrentCursor: currentCursor,
-			Silent:        true,
+			LicenseFile:       licenseFile,
+			CurrentCursor:     currentCursor,
+			Silent:            true,
+			HTTPClientOptions: upstream.HTTPClientOptionsFromEnv(),
 		}
 
 		updates, err := pull.GetUpdates(fmt.Sprintf("replicated://%s", license.Spec.AppSlug), getUpdatesOptions)
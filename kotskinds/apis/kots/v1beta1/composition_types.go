@@ -0,0 +1,64 @@
+/*
+Copyright 2019 Replicated, Inc..
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// CompositionUpstream is one of the upstreams that an application is composed from
+type CompositionUpstream struct {
+	// Name is the directory that the upstream is written to, under upstream/ and base/
+	Name string `json:"name"`
+	URI  string `json:"uri"`
+	// HelmRepoURI is the repo to download helm:// upstreams from, when it's not a known repo
+	HelmRepoURI string `json:"helmRepoURI,omitempty"`
+}
+
+// CompositionSpec defines the desired state of CompositionSpec
+type CompositionSpec struct {
+	Upstreams []CompositionUpstream `json:"upstreams"`
+}
+
+// CompositionStatus defines the observed state of Composition
+type CompositionStatus struct {
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+// Composition is the Schema for the composition API
+// +k8s:openapi-gen=true
+// +kubebuilder:subresource:status
+type Composition struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   CompositionSpec   `json:"spec,omitempty"`
+	Status CompositionStatus `json:"status,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// CompositionList contains a list of Compositions
+type CompositionList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []Composition `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&Composition{}, &CompositionList{})
+}
@@ -157,6 +157,9 @@ func (m *MappedChartValue) UnmarshalJSON(value []byte) error {
 type ChartIdentifier struct {
 	Name         string `json:"name"`
 	ChartVersion string `json:"chartVersion"`
+	// Repository is an OCI registry repository that contains the chart, such as
+	// oci://registry.example.com/charts. The chart is expected to be in the release when not set.
+	Repository string `json:"repository,omitempty"`
 }
 
 func renderOneLevelValues(values map[string]MappedChartValue, parent []string) ([]string, error) {
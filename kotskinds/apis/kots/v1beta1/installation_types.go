@@ -26,6 +26,9 @@ type InstallationSpec struct {
 	VersionLabel  string `json:"versionLabel,omitempty"`
 	ReleaseNotes  string `json:"releaseNotes,omitempty"`
 	EncryptionKey string `json:"encryptionKey,omitempty"`
+	// KubernetesVersion and APIVersions are the target cluster that the application was rendered for
+	KubernetesVersion string   `json:"kubernetesVersion,omitempty"`
+	APIVersions       []string `json:"apiVersions,omitempty"`
 }
 
 // InstallationStatus defines the observed state of Installation
@@ -1,27 +1,36 @@
 package base
 
 import (
-	"bytes"
 	"crypto/sha256"
 	"fmt"
 	"strconv"
+	"strings"
 
 	"github.com/pkg/errors"
 	kotsscheme "github.com/replicatedhq/kots/kotskinds/client/kotsclientset/scheme"
 	troubleshootscheme "github.com/replicatedhq/troubleshoot/pkg/client/troubleshootclientset/scheme"
 	"gopkg.in/yaml.v2"
-	batchv1 "k8s.io/api/batch/v1"
-	serializer "k8s.io/apimachinery/pkg/runtime/serializer/json"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/client-go/kubernetes/scheme"
+	k8syaml "sigs.k8s.io/yaml"
 )
 
 type Base struct {
+	// Path is the directory of a sub-base, relative to the base that includes it
+	Path  string
 	Files []BaseFile
+	// Bases are written to their own directories and included in this base's kustomization
+	Bases []Base
 }
 
 type BaseFile struct {
 	Path    string
 	Content []byte
+
+	// UpstreamPath and UpstreamDocIndex are the file and the document in it that this file was
+	// split from, so that errors can be reported against the upstream
+	UpstreamPath     string
+	UpstreamDocIndex int
 }
 
 type OverlySimpleGVK struct {
@@ -52,35 +61,180 @@ func GetGVKWithNameHash(content []byte) []byte {
 	return h.Sum(nil)
 }
 
-func (f BaseFile) transpileHelmHooksToKotsHooks() error {
-	decode := scheme.Codecs.UniversalDeserializer().Decode
-	obj, gvk, err := decode(f.Content, nil, nil)
-	if err != nil {
-		return nil // this isn't an error, it's just not a job witih a hook, that's certain
+const (
+	// HookAnnotation lists the events that a hook resource is applied on, instead of being
+	// part of the base kustomization
+	HookAnnotation             = "kots.io/hook"
+	HookWeightAnnotation       = "kots.io/hook-weight"
+	HookDeletePolicyAnnotation = "kots.io/hook-delete-policy"
+)
+
+// HooksDir is the dir in bases and overlays that has a kustomization for each hook event
+const HooksDir = "hooks"
+
+// helmHookEvents maps the helm hooks that kots can run to kots hook events
+var helmHookEvents = map[string]string{
+	"pre-install":  "pre-install",
+	"post-install": "post-install",
+	"pre-upgrade":  "pre-upgrade",
+	"post-upgrade": "post-upgrade",
+	"test":         "test",
+	"test-success": "test",
+}
+
+// helmSkippedHooks are the helm hooks that are only run when a release is deleted or rolled
+// back, which kots doesn't do
+var helmSkippedHooks = map[string]bool{
+	"pre-delete":    true,
+	"post-delete":   true,
+	"pre-rollback":  true,
+	"post-rollback": true,
+}
+
+// transpileHelmHooksToKotsHooks translates the helm hook annotations on resources of any kind
+// into kots hook annotations. Resources that only have delete or rollback hooks are excluded from
+// the base, since they would otherwise be applied on every deploy. Resources with other hooks that
+// kots doesn't run, such as the crd-install hook of helm 2, are applied with the base.
+func (f *BaseFile) transpileHelmHooksToKotsHooks() error {
+	docs := strings.Split(string(f.Content), "\n---\n")
+
+	transpiled := false
+	for i, doc := range docs {
+		content, err := transpileHelmHooksInDoc([]byte(doc))
+		if err != nil {
+			return errors.Wrapf(err, "failed to transpile hooks in %s", f.Path)
+		}
+		if content == nil {
+			continue
+		}
+
+		docs[i] = string(content)
+		transpiled = true
 	}
 
-	// we currently only support hooks on jobs
-	if gvk.Group != "batch" || gvk.Version != "v1" || gvk.Kind != "Job" {
-		return nil
+	if transpiled {
+		f.Content = []byte(strings.Join(docs, "\n---\n"))
+	}
+	return nil
+}
+
+// transpileHelmHooksInDoc returns the translated document, or nil when it doesn't have a helm hook
+func transpileHelmHooksInDoc(content []byte) ([]byte, error) {
+	jsonContent, err := k8syaml.YAMLToJSON(content)
+	if err != nil {
+		return nil, nil // this isn't an error, it's just not a resource with a hook
 	}
 
-	job := obj.(*batchv1.Job)
+	obj, _, err := unstructured.UnstructuredJSONScheme.Decode(jsonContent, nil, nil)
+	if err != nil {
+		return nil, nil
+	}
+	resource, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return nil, nil
+	}
 
-	helmHookDeletePolicyAnnotation, ok := job.Annotations["helm.sh/hook-delete-policy"]
+	annotations := resource.GetAnnotations()
+	helmHook, ok := annotations["helm.sh/hook"]
 	if !ok {
+		return nil, nil
+	}
+
+	events := []string{}
+	skipped := true
+	for _, helmEvent := range strings.Split(helmHook, ",") {
+		helmEvent = strings.TrimSpace(helmEvent)
+		if !helmSkippedHooks[helmEvent] {
+			skipped = false
+		}
+
+		event, ok := helmHookEvents[helmEvent]
+		if !ok || containsString(events, event) {
+			continue
+		}
+		events = append(events, event)
+	}
+
+	if len(events) == 0 && skipped {
+		annotations["kots.io/exclude"] = "true"
+	} else if len(events) == 0 {
+		// the resource is part of the base, and helm's annotations would make it a hook to
+		// anything that deploys it with helm again
+		delete(annotations, "helm.sh/hook")
+		delete(annotations, "helm.sh/hook-weight")
+		delete(annotations, "helm.sh/hook-delete-policy")
+		if len(annotations) == 0 {
+			annotations = nil
+		}
+	} else {
+		weight := strings.TrimSpace(annotations["helm.sh/hook-weight"])
+		if weight == "" {
+			weight = "0"
+		}
+		if _, err := strconv.Atoi(weight); err != nil {
+			return nil, errors.Errorf("hook weight %q of %s %s is not an integer", weight, resource.GetKind(), resource.GetName())
+		}
+
+		annotations[HookAnnotation] = strings.Join(events, ",")
+		annotations[HookWeightAnnotation] = weight
+		if deletePolicy, ok := annotations["helm.sh/hook-delete-policy"]; ok {
+			annotations[HookDeletePolicyAnnotation] = deletePolicy
+		}
+	}
+	resource.SetAnnotations(annotations)
+
+	jsonContent, err = resource.MarshalJSON()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to marshal resource")
+	}
+
+	transpiled, err := k8syaml.JSONToYAML(jsonContent)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to convert resource to yaml")
+	}
+
+	return transpiled, nil
+}
+
+// hookEvents returns the kots hook events that the file is applied on, or nil when it's
+// not a hook
+func (f BaseFile) hookEvents() []string {
+	o := OverlySimpleGVK{}
+	if err := yaml.Unmarshal(f.Content, &o); err != nil {
 		return nil
 	}
 
-	job.Annotations["kots.io/hook-delete-policy"] = helmHookDeletePolicyAnnotation
+	hook, ok := o.Metadata.Annotations[HookAnnotation].(string)
+	if !ok || hook == "" {
+		return nil
+	}
 
-	s := serializer.NewYAMLSerializer(serializer.DefaultMetaFactory, scheme.Scheme, scheme.Scheme)
-	var b bytes.Buffer
-	if err := s.Encode(job, &b); err != nil {
-		return errors.Wrap(err, "failed to encode job")
+	events := []string{}
+	for _, event := range strings.Split(hook, ",") {
+		events = append(events, strings.TrimSpace(event))
 	}
+	return events
+}
 
-	f.Content = b.Bytes()
-	return nil
+// hookWeight returns the order that the hook is applied in, lowest first
+func (f BaseFile) hookWeight() int {
+	o := OverlySimpleGVK{}
+	if err := yaml.Unmarshal(f.Content, &o); err != nil {
+		return 0
+	}
+
+	weight, _ := o.Metadata.Annotations[HookWeightAnnotation].(string)
+	w, _ := strconv.Atoi(weight)
+	return w
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
 }
 
 // ShouldBeIncludedInBaseKustomization attempts to determine if this is a valid Kubernetes manifest.
@@ -104,6 +104,140 @@ spec:
           restartPolicy: Never
           backoffLimit: 4`,
 		},
+		{
+			name: "a job with a helm hook, weight and delete policy",
+			content: `apiVersion: batch/v1
+kind: Job
+metadata:
+  name: migrate
+  annotations:
+    "helm.sh/hook": pre-install,pre-upgrade
+    "helm.sh/hook-weight": "-5"
+    "helm.sh/hook-delete-policy": "hook-succeeded"
+spec:
+  backoffLimit: 4`,
+			expected: `apiVersion: batch/v1
+kind: Job
+metadata:
+  annotations:
+    helm.sh/hook: pre-install,pre-upgrade
+    helm.sh/hook-delete-policy: hook-succeeded
+    helm.sh/hook-weight: "-5"
+    kots.io/hook: pre-install,pre-upgrade
+    kots.io/hook-delete-policy: hook-succeeded
+    kots.io/hook-weight: "-5"
+  name: migrate
+spec:
+  backoffLimit: 4
+`,
+		},
+		{
+			name: "a hook on any kind, without a weight",
+			content: `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: test-config
+  annotations:
+    "helm.sh/hook": test-success
+data:
+  key: value`,
+			expected: `apiVersion: v1
+data:
+  key: value
+kind: ConfigMap
+metadata:
+  annotations:
+    helm.sh/hook: test-success
+    kots.io/hook: test
+    kots.io/hook-weight: "0"
+  name: test-config
+`,
+		},
+		{
+			name: "an unsupported hook is excluded",
+			content: `apiVersion: batch/v1
+kind: Job
+metadata:
+  name: cleanup
+  annotations:
+    "helm.sh/hook": pre-delete
+spec:
+  backoffLimit: 4`,
+			expected: `apiVersion: batch/v1
+kind: Job
+metadata:
+  annotations:
+    helm.sh/hook: pre-delete
+    kots.io/exclude: "true"
+  name: cleanup
+spec:
+  backoffLimit: 4
+`,
+		},
+		{
+			name: "a rollback and delete hook is excluded",
+			content: `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: cleanup
+  annotations:
+    "helm.sh/hook": pre-rollback,post-delete`,
+			expected: `apiVersion: v1
+kind: ConfigMap
+metadata:
+  annotations:
+    helm.sh/hook: pre-rollback,post-delete
+    kots.io/exclude: "true"
+  name: cleanup
+`,
+		},
+		{
+			name: "a crd-install hook is applied with the base",
+			content: `apiVersion: apiextensions.k8s.io/v1beta1
+kind: CustomResourceDefinition
+metadata:
+  name: databases.example.com
+  annotations:
+    "helm.sh/hook": crd-install
+    "helm.sh/hook-delete-policy": before-hook-creation
+spec:
+  group: example.com`,
+			expected: `apiVersion: apiextensions.k8s.io/v1beta1
+kind: CustomResourceDefinition
+metadata:
+  name: databases.example.com
+spec:
+  group: example.com
+`,
+		},
+		{
+			name: "multiple docs",
+			content: `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: not-a-hook
+---
+apiVersion: v1
+kind: Secret
+metadata:
+  name: hook
+  annotations:
+    "helm.sh/hook": post-install`,
+			expected: `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: not-a-hook
+---
+apiVersion: v1
+kind: Secret
+metadata:
+  annotations:
+    helm.sh/hook: post-install
+    kots.io/hook: post-install
+    kots.io/hook-weight: "0"
+  name: hook
+`,
+		},
 	}
 
 	for _, test := range tests {
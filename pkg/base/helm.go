@@ -7,17 +7,22 @@ import (
 	"os"
 	"path"
 	"path/filepath"
+	"sort"
 	"strings"
 
+	semver "github.com/Masterminds/semver/v3"
 	"github.com/ghodss/yaml"
 	"github.com/pkg/errors"
+	"github.com/replicatedhq/kots/pkg/template"
 	upstreamtypes "github.com/replicatedhq/kots/pkg/upstream/types"
 	"github.com/replicatedhq/kots/pkg/util"
 	"k8s.io/helm/pkg/chartutil"
+	"k8s.io/helm/pkg/engine"
 	"k8s.io/helm/pkg/proto/hapi/chart"
 	"k8s.io/helm/pkg/renderutil"
 	"k8s.io/helm/pkg/strvals"
 	"k8s.io/helm/pkg/timeconv"
+	tversion "k8s.io/helm/pkg/version"
 )
 
 func RenderHelm(u *upstreamtypes.Upstream, renderOptions *RenderOptions) (*Base, error) {
@@ -40,7 +45,7 @@ func RenderHelm(u *upstreamtypes.Upstream, renderOptions *RenderOptions) (*Base,
 			}
 		}
 
-		if err := ioutil.WriteFile(p, file.Content, 0644); err != nil {
+		if err := file.WriteFile(p); err != nil {
 			return nil, errors.Wrap(err, "failed to write chart file")
 		}
 	}
@@ -52,79 +57,42 @@ func RenderHelm(u *upstreamtypes.Upstream, renderOptions *RenderOptions) (*Base,
 		}
 	}
 
-	marshalledVals, err := yaml.Marshal(vals)
-	if err != nil {
-		return nil, errors.Wrap(err, "failed to marshal helm values")
-	}
-
-	config := &chart.Config{Raw: string(marshalledVals), Values: map[string]*chart.Value{}}
-
-	c, err := chartutil.Load(chartPath)
+	apiVersion, err := helmChartAPIVersion(chartPath)
 	if err != nil {
-		return nil, errors.Wrap(err, "failed to load chart")
+		return nil, errors.Wrap(err, "failed to read chart api version")
 	}
 
-	renderOpts := renderutil.Options{
-		ReleaseOptions: chartutil.ReleaseOptions{
-			Name:      u.Name,
-			IsInstall: true,
-			IsUpgrade: false,
-			Time:      timeconv.Now(),
-			Namespace: renderOptions.Namespace,
-		},
-		KubeVersion: "1.16.0",
+	var rendered map[string]string
+	if apiVersion == "v2" {
+		rendered, err = renderHelmV3(chartPath, u.Name, vals, renderOptions)
+	} else {
+		rendered, err = renderHelmV2(chartPath, u.Name, vals, renderOptions)
 	}
-
-	// Silence the go logger because helm will complain about some of our template strings
-	golog.SetOutput(ioutil.Discard)
-	defer golog.SetOutput(os.Stdout)
-	rendered, err := renderutil.Render(c, config, renderOpts)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to render chart")
 	}
 
-	baseFiles := []BaseFile{}
-	for k, v := range rendered {
-		if !renderOptions.SplitMultiDocYAML {
-			baseFile := BaseFile{
-				Path:    k,
-				Content: []byte(v),
-			}
-			if err := baseFile.transpileHelmHooksToKotsHooks(); err != nil {
-				return nil, errors.Wrap(err, "failed to transpile helm hooks to kots hooks")
-			}
-
-			baseFiles = append(baseFiles, baseFile)
-			continue
-		}
-
-		fileStrings := strings.Split(v, "\n---\n")
-		if len(fileStrings) == 1 {
-			baseFile := BaseFile{
-				Path:    k,
-				Content: []byte(v),
-			}
-			if err := baseFile.transpileHelmHooksToKotsHooks(); err != nil {
-				return nil, errors.Wrap(err, "failed to transpile helm hooks to kots hooks")
-			}
-
-			baseFiles = append(baseFiles, baseFile)
-			continue
-		}
+	renderedPaths := []string{}
+	for k := range rendered {
+		renderedPaths = append(renderedPaths, k)
+	}
+	sort.Strings(renderedPaths)
 
-		for idx, fileString := range fileStrings {
-			filename := strings.TrimSuffix(k, filepath.Ext(k))
-			filename = fmt.Sprintf("%s-%d%s", filename, idx+1, filepath.Ext(k))
+	baseFiles := []BaseFile{}
+	for _, k := range renderedPaths {
+		baseFiles = append(baseFiles, BaseFile{
+			Path:    k,
+			Content: []byte(rendered[k]),
+		})
+	}
 
-			baseFile := BaseFile{
-				Path:    filename,
-				Content: []byte(fileString),
-			}
-			if err := baseFile.transpileHelmHooksToKotsHooks(); err != nil {
-				return nil, errors.Wrap(err, "failed to transpile helm hooks to kots hooks")
-			}
+	if renderOptions.SplitMultiDocYAML {
+		baseFiles = splitMultiDocYAML(baseFiles)
+	}
 
-			baseFiles = append(baseFiles, baseFile)
+	for i := range baseFiles {
+		if err := baseFiles[i].transpileHelmHooksToKotsHooks(); err != nil {
+			return nil, errors.Wrap(err, "failed to transpile helm hooks to kots hooks")
 		}
 	}
 
@@ -159,3 +127,105 @@ func RenderHelm(u *upstreamtypes.Upstream, renderOptions *RenderOptions) (*Base,
 		Files: baseFiles,
 	}, nil
 }
+
+// renderHelmV2 renders a chart with the helm 2 engine
+func renderHelmV2(chartPath string, releaseName string, vals map[string]interface{}, renderOptions *RenderOptions) (map[string]string, error) {
+	marshalledVals, err := yaml.Marshal(vals)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to marshal helm values")
+	}
+
+	config := &chart.Config{Raw: string(marshalledVals), Values: map[string]*chart.Value{}}
+
+	c, err := chartutil.Load(chartPath)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to load chart")
+	}
+
+	if req, err := chartutil.LoadRequirements(c); err == nil {
+		if err := renderutil.CheckDependencies(c, req); err != nil {
+			return nil, errors.Wrap(err, "failed to check dependencies")
+		}
+	} else if err != chartutil.ErrRequirementsNotFound {
+		return nil, errors.Wrap(err, "failed to load requirements")
+	}
+	if err := chartutil.ProcessRequirementsEnabled(c, config); err != nil {
+		return nil, errors.Wrap(err, "failed to process enabled dependencies")
+	}
+	if err := chartutil.ProcessRequirementsImportValues(c); err != nil {
+		return nil, errors.Wrap(err, "failed to process dependency import values")
+	}
+
+	capabilities, err := getHelmV2Capabilities(renderOptions.KubernetesVersion, renderOptions.APIVersions)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get capabilities")
+	}
+
+	releaseOptions := chartutil.ReleaseOptions{
+		Name:      releaseName,
+		IsInstall: true,
+		IsUpgrade: false,
+		Time:      timeconv.Now(),
+		Namespace: renderOptions.Namespace,
+	}
+	values, err := chartutil.ToRenderValuesCaps(c, config, releaseOptions, capabilities)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to build render values")
+	}
+
+	// Silence the go logger because helm will complain about some of our template strings
+	golog.SetOutput(ioutil.Discard)
+	defer golog.SetOutput(os.Stdout)
+	rendered, err := engine.New().Render(c, values)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to render chart")
+	}
+
+	return rendered, nil
+}
+
+// getHelmV2Capabilities returns .Capabilities for the target cluster. When the api versions
+// are not known, helm's default version set is used, as it is by "helm template".
+func getHelmV2Capabilities(kubeVersion string, apiVersions []string) (*chartutil.Capabilities, error) {
+	if kubeVersion == "" {
+		kubeVersion = template.DefaultKubernetesVersion
+	}
+	v, err := semver.NewVersion(kubeVersion)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to parse kubernetes version %q", kubeVersion)
+	}
+
+	capabilities := &chartutil.Capabilities{
+		APIVersions:   chartutil.DefaultVersionSet,
+		KubeVersion:   chartutil.DefaultKubeVersion,
+		TillerVersion: tversion.GetVersionProto(),
+	}
+	if len(apiVersions) > 0 {
+		capabilities.APIVersions = chartutil.NewVersionSet(apiVersions...)
+	}
+
+	kv := *capabilities.KubeVersion
+	kv.Major = fmt.Sprint(v.Major())
+	kv.Minor = fmt.Sprint(v.Minor())
+	kv.GitVersion = fmt.Sprintf("v%d.%d.%d", v.Major(), v.Minor(), v.Patch())
+	capabilities.KubeVersion = &kv
+
+	return capabilities, nil
+}
+
+// helmChartAPIVersion returns the apiVersion from Chart.yaml. Helm 2 charts may omit it.
+func helmChartAPIVersion(chartPath string) (string, error) {
+	content, err := ioutil.ReadFile(filepath.Join(chartPath, "Chart.yaml"))
+	if err != nil {
+		return "", errors.Wrap(err, "failed to read Chart.yaml")
+	}
+
+	chartAPIVersion := struct {
+		APIVersion string `json:"apiVersion"`
+	}{}
+	if err := yaml.Unmarshal(content, &chartAPIVersion); err != nil {
+		return "", errors.Wrap(err, "failed to unmarshal Chart.yaml")
+	}
+
+	return chartAPIVersion.APIVersion, nil
+}
@@ -0,0 +1,437 @@
+package base
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	golog "log"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	semver "github.com/Masterminds/semver/v3"
+	"github.com/ghodss/yaml"
+	"github.com/otiai10/copy"
+	"github.com/pkg/errors"
+	"github.com/replicatedhq/kots/pkg/template"
+	"k8s.io/helm/pkg/chartutil"
+	"k8s.io/helm/pkg/engine"
+	"k8s.io/helm/pkg/getter"
+	"k8s.io/helm/pkg/helm/environment"
+	"k8s.io/helm/pkg/proto/hapi/chart"
+	"k8s.io/helm/pkg/renderutil"
+	"k8s.io/helm/pkg/repo"
+	"k8s.io/helm/pkg/timeconv"
+	tversion "k8s.io/helm/pkg/version"
+)
+
+// helmV3Metadata is the Chart.yaml of an apiVersion v2 chart. The field names are the ones
+// helm 3 exposes to templates as .Chart
+type helmV3Metadata struct {
+	Name         string                  `json:"name"`
+	Home         string                  `json:"home,omitempty"`
+	Sources      []string                `json:"sources,omitempty"`
+	Version      string                  `json:"version"`
+	Description  string                  `json:"description,omitempty"`
+	Keywords     []string                `json:"keywords,omitempty"`
+	Maintainers  []helmV3Maintainer      `json:"maintainers,omitempty"`
+	Icon         string                  `json:"icon,omitempty"`
+	APIVersion   string                  `json:"apiVersion"`
+	Condition    string                  `json:"condition,omitempty"`
+	Tags         string                  `json:"tags,omitempty"`
+	AppVersion   string                  `json:"appVersion,omitempty"`
+	Deprecated   bool                    `json:"deprecated,omitempty"`
+	Annotations  map[string]string       `json:"annotations,omitempty"`
+	KubeVersion  string                  `json:"kubeVersion,omitempty"`
+	Dependencies []*chartutil.Dependency `json:"dependencies,omitempty"`
+	Type         string                  `json:"type,omitempty"`
+}
+
+type helmV3Maintainer struct {
+	Name  string `json:"name"`
+	Email string `json:"email,omitempty"`
+	URL   string `json:"url,omitempty"`
+}
+
+// helmV3Capabilities is .Capabilities as helm 3 exposes it to templates
+type helmV3Capabilities struct {
+	APIVersions chartutil.VersionSet
+	KubeVersion helmV3KubeVersion
+}
+
+type helmV3KubeVersion struct {
+	Version string
+	Major   string
+	Minor   string
+	// GitVersion is deprecated in helm 3, but still available to templates
+	GitVersion string
+}
+
+func (kv helmV3KubeVersion) String() string {
+	return kv.Version
+}
+
+// renderHelmV3 renders an apiVersion v2 chart with helm 3 semantics on top of the helm 2 engine.
+// Dependencies are read from Chart.yaml and vendored into charts/ when missing, library charts
+// don't produce any output, crds/ are included as is, and .Chart, .Capabilities and
+// .Release.Service match what helm 3 provides.
+func renderHelmV3(chartPath string, releaseName string, vals map[string]interface{}, renderOptions *RenderOptions) (map[string]string, error) {
+	metadata, err := loadHelmV3Metadata(filepath.Join(chartPath, "Chart.yaml"))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to load chart metadata")
+	}
+	if metadata.Type == "library" {
+		return nil, errors.Errorf("chart %s is a library chart and cannot be rendered", metadata.Name)
+	}
+
+	if err := vendorHelmV3Dependencies(chartPath, metadata.Dependencies); err != nil {
+		return nil, errors.Wrap(err, "failed to vendor dependencies")
+	}
+
+	// the helm 2 engine only knows about dependencies in requirements.yaml
+	if len(metadata.Dependencies) > 0 {
+		requirements, err := yaml.Marshal(chartutil.Requirements{Dependencies: metadata.Dependencies})
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to marshal requirements")
+		}
+		if err := ioutil.WriteFile(filepath.Join(chartPath, "requirements.yaml"), requirements, 0644); err != nil {
+			return nil, errors.Wrap(err, "failed to write requirements")
+		}
+	}
+
+	libraryCharts, err := findHelmV3LibraryCharts(chartPath, metadata.Dependencies)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to find library charts")
+	}
+
+	marshalledVals, err := yaml.Marshal(vals)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to marshal helm values")
+	}
+
+	config := &chart.Config{Raw: string(marshalledVals), Values: map[string]*chart.Value{}}
+
+	c, err := chartutil.Load(chartPath)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to load chart")
+	}
+
+	if req, err := chartutil.LoadRequirements(c); err == nil {
+		if err := renderutil.CheckDependencies(c, req); err != nil {
+			return nil, errors.Wrap(err, "failed to check dependencies")
+		}
+	} else if err != chartutil.ErrRequirementsNotFound {
+		return nil, errors.Wrap(err, "failed to load requirements")
+	}
+	if err := chartutil.ProcessRequirementsEnabled(c, config); err != nil {
+		return nil, errors.Wrap(err, "failed to process enabled dependencies")
+	}
+	if err := chartutil.ProcessRequirementsImportValues(c); err != nil {
+		return nil, errors.Wrap(err, "failed to process dependency import values")
+	}
+
+	capabilities, err := getHelmV3Capabilities(renderOptions.KubernetesVersion, renderOptions.APIVersions)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get capabilities")
+	}
+
+	releaseOptions := chartutil.ReleaseOptions{
+		Name:      releaseName,
+		IsInstall: true,
+		IsUpgrade: false,
+		Time:      timeconv.Now(),
+		Namespace: renderOptions.Namespace,
+		Revision:  1,
+	}
+	helm2Capabilities := &chartutil.Capabilities{
+		APIVersions:   capabilities.APIVersions,
+		KubeVersion:   chartutil.DefaultKubeVersion,
+		TillerVersion: tversion.GetVersionProto(),
+	}
+	values, err := chartutil.ToRenderValuesCaps(c, config, releaseOptions, helm2Capabilities)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to build render values")
+	}
+	values["Chart"] = metadata
+	values["Capabilities"] = capabilities
+	if release, ok := values["Release"].(map[string]interface{}); ok {
+		release["Service"] = "Helm"
+	}
+
+	// Silence the go logger because helm will complain about some of our template strings
+	golog.SetOutput(ioutil.Discard)
+	defer golog.SetOutput(os.Stdout)
+	rendered, err := engine.New().Render(c, values)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to render chart")
+	}
+
+	for k := range rendered {
+		// helm 3 never outputs notes when templating
+		if path.Base(k) == "NOTES.txt" {
+			delete(rendered, k)
+			continue
+		}
+
+		for _, libraryChart := range libraryCharts {
+			if strings.Contains(k, fmt.Sprintf("/charts/%s/templates/", libraryChart)) {
+				delete(rendered, k)
+				break
+			}
+		}
+	}
+
+	addHelmV3CRDs(c, "", rendered)
+
+	return rendered, nil
+}
+
+func loadHelmV3Metadata(filename string) (*helmV3Metadata, error) {
+	content, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read Chart.yaml")
+	}
+
+	return parseHelmV3Metadata(content)
+}
+
+func parseHelmV3Metadata(content []byte) (*helmV3Metadata, error) {
+	metadata := helmV3Metadata{}
+	if err := yaml.Unmarshal(content, &metadata); err != nil {
+		return nil, errors.Wrap(err, "failed to unmarshal Chart.yaml")
+	}
+
+	return &metadata, nil
+}
+
+// getHelmV3Capabilities returns .Capabilities for the target cluster. Like helm 3, the api versions
+// default to the ones known to the kubernetes client.
+func getHelmV3Capabilities(kubeVersion string, apiVersions []string) (*helmV3Capabilities, error) {
+	if kubeVersion == "" {
+		kubeVersion = template.DefaultKubernetesVersion
+	}
+	v, err := semver.NewVersion(kubeVersion)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to parse kubernetes version %q", kubeVersion)
+	}
+
+	if len(apiVersions) == 0 {
+		apiVersions = template.DefaultAPIVersions()
+	}
+
+	return &helmV3Capabilities{
+		APIVersions: chartutil.NewVersionSet(apiVersions...),
+		KubeVersion: helmV3KubeVersion{
+			Version:    fmt.Sprintf("v%d.%d.%d", v.Major(), v.Minor(), v.Patch()),
+			Major:      fmt.Sprint(v.Major()),
+			Minor:      fmt.Sprint(v.Minor()),
+			GitVersion: fmt.Sprintf("v%d.%d.%d", v.Major(), v.Minor(), v.Patch()),
+		},
+	}, nil
+}
+
+// vendorHelmV3Dependencies makes sure that every dependency declared in Chart.yaml is in charts/,
+// copying file:// dependencies and downloading the others from their chart repository
+func vendorHelmV3Dependencies(chartPath string, dependencies []*chartutil.Dependency) error {
+	if len(dependencies) == 0 {
+		return nil
+	}
+
+	chartsDir := filepath.Join(chartPath, "charts")
+	if err := os.MkdirAll(chartsDir, 0755); err != nil {
+		return errors.Wrap(err, "failed to create charts dir")
+	}
+
+	vendored, err := readVendoredHelmCharts(chartsDir)
+	if err != nil {
+		return errors.Wrap(err, "failed to read vendored charts")
+	}
+
+	for _, dependency := range dependencies {
+		if _, ok := vendored[dependency.Name]; ok {
+			continue
+		}
+
+		if strings.HasPrefix(dependency.Repository, "file://") {
+			src := strings.TrimPrefix(dependency.Repository, "file://")
+			if !filepath.IsAbs(src) {
+				src = filepath.Join(chartPath, src)
+			}
+			if _, err := os.Stat(src); err != nil {
+				return errors.Wrapf(err, "failed to find dependency %s", dependency.Name)
+			}
+			if err := copy.Copy(src, filepath.Join(chartsDir, dependency.Name)); err != nil {
+				return errors.Wrapf(err, "failed to copy dependency %s", dependency.Name)
+			}
+			continue
+		}
+
+		u, err := url.Parse(dependency.Repository)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			return errors.Errorf("dependency %s is not in charts/ and has unsupported repository %q", dependency.Name, dependency.Repository)
+		}
+
+		if err := downloadHelmV3Dependency(chartsDir, dependency); err != nil {
+			return errors.Wrapf(err, "failed to download dependency %s", dependency.Name)
+		}
+	}
+
+	return nil
+}
+
+func downloadHelmV3Dependency(chartsDir string, dependency *chartutil.Dependency) error {
+	getters := getter.All(environment.EnvSettings{})
+
+	chartURL, err := repo.FindChartInRepoURL(dependency.Repository, dependency.Name, dependency.Version, "", "", "", getters)
+	if err != nil {
+		return errors.Wrap(err, "failed to find chart in repo")
+	}
+
+	u, err := url.Parse(chartURL)
+	if err != nil {
+		return errors.Wrap(err, "failed to parse chart url")
+	}
+
+	getterConstructor, err := getters.ByScheme(u.Scheme)
+	if err != nil {
+		return errors.Wrapf(err, "failed to find getter for %s", u.Scheme)
+	}
+	g, err := getterConstructor(chartURL, "", "", "")
+	if err != nil {
+		return errors.Wrap(err, "failed to create getter")
+	}
+
+	data, err := g.Get(chartURL)
+	if err != nil {
+		return errors.Wrap(err, "failed to get chart archive")
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(chartsDir, path.Base(u.Path)), data.Bytes(), 0644); err != nil {
+		return errors.Wrap(err, "failed to write chart archive")
+	}
+
+	return nil
+}
+
+// readVendoredHelmCharts returns the metadata of all charts in charts/, both directories
+// and archives, keyed by chart name
+func readVendoredHelmCharts(chartsDir string) (map[string]*helmV3Metadata, error) {
+	vendored := map[string]*helmV3Metadata{}
+
+	entries, err := ioutil.ReadDir(chartsDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return vendored, nil
+		}
+		return nil, errors.Wrap(err, "failed to read charts dir")
+	}
+
+	for _, entry := range entries {
+		var content []byte
+		if entry.IsDir() {
+			content, err = ioutil.ReadFile(filepath.Join(chartsDir, entry.Name(), "Chart.yaml"))
+			if os.IsNotExist(err) {
+				continue
+			}
+		} else if strings.HasSuffix(entry.Name(), ".tgz") || strings.HasSuffix(entry.Name(), ".tar.gz") {
+			content, err = readChartYAMLFromArchive(filepath.Join(chartsDir, entry.Name()))
+		} else {
+			continue
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to read Chart.yaml from %s", entry.Name())
+		}
+
+		metadata, err := parseHelmV3Metadata(content)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to parse Chart.yaml from %s", entry.Name())
+		}
+		vendored[metadata.Name] = metadata
+	}
+
+	return vendored, nil
+}
+
+// findHelmV3LibraryCharts returns the names of the library charts in charts/, using the alias if there is one
+func findHelmV3LibraryCharts(chartPath string, dependencies []*chartutil.Dependency) ([]string, error) {
+	vendored, err := readVendoredHelmCharts(filepath.Join(chartPath, "charts"))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read vendored charts")
+	}
+
+	libraryCharts := []string{}
+	for name, metadata := range vendored {
+		if metadata.Type != "library" {
+			continue
+		}
+
+		libraryCharts = append(libraryCharts, name)
+		for _, dependency := range dependencies {
+			if dependency.Name == name && dependency.Alias != "" {
+				libraryCharts = append(libraryCharts, dependency.Alias)
+			}
+		}
+	}
+
+	return libraryCharts, nil
+}
+
+func readChartYAMLFromArchive(archivePath string) ([]byte, error) {
+	f, err := os.Open(archivePath)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to open archive")
+	}
+	defer f.Close()
+
+	gzf, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create gzip reader")
+	}
+
+	tarReader := tar.NewReader(gzf)
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to advance in tar archive")
+		}
+
+		// the chart is always in a single top level directory
+		parts := strings.Split(path.Clean(header.Name), "/")
+		if len(parts) == 2 && parts[1] == "Chart.yaml" {
+			return ioutil.ReadAll(tarReader)
+		}
+	}
+
+	return nil, errors.New("Chart.yaml not found in archive")
+}
+
+// addHelmV3CRDs adds the files in crds/ of the chart and its enabled dependencies to rendered,
+// using the same path layout as the rendered templates
+func addHelmV3CRDs(c *chart.Chart, parentID string, rendered map[string]string) {
+	chartID := c.Metadata.Name
+	if parentID != "" {
+		chartID = path.Join(parentID, "charts", chartID)
+	}
+
+	for _, f := range c.Files {
+		if !strings.HasPrefix(f.TypeUrl, "crds/") {
+			continue
+		}
+		ext := filepath.Ext(f.TypeUrl)
+		if ext != ".yaml" && ext != ".yml" && ext != ".json" {
+			continue
+		}
+		rendered[path.Join(chartID, f.TypeUrl)] = string(f.Value)
+	}
+
+	for _, dependency := range c.Dependencies {
+		addHelmV3CRDs(dependency, chartID, rendered)
+	}
+}
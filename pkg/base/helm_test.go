@@ -0,0 +1,256 @@
+package base
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/replicatedhq/kots/pkg/logger"
+	upstreamtypes "github.com/replicatedhq/kots/pkg/upstream/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_RenderHelm(t *testing.T) {
+	configMap := `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: {{ .Release.Name }}
+  namespace: {{ .Release.Namespace }}
+data:
+  value: {{ .Values.value | quote }}
+`
+
+	tests := []struct {
+		name          string
+		files         map[string]string
+		helmOptions   []string
+		expectedFiles map[string]string
+	}{
+		{
+			name: "helm 2 chart",
+			files: map[string]string{
+				"Chart.yaml":               "apiVersion: v1\nname: mychart\nversion: 0.1.0\n",
+				"values.yaml":              "value: default\n",
+				"templates/configmap.yaml": configMap,
+			},
+			helmOptions: []string{"value=set"},
+			expectedFiles: map[string]string{
+				"configmap.yaml": "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: myapp\n  namespace: test\ndata:\n  value: \"set\"\n",
+			},
+		},
+		{
+			name: "helm 3 chart has the same output",
+			files: map[string]string{
+				"Chart.yaml":               "apiVersion: v2\nname: mychart\nversion: 0.1.0\n",
+				"values.yaml":              "value: default\n",
+				"templates/configmap.yaml": configMap,
+			},
+			helmOptions: []string{"value=set"},
+			expectedFiles: map[string]string{
+				"configmap.yaml": "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: myapp\n  namespace: test\ndata:\n  value: \"set\"\n",
+			},
+		},
+		{
+			name: "helm 3 chart with library chart, disabled dependency and crds",
+			files: map[string]string{
+				"Chart.yaml": `apiVersion: v2
+name: mychart
+version: 0.1.0
+appVersion: 2.0.0
+dependencies:
+- name: common
+  version: 1.0.0
+  repository: https://charts.example.com
+- name: sub
+  version: 0.1.0
+  repository: https://charts.example.com
+  condition: sub.enabled
+`,
+				"values.yaml": "sub:\n  enabled: false\n",
+				"templates/info.yaml": `{{ include "common.name" . }}: {{ .Chart.APIVersion }} {{ .Chart.AppVersion }}
+service: {{ .Release.Service }}
+kubeVersion: {{ .Capabilities.KubeVersion.Version }}
+hasApps: {{ .Capabilities.APIVersions.Has "apps/v1" }}
+`,
+				"templates/NOTES.txt":                  "thanks for installing",
+				"crds/crd.yaml":                        "kind: CustomResourceDefinition\n",
+				"charts/common/Chart.yaml":             "apiVersion: v2\nname: common\nversion: 1.0.0\ntype: library\n",
+				"charts/common/templates/_helpers.tpl": `{{- define "common.name" -}}common{{- end -}}`,
+				"charts/common/templates/ignored.yaml": "kind: ShouldNotRender\n",
+				"charts/sub/Chart.yaml":                "apiVersion: v2\nname: sub\nversion: 0.1.0\n",
+				"charts/sub/templates/disabled.yaml":   "kind: ShouldNotRender\n",
+			},
+			expectedFiles: map[string]string{
+				"templates/info.yaml": "common: v2 2.0.0\nservice: Helm\nkubeVersion: v1.16.0\nhasApps: true\n",
+				"crds/crd.yaml":       "kind: CustomResourceDefinition\n",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := require.New(t)
+
+			u := &upstreamtypes.Upstream{
+				Name: "myapp",
+				Type: "helm",
+			}
+			for p, content := range test.files {
+				u.Files = append(u.Files, upstreamtypes.UpstreamFile{Path: p, Content: []byte(content)})
+			}
+
+			base, err := RenderHelm(u, &RenderOptions{
+				Namespace:   "test",
+				HelmOptions: test.helmOptions,
+				Log:         logger.NewLogger(),
+			})
+			req.NoError(err)
+
+			actual := map[string]string{}
+			for _, f := range base.Files {
+				actual[f.Path] = string(f.Content)
+			}
+			assert.Equal(t, test.expectedFiles, actual)
+		})
+	}
+}
+
+func Test_RenderHelmVendorsDependencies(t *testing.T) {
+	req := require.New(t)
+
+	var b bytes.Buffer
+	gw := gzip.NewWriter(&b)
+	tw := tar.NewWriter(gw)
+	for name, content := range map[string]string{
+		"sub/Chart.yaml":             "apiVersion: v2\nname: sub\nversion: 0.2.0\n",
+		"sub/templates/service.yaml": "kind: Service\nname: {{ .Values.name }}\n",
+	} {
+		req.NoError(tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(content)), Typeflag: tar.TypeReg}))
+		_, err := tw.Write([]byte(content))
+		req.NoError(err)
+	}
+	req.NoError(tw.Close())
+	req.NoError(gw.Close())
+
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/index.yaml":
+			fmt.Fprintf(w, `apiVersion: v1
+entries:
+  sub:
+  - name: sub
+    version: 0.1.0
+    urls:
+    - %[1]s/sub-0.1.0.tgz
+  - name: sub
+    version: 0.2.0
+    urls:
+    - %[1]s/sub-0.2.0.tgz
+`, server.URL)
+		case "/sub-0.2.0.tgz":
+			w.Write(b.Bytes())
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	u := &upstreamtypes.Upstream{
+		Name: "myapp",
+		Type: "helm",
+		Files: []upstreamtypes.UpstreamFile{
+			{
+				Path:    "Chart.yaml",
+				Content: []byte(fmt.Sprintf("apiVersion: v2\nname: mychart\nversion: 0.1.0\ndependencies:\n- name: sub\n  version: ^0.2.0\n  repository: %s\n", server.URL)),
+			},
+			{
+				Path:    "values.yaml",
+				Content: []byte("sub:\n  name: vendored\n"),
+			},
+		},
+	}
+
+	base, err := RenderHelm(u, &RenderOptions{Namespace: "test", Log: logger.NewLogger()})
+	req.NoError(err)
+	req.Len(base.Files, 1)
+	assert.Equal(t, "service.yaml", base.Files[0].Path)
+	assert.Equal(t, "kind: Service\nname: vendored\n", string(base.Files[0].Content))
+}
+
+func Test_RenderHelmCapabilities(t *testing.T) {
+	capabilities := `kubeVersion: {{ .Capabilities.KubeVersion.Major }}.{{ .Capabilities.KubeVersion.Minor }}
+hasIngressV1: {{ .Capabilities.APIVersions.Has "networking.k8s.io/v1" }}
+`
+
+	tests := []struct {
+		name              string
+		chartAPIVersion   string
+		kubernetesVersion string
+		apiVersions       []string
+		expected          string
+	}{
+		{
+			name:            "helm 2 chart with defaults",
+			chartAPIVersion: "v1",
+			expected:        "kubeVersion: 1.16\nhasIngressV1: false\n",
+		},
+		{
+			name:              "helm 2 chart",
+			chartAPIVersion:   "v1",
+			kubernetesVersion: "1.19.3",
+			apiVersions:       []string{"v1", "networking.k8s.io/v1"},
+			expected:          "kubeVersion: 1.19\nhasIngressV1: true\n",
+		},
+		{
+			name:              "helm 3 chart",
+			chartAPIVersion:   "v2",
+			kubernetesVersion: "1.19.3",
+			apiVersions:       []string{"v1", "networking.k8s.io/v1"},
+			expected:          "kubeVersion: 1.19\nhasIngressV1: true\n",
+		},
+		{
+			name:              "helm 3 chart without ingress v1",
+			chartAPIVersion:   "v2",
+			kubernetesVersion: "1.18.0",
+			apiVersions:       []string{"v1", "networking.k8s.io/v1beta1"},
+			expected:          "kubeVersion: 1.18\nhasIngressV1: false\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := require.New(t)
+
+			u := &upstreamtypes.Upstream{
+				Name: "myapp",
+				Type: "helm",
+				Files: []upstreamtypes.UpstreamFile{
+					{
+						Path:    "Chart.yaml",
+						Content: []byte(fmt.Sprintf("apiVersion: %s\nname: mychart\nversion: 0.1.0\n", test.chartAPIVersion)),
+					},
+					{
+						Path:    "templates/capabilities.yaml",
+						Content: []byte(capabilities),
+					},
+				},
+			}
+
+			base, err := RenderHelm(u, &RenderOptions{
+				Namespace:         "test",
+				KubernetesVersion: test.kubernetesVersion,
+				APIVersions:       test.apiVersions,
+				Log:               logger.NewLogger(),
+			})
+			req.NoError(err)
+			req.Len(base.Files, 1)
+			assert.Equal(t, test.expected, string(base.Files[0].Content))
+		})
+	}
+}
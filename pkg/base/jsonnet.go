@@ -0,0 +1,300 @@
+package base
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/ghodss/yaml"
+	"github.com/google/go-jsonnet"
+	"github.com/pkg/errors"
+	"github.com/replicatedhq/kots/pkg/template"
+	upstreamtypes "github.com/replicatedhq/kots/pkg/upstream/types"
+)
+
+// renderJsonnet evaluates the entrypoint of a jsonnet upstream, the same as
+// jsonnet -J vendor <entrypoint> would, and returns a file for each of the objects in the output
+func renderJsonnet(u *upstreamtypes.Upstream, renderOptions *RenderOptions) (*Base, error) {
+	evalDir, err := ioutil.TempDir("", "kots-jsonnet")
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create temp dir")
+	}
+	defer os.RemoveAll(evalDir)
+
+	for _, upstreamFile := range u.Files {
+		filename := filepath.Join(evalDir, upstreamFile.Path)
+		if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+			return nil, errors.Wrap(err, "failed to mkdir")
+		}
+		if err := upstreamFile.WriteFile(filename); err != nil {
+			return nil, errors.Wrapf(err, "failed to write %s", upstreamFile.Path)
+		}
+	}
+
+	baseFiles, err := evaluateJsonnetFile(evalDir, jsonnetEntrypoint(u.JsonnetEntrypoint), renderOptions.JsonnetTLAs, renderOptions.JsonnetExtVars)
+	if err != nil {
+		return nil, err
+	}
+
+	base := Base{
+		Files: baseFiles,
+	}
+
+	return &base, nil
+}
+
+// renderJsonnetFiles evaluates each of the .jsonnet files in a release. .libsonnet files, and the
+// files in vendor dirs, are only available to be imported.
+func renderJsonnetFiles(files []BaseFile, tlas []string, extVars []string) ([]BaseFile, error) {
+	evalDir, err := ioutil.TempDir("", "kots-jsonnet")
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create temp dir")
+	}
+	defer os.RemoveAll(evalDir)
+
+	for _, file := range files {
+		filename := filepath.Join(evalDir, file.Path)
+		if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+			return nil, errors.Wrap(err, "failed to mkdir")
+		}
+		if err := ioutil.WriteFile(filename, file.Content, 0644); err != nil {
+			return nil, errors.Wrapf(err, "failed to write %s", file.Path)
+		}
+	}
+
+	baseFiles := []BaseFile{}
+	for _, file := range files {
+		if path.Ext(file.Path) != ".jsonnet" || isJsonnetVendorFile(file.Path) {
+			continue
+		}
+
+		evaluated, err := evaluateJsonnetFile(evalDir, file.Path, tlas, extVars)
+		if err != nil {
+			return nil, err
+		}
+		baseFiles = append(baseFiles, evaluated...)
+	}
+
+	return baseFiles, nil
+}
+
+// isJsonnetVendorFile returns true for files in a vendor dir, such as the libraries and their
+// examples that jsonnet-bundler installs
+func isJsonnetVendorFile(p string) bool {
+	for _, dir := range strings.Split(path.Dir(p), "/") {
+		if dir == "vendor" {
+			return true
+		}
+	}
+	return false
+}
+
+func isJsonnetFile(p string) bool {
+	ext := path.Ext(p)
+	return ext == ".jsonnet" || ext == ".libsonnet"
+}
+
+// jsonnetEntrypoint is the file that's evaluated, main.jsonnet when the upstream names a directory
+func jsonnetEntrypoint(p string) string {
+	if path.Ext(p) == ".jsonnet" {
+		return p
+	}
+	return path.Join(p, "main.jsonnet")
+}
+
+// configItemsToJsonnetExtVars makes each config item available to jsonnet as
+// std.extVar("<item name>"), with the same value that ConfigOption returns in templates
+func configItemsToJsonnetExtVars(itemValues map[string]template.ItemValue) []string {
+	extVars := []string{}
+	for name, itemValue := range itemValues {
+		value := itemValue.DefaultStr()
+		if itemValue.HasValue() {
+			value = itemValue.ValueStr()
+		}
+		extVars = append(extVars, fmt.Sprintf("%s=%s", name, value))
+	}
+	sort.Strings(extVars)
+
+	return extVars
+}
+
+// evaluateJsonnetFile evaluates a file in dir and returns the objects that it outputs. Imports are
+// resolved relative to the importing file, then in the vendor dirs next to the file and at the root.
+func evaluateJsonnetFile(dir string, filePath string, tlas []string, extVars []string) ([]BaseFile, error) {
+	vm := jsonnet.MakeVM()
+	vm.Importer(&jsonnet.FileImporter{
+		JPaths: []string{
+			filepath.Join(dir, filepath.Dir(filePath), "vendor"),
+			filepath.Join(dir, "vendor"),
+		},
+	})
+
+	for _, tla := range tlas {
+		name, value, err := parseJsonnetVar(tla)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to parse top level arg")
+		}
+		vm.TLAVar(name, value)
+	}
+	for _, extVar := range extVars {
+		name, value, err := parseJsonnetVar(extVar)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to parse ext var")
+		}
+		vm.ExtVar(name, value)
+	}
+
+	filename := filepath.Join(dir, filePath)
+	snippet, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read %s", filePath)
+	}
+
+	output, err := vm.EvaluateSnippet(filename, string(snippet))
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to evaluate %s", filePath)
+	}
+
+	baseFiles, err := jsonnetOutputToBaseFiles(filePath, output)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read output of %s", filePath)
+	}
+
+	return baseFiles, nil
+}
+
+// parseJsonnetVar parses a name=value top level arg or ext var
+func parseJsonnetVar(v string) (string, string, error) {
+	parts := strings.SplitN(v, "=", 2)
+	if len(parts) != 2 || parts[0] == "" {
+		return "", "", errors.Errorf("expected name=value, got %q", v)
+	}
+	return parts[0], parts[1], nil
+}
+
+type jsonnetManifest struct {
+	Path   string
+	Object map[string]interface{}
+}
+
+// jsonnetOutputToBaseFiles splits the output of a jsonnet file into a file for each object. An
+// object of manifests, such as the output of kube-prometheus, has a file for each field, named for
+// the field. Arrays and Lists are split into a file for each object, the way multi doc yaml is split.
+func jsonnetOutputToBaseFiles(filePath string, output string) ([]BaseFile, error) {
+	var value interface{}
+	if err := json.Unmarshal([]byte(output), &value); err != nil {
+		return nil, errors.Wrap(err, "failed to unmarshal output")
+	}
+
+	dir := path.Dir(filePath)
+	name := strings.TrimSuffix(path.Base(filePath), path.Ext(filePath))
+
+	var manifests []jsonnetManifest
+	var err error
+	if object, ok := value.(map[string]interface{}); ok && !isKubernetesObject(object) {
+		manifests, err = collectJsonnetManifests(dir, object)
+	} else {
+		manifests, err = collectJsonnetManifests(path.Join(dir, name), value)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	counts := map[string]int{}
+	for _, manifest := range manifests {
+		counts[manifest.Path]++
+	}
+
+	baseFiles := []BaseFile{}
+	indexes := map[string]int{}
+	usedPaths := map[string]bool{}
+	for _, manifest := range manifests {
+		filename := manifest.Path + ".yaml"
+		if counts[manifest.Path] > 1 {
+			kind, _ := manifest.Object["kind"].(string)
+			name := ""
+			if metadata, ok := manifest.Object["metadata"].(map[string]interface{}); ok {
+				name, _ = metadata["name"].(string)
+			}
+			filename = splitResourcePath(manifest.Path, ".yaml", indexes[manifest.Path], kind, name, usedPaths)
+			indexes[manifest.Path]++
+		}
+
+		content, err := yaml.Marshal(manifest.Object)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to marshal %s", manifest.Path)
+		}
+
+		baseFiles = append(baseFiles, BaseFile{
+			Path:    filename,
+			Content: content,
+		})
+	}
+
+	return baseFiles, nil
+}
+
+func collectJsonnetManifests(p string, value interface{}) ([]jsonnetManifest, error) {
+	if p == ".." || strings.HasPrefix(p, "../") || path.IsAbs(p) {
+		return nil, errors.Errorf("output path %s is outside of the base", p)
+	}
+
+	switch v := value.(type) {
+	case nil:
+		// conditional objects are commonly null when they're disabled
+		return nil, nil
+
+	case []interface{}:
+		manifests := []jsonnetManifest{}
+		for _, item := range v {
+			itemManifests, err := collectJsonnetManifests(p, item)
+			if err != nil {
+				return nil, err
+			}
+			manifests = append(manifests, itemManifests...)
+		}
+		return manifests, nil
+
+	case map[string]interface{}:
+		if isKubernetesObject(v) {
+			if items, ok := v["items"].([]interface{}); ok && strings.HasSuffix(v["kind"].(string), "List") {
+				return collectJsonnetManifests(p, items)
+			}
+			return []jsonnetManifest{{Path: p, Object: v}}, nil
+		}
+
+		keys := []string{}
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		manifests := []jsonnetManifest{}
+		for _, key := range keys {
+			keyPath := path.Join(p, strings.TrimSuffix(key, path.Ext(key)))
+			if ext := path.Ext(key); ext != ".yaml" && ext != ".yml" && ext != ".json" {
+				keyPath = path.Join(p, key)
+			}
+
+			keyManifests, err := collectJsonnetManifests(keyPath, v[key])
+			if err != nil {
+				return nil, err
+			}
+			manifests = append(manifests, keyManifests...)
+		}
+		return manifests, nil
+	}
+
+	return nil, errors.Errorf("unexpected %T in output at %s, expected kubernetes objects", value, p)
+}
+
+func isKubernetesObject(object map[string]interface{}) bool {
+	_, hasAPIVersion := object["apiVersion"].(string)
+	_, hasKind := object["kind"].(string)
+	return hasAPIVersion && hasKind
+}
@@ -0,0 +1,263 @@
+package base
+
+import (
+	"encoding/base64"
+	"testing"
+
+	"github.com/replicatedhq/kots/pkg/crypto"
+	"github.com/replicatedhq/kots/pkg/logger"
+	upstreamtypes "github.com/replicatedhq/kots/pkg/upstream/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_jsonnetOutputToBaseFiles(t *testing.T) {
+	tests := []struct {
+		name      string
+		filePath  string
+		output    string
+		expected  map[string]string
+		expectErr bool
+	}{
+		{
+			name:     "single object",
+			filePath: "app/main.jsonnet",
+			output:   `{"apiVersion": "v1", "kind": "ConfigMap", "metadata": {"name": "a"}}`,
+			expected: map[string]string{
+				"app/main.yaml": "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: a\n",
+			},
+		},
+		{
+			name:     "array and list are split",
+			filePath: "main.jsonnet",
+			output: `[
+				{"apiVersion": "v1", "kind": "ConfigMap", "metadata": {"name": "a"}},
+				{"apiVersion": "v1", "kind": "List", "items": [{"apiVersion": "v1", "kind": "Secret", "metadata": {"name": "b"}}]},
+				null
+			]`,
+			expected: map[string]string{
+				"main-configmap-a.yaml": "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: a\n",
+				"main-secret-b.yaml":    "apiVersion: v1\nkind: Secret\nmetadata:\n  name: b\n",
+			},
+		},
+		{
+			name:     "object of manifests",
+			filePath: "main.jsonnet",
+			output: `{
+				"setup/namespace": {"apiVersion": "v1", "kind": "Namespace", "metadata": {"name": "monitoring"}},
+				"grafana-service.yaml": {"apiVersion": "v1", "kind": "Service", "metadata": {"name": "grafana"}},
+				"disabled": null
+			}`,
+			expected: map[string]string{
+				"setup/namespace.yaml": "apiVersion: v1\nkind: Namespace\nmetadata:\n  name: monitoring\n",
+				"grafana-service.yaml": "apiVersion: v1\nkind: Service\nmetadata:\n  name: grafana\n",
+			},
+		},
+		{
+			name:      "not an object",
+			filePath:  "main.jsonnet",
+			output:    `{"replicas": 3}`,
+			expectErr: true,
+		},
+		{
+			name:      "outside of base",
+			filePath:  "main.jsonnet",
+			output:    `{"../escape": {"apiVersion": "v1", "kind": "ConfigMap", "metadata": {"name": "a"}}}`,
+			expectErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := require.New(t)
+
+			baseFiles, err := jsonnetOutputToBaseFiles(test.filePath, test.output)
+			if test.expectErr {
+				req.Error(err)
+				return
+			}
+			req.NoError(err)
+
+			actual := map[string]string{}
+			for _, baseFile := range baseFiles {
+				actual[baseFile.Path] = string(baseFile.Content)
+			}
+			assert.Equal(t, test.expected, actual)
+		})
+	}
+}
+
+func Test_renderJsonnet(t *testing.T) {
+	req := require.New(t)
+
+	u := &upstreamtypes.Upstream{
+		Name:              "myapp",
+		Type:              "jsonnet",
+		JsonnetEntrypoint: "example",
+		Files: []upstreamtypes.UpstreamFile{
+			{
+				Path: "example/main.jsonnet",
+				Content: []byte(`local configmap = import 'configmap.libsonnet';
+function(replicas='1') {
+  configmap: configmap.new(std.extVar('name'), replicas),
+}
+`),
+			},
+			{
+				Path: "vendor/configmap.libsonnet",
+				Content: []byte(`{
+  new(name, replicas):: {apiVersion: 'v1', kind: 'ConfigMap', metadata: {name: name}, data: {replicas: replicas}},
+}
+`),
+			},
+		},
+	}
+
+	base, err := RenderUpstream(u, &RenderOptions{
+		JsonnetTLAs:    []string{"replicas=3"},
+		JsonnetExtVars: []string{"name=from-ext-var"},
+		Log:            logger.NewLogger(),
+	})
+	req.NoError(err)
+	req.Len(base.Files, 1)
+	assert.Equal(t, "example/configmap.yaml", base.Files[0].Path)
+	assert.Equal(t, "apiVersion: v1\ndata:\n  replicas: \"3\"\nkind: ConfigMap\nmetadata:\n  name: from-ext-var\n", string(base.Files[0].Content))
+}
+
+func Test_renderReplicatedJsonnet(t *testing.T) {
+	req := require.New(t)
+
+	u := &upstreamtypes.Upstream{
+		Name: "myapp",
+		Type: "replicated",
+		Files: []upstreamtypes.UpstreamFile{
+			{
+				Path: "userdata/config.yaml",
+				Content: []byte(`apiVersion: kots.io/v1beta1
+kind: ConfigValues
+metadata:
+  name: myapp
+spec:
+  values:
+    hostname:
+      value: app.example.com
+`),
+			},
+			{
+				Path: "ingress.jsonnet",
+				Content: []byte(`local lib = import 'lib.libsonnet';
+lib.ingress(std.extVar('hostname'))
+`),
+			},
+			{
+				Path:    "lib.libsonnet",
+				Content: []byte(`{ ingress(host):: {apiVersion: 'extensions/v1beta1', kind: 'Ingress', metadata: {name: 'app'}, spec: {rules: [{host: host}]}} }`),
+			},
+		},
+	}
+
+	base, err := renderReplicated(u, &RenderOptions{Log: logger.NewLogger()})
+	req.NoError(err)
+
+	actual := map[string]string{}
+	for _, f := range base.Files {
+		actual[f.Path] = string(f.Content)
+	}
+	assert.NotContains(t, actual, "ingress.jsonnet")
+	assert.NotContains(t, actual, "lib.libsonnet")
+	assert.Equal(t, "apiVersion: extensions/v1beta1\nkind: Ingress\nmetadata:\n  name: app\nspec:\n  rules:\n  - host: app.example.com\n", actual["ingress.yaml"])
+}
+
+func Test_renderReplicatedJsonnetSkipsVendor(t *testing.T) {
+	req := require.New(t)
+
+	u := &upstreamtypes.Upstream{
+		Name: "myapp",
+		Type: "replicated",
+		Files: []upstreamtypes.UpstreamFile{
+			{
+				Path: "app.jsonnet",
+				Content: []byte(`local kube = import 'kube/kube.libsonnet';
+kube.configMap('app')
+`),
+			},
+			{
+				Path:    "vendor/kube/kube.libsonnet",
+				Content: []byte(`{ configMap(name):: {apiVersion: 'v1', kind: 'ConfigMap', metadata: {name: name}} }`),
+			},
+			{
+				Path:    "vendor/kube/examples/example.jsonnet",
+				Content: []byte(`error 'examples are not evaluated'`),
+			},
+		},
+	}
+
+	base, err := renderReplicated(u, &RenderOptions{Log: logger.NewLogger()})
+	req.NoError(err)
+
+	actual := map[string]string{}
+	for _, f := range base.Files {
+		actual[f.Path] = string(f.Content)
+	}
+	assert.Equal(t, map[string]string{
+		"app.yaml": "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: app\n",
+	}, actual)
+}
+
+func Test_renderReplicatedJsonnetConfigItems(t *testing.T) {
+	req := require.New(t)
+
+	cipher, err := crypto.NewAESCipher()
+	req.NoError(err)
+	encryptedPassword := base64.StdEncoding.EncodeToString(cipher.Encrypt([]byte("hunter2")))
+
+	u := &upstreamtypes.Upstream{
+		Name:          "myapp",
+		Type:          "replicated",
+		EncryptionKey: cipher.ToString(),
+		Files: []upstreamtypes.UpstreamFile{
+			{
+				Path: "config.yaml",
+				Content: []byte(`apiVersion: kots.io/v1beta1
+kind: Config
+metadata:
+  name: myapp
+spec:
+  groups:
+  - name: database
+    items:
+    - name: db_password
+      type: password
+    - name: db_user
+      type: text
+      default: '{{repl printf "%s-user" "app" }}'
+`),
+			},
+			{
+				Path: "userdata/config.yaml",
+				Content: []byte(`apiVersion: kots.io/v1beta1
+kind: ConfigValues
+metadata:
+  name: myapp
+spec:
+  values:
+    db_password:
+      value: ` + encryptedPassword + `
+`),
+			},
+			{
+				Path:    "secret.jsonnet",
+				Content: []byte(`{apiVersion: 'v1', kind: 'Secret', metadata: {name: 'db'}, stringData: {user: std.extVar('db_user'), password: std.extVar('db_password')}}`),
+			},
+		},
+	}
+
+	base, err := renderReplicated(u, &RenderOptions{Log: logger.NewLogger()})
+	req.NoError(err)
+
+	actual := map[string]string{}
+	for _, f := range base.Files {
+		actual[f.Path] = string(f.Content)
+	}
+	assert.Equal(t, "apiVersion: v1\nkind: Secret\nmetadata:\n  name: db\nstringData:\n  password: hunter2\n  user: app-user\n", actual["secret.yaml"])
+}
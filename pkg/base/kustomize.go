@@ -0,0 +1,99 @@
+package base
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+	upstreamtypes "github.com/replicatedhq/kots/pkg/upstream/types"
+	"sigs.k8s.io/kustomize/v3/k8sdeps/kunstruct"
+	"sigs.k8s.io/kustomize/v3/k8sdeps/transformer"
+	"sigs.k8s.io/kustomize/v3/k8sdeps/validator"
+	"sigs.k8s.io/kustomize/v3/pkg/fs"
+	"sigs.k8s.io/kustomize/v3/pkg/loader"
+	"sigs.k8s.io/kustomize/v3/pkg/plugins"
+	"sigs.k8s.io/kustomize/v3/pkg/resmap"
+	"sigs.k8s.io/kustomize/v3/pkg/resource"
+	"sigs.k8s.io/kustomize/v3/pkg/target"
+)
+
+// renderKustomize builds the kustomization of a kustomize upstream, the same as kustomize build
+// would, and returns a file for each of the resources in the output
+func renderKustomize(u *upstreamtypes.Upstream, renderOptions *RenderOptions) (*Base, error) {
+	buildDir, err := ioutil.TempDir("", "kots-kustomize")
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create temp dir")
+	}
+	defer os.RemoveAll(buildDir)
+
+	for _, upstreamFile := range u.Files {
+		filename := filepath.Join(buildDir, upstreamFile.Path)
+		if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+			return nil, errors.Wrap(err, "failed to mkdir")
+		}
+		if err := upstreamFile.WriteFile(filename); err != nil {
+			return nil, errors.Wrapf(err, "failed to write %s", upstreamFile.Path)
+		}
+	}
+
+	resMap, err := KustomizeBuild(filepath.Join(buildDir, u.KustomizationDir))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to build kustomization")
+	}
+
+	baseFiles := []BaseFile{}
+	usedPaths := map[string]bool{}
+	for _, res := range resMap.Resources() {
+		content, err := res.AsYAML()
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to marshal %s", res.CurId())
+		}
+
+		baseFile := BaseFile{
+			Path:    kustomizeResourcePath(res.GetKind(), res.GetName(), usedPaths),
+			Content: content,
+		}
+		baseFiles = append(baseFiles, baseFile)
+	}
+
+	base := Base{
+		Files: baseFiles,
+	}
+
+	return &base, nil
+}
+
+// KustomizeBuild builds the kustomization in kustomizationDir in-process, with the same version of
+// kustomize that the kustomizations in the base and overlays are written for
+func KustomizeBuild(kustomizationDir string) (resmap.ResMap, error) {
+	fSys := fs.MakeRealFS()
+
+	ldr, err := loader.NewLoader(loader.RestrictionRootOnly, validator.NewKustValidator(), kustomizationDir, fSys)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create loader")
+	}
+	defer ldr.Cleanup()
+
+	uf := kunstruct.NewKunstructuredFactoryImpl()
+	pf := transformer.NewFactoryImpl()
+	rf := resmap.NewFactory(resource.NewFactory(uf), pf)
+
+	kt, err := target.NewKustTarget(ldr, rf, pf, plugins.NewLoader(plugins.DefaultPluginConfig(), rf))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to load kustomization")
+	}
+
+	resMap, err := kt.MakeCustomizedResMap()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to build resources")
+	}
+
+	return resMap, nil
+}
+
+// kustomizeResourcePath names the file for a resource by its kind and name, with a suffix
+// when the same kind and name is used in more than one namespace
+func kustomizeResourcePath(kind string, name string, usedPaths map[string]bool) string {
+	return splitResourcePath("", ".yaml", 0, kind, name, usedPaths)
+}
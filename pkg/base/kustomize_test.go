@@ -0,0 +1,57 @@
+package base
+
+import (
+	"testing"
+
+	upstreamtypes "github.com/replicatedhq/kots/pkg/upstream/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_renderKustomize(t *testing.T) {
+	req := require.New(t)
+
+	u := &upstreamtypes.Upstream{
+		Name: "repo",
+		Type: "kustomize",
+		Files: []upstreamtypes.UpstreamFile{
+			{
+				Path:    "base/kustomization.yaml",
+				Content: []byte("resources:\n- deployment.yaml\n- service.yaml\n"),
+			},
+			{
+				Path:    "base/deployment.yaml",
+				Content: []byte("apiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: web\nspec:\n  template:\n    spec:\n      containers:\n      - name: web\n        image: nginx:1.17\n"),
+			},
+			{
+				Path:    "base/service.yaml",
+				Content: []byte("apiVersion: v1\nkind: Service\nmetadata:\n  name: web\n"),
+			},
+			{
+				Path:    "config/default/kustomization.yaml",
+				Content: []byte("bases:\n- ../../base\nnamePrefix: prod-\n"),
+			},
+		},
+		KustomizationDir: "config/default",
+	}
+
+	base, err := renderKustomize(u, &RenderOptions{})
+	req.NoError(err)
+
+	actual := map[string]string{}
+	for _, file := range base.Files {
+		actual[file.Path] = string(file.Content)
+	}
+	req.Len(actual, 2)
+	assert.Contains(t, actual["deployment-prod-web.yaml"], "name: prod-web")
+	assert.Contains(t, actual["deployment-prod-web.yaml"], "image: nginx:1.17")
+	assert.Contains(t, actual["service-prod-web.yaml"], "name: prod-web")
+}
+
+func Test_kustomizeResourcePath(t *testing.T) {
+	usedPaths := map[string]bool{}
+
+	assert.Equal(t, "configmap-config.yaml", kustomizeResourcePath("ConfigMap", "config", usedPaths))
+	assert.Equal(t, "configmap-config-1.yaml", kustomizeResourcePath("ConfigMap", "config", usedPaths))
+	assert.Equal(t, "service-config.yaml", kustomizeResourcePath("Service", "config", usedPaths))
+}
@@ -0,0 +1,41 @@
+package base
+
+import (
+	"path"
+
+	"github.com/pkg/errors"
+	upstreamtypes "github.com/replicatedhq/kots/pkg/upstream/types"
+)
+
+// renderPlain renders upstreams that are already a directory of kubernetes manifests.
+// There are no templates to evaluate in these, so the files are passed through as-is.
+func renderPlain(u *upstreamtypes.Upstream, renderOptions *RenderOptions) (*Base, error) {
+	baseFiles := []BaseFile{}
+
+	for _, upstreamFile := range u.Files {
+		// kots writes its own kustomization in the base, an upstream
+		// kustomization would be included as a resource otherwise
+		_, filename := path.Split(upstreamFile.Path)
+		if filename == "kustomization.yaml" || filename == "kustomization.yml" || filename == "Kustomization" {
+			continue
+		}
+
+		content, err := upstreamFile.ReadContent()
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to read %s", upstreamFile.Path)
+		}
+
+		baseFile := BaseFile{
+			Path:    upstreamFile.Path,
+			Content: content,
+		}
+
+		baseFiles = append(baseFiles, baseFile)
+	}
+
+	base := Base{
+		Files: baseFiles,
+	}
+
+	return &base, nil
+}
@@ -0,0 +1,52 @@
+package base
+
+import (
+	"sync"
+
+	upstreamtypes "github.com/replicatedhq/kots/pkg/upstream/types"
+)
+
+// Renderer converts an upstream of a single type into a kubernetes base
+type Renderer interface {
+	Render(u *upstreamtypes.Upstream, renderOptions *RenderOptions) (*Base, error)
+}
+
+// RendererFunc adapts a function to a Renderer
+type RendererFunc func(u *upstreamtypes.Upstream, renderOptions *RenderOptions) (*Base, error)
+
+func (f RendererFunc) Render(u *upstreamtypes.Upstream, renderOptions *RenderOptions) (*Base, error) {
+	return f(u, renderOptions)
+}
+
+var (
+	renderersMu sync.RWMutex
+	renderers   = map[string]Renderer{
+		"helm":       RendererFunc(RenderHelm),
+		"replicated": RendererFunc(renderReplicated),
+		"kustomize":  RendererFunc(renderKustomize),
+		"jsonnet":    RendererFunc(renderJsonnet),
+		"git":        RendererFunc(renderPlain),
+		"http":       RendererFunc(renderPlain),
+		"s3":         RendererFunc(renderPlain),
+		"local":      RendererFunc(renderPlain),
+	}
+)
+
+// RegisterRenderer makes a renderer available for upstreams of upstreamType. Registering
+// a renderer for a type that already has one replaces it.
+func RegisterRenderer(upstreamType string, renderer Renderer) {
+	if renderer == nil {
+		panic("base: RegisterRenderer renderer is nil")
+	}
+
+	renderersMu.Lock()
+	defer renderersMu.Unlock()
+	renderers[upstreamType] = renderer
+}
+
+func rendererForType(upstreamType string) (Renderer, bool) {
+	renderersMu.RLock()
+	defer renderersMu.RUnlock()
+	renderer, ok := renderers[upstreamType]
+	return renderer, ok
+}
@@ -0,0 +1,43 @@
+package base
+
+import (
+	"testing"
+
+	"github.com/replicatedhq/kots/pkg/logger"
+	upstreamtypes "github.com/replicatedhq/kots/pkg/upstream/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_RegisterRenderer(t *testing.T) {
+	req := require.New(t)
+
+	u := &upstreamtypes.Upstream{
+		Name: "myapp",
+		Type: "custom",
+		Files: []upstreamtypes.UpstreamFile{
+			{Path: "app.custom", Content: []byte("custom")},
+		},
+	}
+
+	_, err := RenderUpstream(u, &RenderOptions{Log: logger.NewLogger()})
+	req.Error(err)
+
+	RegisterRenderer("custom", RendererFunc(func(u *upstreamtypes.Upstream, renderOptions *RenderOptions) (*Base, error) {
+		return &Base{
+			Files: []BaseFile{
+				{Path: "configmap.yaml", Content: []byte("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: " + string(u.Files[0].Content) + "\n")},
+			},
+		}, nil
+	}))
+	defer func() {
+		renderersMu.Lock()
+		delete(renderers, "custom")
+		renderersMu.Unlock()
+	}()
+
+	base, err := RenderUpstream(u, &RenderOptions{Log: logger.NewLogger()})
+	req.NoError(err)
+	req.Len(base.Files, 1)
+	assert.Equal(t, "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: custom\n", string(base.Files[0].Content))
+}
@@ -10,19 +10,31 @@ type RenderOptions struct {
 	SplitMultiDocYAML bool
 	Namespace         string
 	HelmOptions       []string
+	// JsonnetTLAs and JsonnetExtVars are name=value top level args and ext vars for jsonnet
+	JsonnetTLAs       []string
+	JsonnetExtVars    []string
+	KubernetesVersion string
+	APIVersions       []string
 	Log               *logger.Logger
 }
 
 // RenderUpstream is responsible for any conversions or transpilation steps are required
-// to take an upstream and make it a valid kubernetes base
+// to take an upstream and make it a valid kubernetes base. The renderer registered for
+// the upstream type does the work.
 func RenderUpstream(u *upstreamtypes.Upstream, renderOptions *RenderOptions) (*Base, error) {
-	if u.Type == "helm" {
-		return RenderHelm(u, renderOptions)
+	renderer, ok := rendererForType(u.Type)
+	if !ok {
+		return nil, errors.Errorf("unknown upstream type %q", u.Type)
 	}
 
-	if u.Type == "replicated" {
-		return renderReplicated(u, renderOptions)
+	base, err := renderer.Render(u, renderOptions)
+	if err != nil {
+		return nil, err
 	}
 
-	return nil, errors.New("unknown upstream type")
+	if renderOptions.SplitMultiDocYAML {
+		base.Files = splitMultiDocYAML(base.Files)
+	}
+
+	return base, nil
 }
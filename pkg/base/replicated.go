@@ -25,7 +25,10 @@ import (
 )
 
 func renderReplicated(u *upstreamtypes.Upstream, renderOptions *RenderOptions) (*Base, error) {
-	config, configValues, license := findConfig(u, renderOptions.Log)
+	config, configValues, license, err := findConfig(u, renderOptions.Log)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to find config")
+	}
 
 	var templateContext map[string]template.ItemValue
 	if configValues != nil {
@@ -54,13 +57,21 @@ func renderReplicated(u *upstreamtypes.Upstream, renderOptions *RenderOptions) (
 
 	builder := template.Builder{}
 	builder.AddCtx(template.StaticCtx{})
-
+	builder.AddCtx(template.ClusterCtx{
+		KubernetesVersion: renderOptions.KubernetesVersion,
+		APIVersions:       renderOptions.APIVersions,
+	})
+
+	// itemValues are the values of the config items as templates see them, with passwords
+	// decrypted and defaults from the config rendered
+	itemValues := templateContext
 	if config != nil {
 		configCtx, err := builder.NewConfigContext(config.Spec.Groups, templateContext, cipher)
 		if err != nil {
 			return nil, errors.Wrap(err, "failed to create config context")
 		}
 		builder.AddCtx(configCtx)
+		itemValues = configCtx.ItemValues
 	}
 
 	if license != nil {
@@ -72,7 +83,10 @@ func renderReplicated(u *upstreamtypes.Upstream, renderOptions *RenderOptions) (
 
 	// render helm charts that were specified
 	// we just inject them into u.Files
-	kotsHelmCharts := findAllKotsHelmCharts(u.Files)
+	kotsHelmCharts, err := findAllKotsHelmCharts(u.Files)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to find helm charts")
+	}
 	for _, kotsHelmChart := range kotsHelmCharts {
 		if kotsHelmChart.Spec.Exclude != "" {
 			renderedExclude, err := builder.RenderTemplate(kotsHelmChart.Name, kotsHelmChart.Spec.Exclude)
@@ -98,16 +112,7 @@ func renderReplicated(u *upstreamtypes.Upstream, renderOptions *RenderOptions) (
 			return nil, errors.Wrap(err, "failed to find helm chart archive in release")
 		}
 
-		tmpFile, err := ioutil.TempFile("", "kots")
-		if err != nil {
-			return nil, errors.Wrap(err, "failed to create temp file")
-		}
-		_, err = io.Copy(tmpFile, bytes.NewReader(archive))
-		if err != nil {
-			return nil, errors.Wrap(err, "failed to copy chart to temp file")
-		}
-
-		helmUpstream, err := chartArchiveToSparseUpstream(tmpFile.Name())
+		helmUpstream, err := upstreamFileToSparseUpstream(archive)
 		if err != nil {
 			return nil, errors.Wrap(err, "failed to fetch helm dependency")
 		}
@@ -176,10 +181,21 @@ func renderReplicated(u *upstreamtypes.Upstream, renderOptions *RenderOptions) (
 		}
 	}
 
+	jsonnetFiles := []BaseFile{}
 	for _, upstreamFile := range u.Files {
-		rendered, err := builder.RenderTemplate(upstreamFile.Path, string(upstreamFile.Content))
+		// archives are only read to render the charts above, and are not part of the base
+		if upstreamFile.LocalPath != "" && isHelmChartFile(upstreamFile) {
+			continue
+		}
+
+		content, err := upstreamFile.ReadContent()
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to read %s", upstreamFile.Path)
+		}
+
+		rendered, err := builder.RenderTemplate(upstreamFile.Path, string(content))
 		if err != nil {
-			renderOptions.Log.Error(errors.Errorf("Failed to render file %s. Contents are %s", upstreamFile.Path, upstreamFile.Content))
+			renderOptions.Log.Error(errors.Errorf("Failed to render file %s. Contents are %s", upstreamFile.Path, content))
 			return nil, errors.Wrap(err, "failed to render file template")
 		}
 
@@ -188,9 +204,24 @@ func renderReplicated(u *upstreamtypes.Upstream, renderOptions *RenderOptions) (
 			Content: []byte(rendered),
 		}
 
+		// jsonnet is evaluated after all of the files are rendered, since they can import each other
+		if isJsonnetFile(upstreamFile.Path) {
+			jsonnetFiles = append(jsonnetFiles, baseFile)
+			continue
+		}
+
 		baseFiles = append(baseFiles, baseFile)
 	}
 
+	if len(jsonnetFiles) > 0 {
+		extVars := append(configItemsToJsonnetExtVars(itemValues), renderOptions.JsonnetExtVars...)
+		jsonnetBaseFiles, err := renderJsonnetFiles(jsonnetFiles, renderOptions.JsonnetTLAs, extVars)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to render jsonnet")
+		}
+		baseFiles = append(baseFiles, jsonnetBaseFiles...)
+	}
+
 	base := Base{
 		Files: baseFiles,
 	}
@@ -198,16 +229,30 @@ func renderReplicated(u *upstreamtypes.Upstream, renderOptions *RenderOptions) (
 	return &base, nil
 }
 
-func findAllKotsHelmCharts(upstreamFiles []upstreamtypes.UpstreamFile) []*kotsv1beta1.HelmChart {
+func findAllKotsHelmCharts(upstreamFiles []upstreamtypes.UpstreamFile) ([]*kotsv1beta1.HelmChart, error) {
 	kotsHelmCharts := []*kotsv1beta1.HelmChart{}
 	for _, upstreamFile := range upstreamFiles {
-		kotsHelmChart := tryParsingAsHelmChartGVK(upstreamFile.Content)
+		content, err := readKotsKindContent(upstreamFile)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to read %s", upstreamFile.Path)
+		}
+
+		kotsHelmChart := tryParsingAsHelmChartGVK(content)
 		if kotsHelmChart != nil {
 			kotsHelmCharts = append(kotsHelmCharts, kotsHelmChart)
 		}
 	}
 
-	return kotsHelmCharts
+	return kotsHelmCharts, nil
+}
+
+// readKotsKindContent returns the content of a file that may be a kots kind, reading files that
+// were staged on disk. Staged chart archives are not read, since they can't be kots kinds.
+func readKotsKindContent(upstreamFile upstreamtypes.UpstreamFile) ([]byte, error) {
+	if upstreamFile.LocalPath != "" && isHelmChartFile(upstreamFile) {
+		return nil, nil
+	}
+	return upstreamFile.ReadContent()
 }
 
 func UnmarshalLicenseContent(content []byte, log *logger.Logger) *kotsv1beta1.License {
@@ -282,14 +327,19 @@ func tryGetConfigFromFileContent(content []byte, log *logger.Logger) *kotsv1beta
 	return nil
 }
 
-func findConfig(u *upstreamtypes.Upstream, log *logger.Logger) (*kotsv1beta1.Config, *kotsv1beta1.ConfigValues, *kotsv1beta1.License) {
+func findConfig(u *upstreamtypes.Upstream, log *logger.Logger) (*kotsv1beta1.Config, *kotsv1beta1.ConfigValues, *kotsv1beta1.License, error) {
 	var config *kotsv1beta1.Config
 	var values *kotsv1beta1.ConfigValues
 	var license *kotsv1beta1.License
 
 	for _, file := range u.Files {
+		content, err := readKotsKindContent(file)
+		if err != nil {
+			return nil, nil, nil, errors.Wrapf(err, "failed to read %s", file.Path)
+		}
+
 		decode := scheme.Codecs.UniversalDeserializer().Decode
-		obj, gvk, err := decode(file.Content, nil, nil)
+		obj, gvk, err := decode(content, nil, nil)
 		if err != nil {
 			continue
 		}
@@ -302,29 +352,19 @@ func findConfig(u *upstreamtypes.Upstream, log *logger.Logger) (*kotsv1beta1.Con
 			license = obj.(*kotsv1beta1.License)
 		}
 	}
-	return config, values, license
+	return config, values, license, nil
 }
 
 // findHelmChartArchiveInRelease iterates through all files in the release (upstreamFiles), looking for a helm chart archive
 // that matches the chart name and version specified in the kotsHelmChart parameter
-func findHelmChartArchiveInRelease(upstreamFiles []upstreamtypes.UpstreamFile, kotsHelmChart *kotsv1beta1.HelmChart) ([]byte, error) {
+func findHelmChartArchiveInRelease(upstreamFiles []upstreamtypes.UpstreamFile, kotsHelmChart *kotsv1beta1.HelmChart) (*upstreamtypes.UpstreamFile, error) {
 	for _, upstreamFile := range upstreamFiles {
-		if !isHelmChart(upstreamFile.Content) {
+		if !isHelmChartFile(upstreamFile) {
 			continue
 		}
 
 		// We treat all .tar.gz archives as helm charts
-		chartArchivePath, err := ioutil.TempFile("", "chart")
-		if err != nil {
-			return nil, errors.Wrap(err, "failed to create temp file for chart archive path")
-		}
-		defer os.Remove(chartArchivePath.Name())
-		_, err = io.Copy(chartArchivePath, bytes.NewReader(upstreamFile.Content))
-		if err != nil {
-			return nil, errors.Wrap(err, "failed to copy maybe chart to tmp file")
-		}
-
-		files, err := readTarGz(chartArchivePath.Name())
+		files, err := readUpstreamFileTarGz(upstreamFile)
 		if err != nil {
 			return nil, errors.Wrap(err, "failed to read chart archive")
 		}
@@ -338,7 +378,8 @@ func findHelmChartArchiveInRelease(upstreamFiles []upstreamtypes.UpstreamFile, k
 
 				if chartManifest.GetName() == kotsHelmChart.Spec.Chart.Name {
 					if chartManifest.GetVersion() == kotsHelmChart.Spec.Chart.ChartVersion {
-						return upstreamFile.Content, nil
+						archive := upstreamFile
+						return &archive, nil
 					}
 				}
 			}
@@ -357,6 +398,67 @@ func isHelmChart(data []byte) bool {
 	return true
 }
 
+// isHelmChartFile is isHelmChart for files that may be staged on disk, only the gzip header is read
+func isHelmChartFile(upstreamFile upstreamtypes.UpstreamFile) bool {
+	if upstreamFile.LocalPath == "" {
+		return isHelmChart(upstreamFile.Content)
+	}
+
+	f, err := upstreamFile.Open()
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+
+	gzReader, err := gzip.NewReader(f)
+	if err != nil {
+		return false
+	}
+	gzReader.Close()
+	return true
+}
+
+// withArchivePath calls fn with the path of an archive on disk. Archives that are held
+// in memory are written to a temp file first.
+func withArchivePath(upstreamFile upstreamtypes.UpstreamFile, fn func(string) error) error {
+	if upstreamFile.LocalPath != "" {
+		return fn(upstreamFile.LocalPath)
+	}
+
+	archiveFile, err := ioutil.TempFile("", "chart")
+	if err != nil {
+		return errors.Wrap(err, "failed to create temp file for chart archive")
+	}
+	defer os.Remove(archiveFile.Name())
+	defer archiveFile.Close()
+
+	if _, err := io.Copy(archiveFile, bytes.NewReader(upstreamFile.Content)); err != nil {
+		return errors.Wrap(err, "failed to copy chart archive to temp file")
+	}
+
+	return fn(archiveFile.Name())
+}
+
+func readUpstreamFileTarGz(upstreamFile upstreamtypes.UpstreamFile) ([]upstreamtypes.UpstreamFile, error) {
+	var files []upstreamtypes.UpstreamFile
+	err := withArchivePath(upstreamFile, func(archivePath string) error {
+		var err error
+		files, err = readTarGz(archivePath)
+		return err
+	})
+	return files, err
+}
+
+func upstreamFileToSparseUpstream(upstreamFile *upstreamtypes.UpstreamFile) (*upstreamtypes.Upstream, error) {
+	var u *upstreamtypes.Upstream
+	err := withArchivePath(*upstreamFile, func(archivePath string) error {
+		var err error
+		u, err = chartArchiveToSparseUpstream(archivePath)
+		return err
+	})
+	return u, err
+}
+
 func readTarGz(source string) ([]upstreamtypes.UpstreamFile, error) {
 	f, err := os.Open(source)
 	if err != nil {
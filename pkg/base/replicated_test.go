@@ -0,0 +1,98 @@
+package base
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/replicatedhq/kots/pkg/logger"
+	upstreamtypes "github.com/replicatedhq/kots/pkg/upstream/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_renderReplicatedStagedChart(t *testing.T) {
+	req := require.New(t)
+
+	var b bytes.Buffer
+	gw := gzip.NewWriter(&b)
+	tw := tar.NewWriter(gw)
+	for name, content := range map[string]string{
+		"mychart/Chart.yaml":               "apiVersion: v1\nname: mychart\nversion: 1.0.0\n",
+		"mychart/templates/configmap.yaml": "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: {{ .Values.name }}\n",
+	} {
+		req.NoError(tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(content)), Typeflag: tar.TypeReg}))
+		_, err := tw.Write([]byte(content))
+		req.NoError(err)
+	}
+	req.NoError(tw.Close())
+	req.NoError(gw.Close())
+
+	stagingDir, err := ioutil.TempDir("", "kots")
+	req.NoError(err)
+	defer os.RemoveAll(stagingDir)
+
+	archivePath := filepath.Join(stagingDir, "mychart-1.0.0.tgz")
+	req.NoError(ioutil.WriteFile(archivePath, b.Bytes(), 0644))
+
+	// kots kinds that are large enough to be staged are read from disk too
+	helmChartPath := filepath.Join(stagingDir, "mychart.yaml")
+	req.NoError(ioutil.WriteFile(helmChartPath, []byte(`apiVersion: kots.io/v1beta1
+kind: HelmChart
+metadata:
+  name: mychart
+spec:
+  chart:
+    name: mychart
+    chartVersion: 1.0.0
+  values:
+    name: repl{{ ConfigOption "name" }}
+`), 0644))
+	configPath := filepath.Join(stagingDir, "config.yaml")
+	req.NoError(ioutil.WriteFile(configPath, []byte(`apiVersion: kots.io/v1beta1
+kind: Config
+metadata:
+  name: config
+spec:
+  groups:
+  - name: settings
+    items:
+    - name: name
+      type: text
+      default: from-kots
+`), 0644))
+
+	u := &upstreamtypes.Upstream{
+		Name: "myapp",
+		Type: "replicated",
+		Files: []upstreamtypes.UpstreamFile{
+			{
+				Path:      "mychart.yaml",
+				LocalPath: helmChartPath,
+			},
+			{
+				Path:      "config.yaml",
+				LocalPath: configPath,
+			},
+			{
+				Path:      "mychart-1.0.0.tgz",
+				LocalPath: archivePath,
+			},
+		},
+		StagingDir: stagingDir,
+	}
+
+	base, err := renderReplicated(u, &RenderOptions{Log: logger.NewLogger()})
+	req.NoError(err)
+
+	actual := map[string]string{}
+	for _, f := range base.Files {
+		actual[f.Path] = string(f.Content)
+	}
+	assert.NotContains(t, actual, "mychart-1.0.0.tgz")
+	assert.Equal(t, "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: from-kots\n", actual["charts/mychart/configmap.yaml"])
+}
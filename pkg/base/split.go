@@ -0,0 +1,99 @@
+package base
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// splitMultiDocYAML splits yaml files with more than one document into a file for each document,
+// so that each one is included, excluded and deduplicated on its own. The files are named for the
+// kind and name of the resource in them, so that the names are stable between versions.
+func splitMultiDocYAML(files []BaseFile) []BaseFile {
+	splitFiles := []BaseFile{}
+	for _, file := range files {
+		ext := path.Ext(file.Path)
+		if ext != ".yaml" && ext != ".yml" {
+			splitFiles = append(splitFiles, file)
+			continue
+		}
+
+		docs := SplitYAMLDocuments(file.Content)
+		if len(docs) <= 1 {
+			splitFiles = append(splitFiles, file)
+			continue
+		}
+
+		prefix := strings.TrimSuffix(file.Path, ext)
+		usedPaths := map[string]bool{}
+		for idx, doc := range docs {
+			o := OverlySimpleGVK{}
+			_ = yaml.Unmarshal(doc, &o) // documents that aren't resources are numbered instead
+
+			splitFiles = append(splitFiles, BaseFile{
+				Path:             splitResourcePath(prefix, ext, idx, o.Kind, o.Metadata.Name, usedPaths),
+				Content:          doc,
+				UpstreamPath:     upstreamPath(file),
+				UpstreamDocIndex: file.UpstreamDocIndex + idx,
+			})
+		}
+	}
+
+	return splitFiles
+}
+
+// SplitYAMLDocuments returns the documents in content, without any that are empty
+func SplitYAMLDocuments(content []byte) [][]byte {
+	docs := [][]byte{}
+
+	lines := []string{}
+	addDoc := func() {
+		doc := strings.Join(lines, "\n")
+		if strings.TrimSpace(doc) != "" {
+			docs = append(docs, []byte(doc))
+		}
+		lines = []string{}
+	}
+
+	for _, line := range strings.Split(string(content), "\n") {
+		if strings.TrimRight(line, " \t\r") == "---" {
+			addDoc()
+			continue
+		}
+		lines = append(lines, line)
+	}
+	addDoc()
+
+	return docs
+}
+
+// upstreamPath is the path of the file that a file was rendered or split from
+func upstreamPath(file BaseFile) string {
+	if file.UpstreamPath != "" {
+		return file.UpstreamPath
+	}
+	return file.Path
+}
+
+// splitResourcePath names the file for a resource that was split out of prefix+ext by its kind and
+// name, with a suffix when the same kind and name is used more than once. Documents without a kind
+// and name are numbered by their position.
+func splitResourcePath(prefix string, ext string, idx int, kind string, name string, usedPaths map[string]bool) string {
+	filename := fmt.Sprintf("%s-%d", prefix, idx+1)
+	if kind != "" && name != "" {
+		filename = strings.ToLower(fmt.Sprintf("%s-%s", kind, name))
+		if prefix != "" {
+			filename = fmt.Sprintf("%s-%s", prefix, filename)
+		}
+	}
+
+	resourcePath := filename + ext
+	for i := 1; usedPaths[resourcePath]; i++ {
+		resourcePath = fmt.Sprintf("%s-%d%s", filename, i, ext)
+	}
+
+	usedPaths[resourcePath] = true
+	return resourcePath
+}
@@ -0,0 +1,101 @@
+package base
+
+import (
+	"testing"
+
+	"github.com/replicatedhq/kots/pkg/logger"
+	upstreamtypes "github.com/replicatedhq/kots/pkg/upstream/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_splitMultiDocYAML(t *testing.T) {
+	tests := []struct {
+		name     string
+		files    []BaseFile
+		expected []BaseFile
+	}{
+		{
+			name: "single document",
+			files: []BaseFile{
+				{Path: "deployment.yaml", Content: []byte("---\napiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: app\n")},
+			},
+			expected: []BaseFile{
+				{Path: "deployment.yaml", Content: []byte("---\napiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: app\n")},
+			},
+		},
+		{
+			name: "named by kind and name",
+			files: []BaseFile{
+				{Path: "manifests/app.yaml", Content: []byte("apiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: App\n---\n\n---\napiVersion: v1\nkind: Service\nmetadata:\n  name: app\n")},
+			},
+			expected: []BaseFile{
+				{Path: "manifests/app-deployment-app.yaml", Content: []byte("apiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: App"), UpstreamPath: "manifests/app.yaml", UpstreamDocIndex: 0},
+				{Path: "manifests/app-service-app.yaml", Content: []byte("apiVersion: v1\nkind: Service\nmetadata:\n  name: app\n"), UpstreamPath: "manifests/app.yaml", UpstreamDocIndex: 1},
+			},
+		},
+		{
+			name: "duplicates and documents that aren't resources",
+			files: []BaseFile{
+				{Path: "app.yml", Content: []byte("kind: ConfigMap\nmetadata:\n  name: a\n---\nkind: ConfigMap\nmetadata:\n  name: a\n---\n# just a comment\n")},
+			},
+			expected: []BaseFile{
+				{Path: "app-configmap-a.yml", Content: []byte("kind: ConfigMap\nmetadata:\n  name: a"), UpstreamPath: "app.yml", UpstreamDocIndex: 0},
+				{Path: "app-configmap-a-1.yml", Content: []byte("kind: ConfigMap\nmetadata:\n  name: a"), UpstreamPath: "app.yml", UpstreamDocIndex: 1},
+				{Path: "app-3.yml", Content: []byte("# just a comment\n"), UpstreamPath: "app.yml", UpstreamDocIndex: 2},
+			},
+		},
+		{
+			name: "not yaml",
+			files: []BaseFile{
+				{Path: "README.md", Content: []byte("title\n---\nbody\n")},
+			},
+			expected: []BaseFile{
+				{Path: "README.md", Content: []byte("title\n---\nbody\n")},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			actual := splitMultiDocYAML(test.files)
+			assert.Equal(t, test.expected, actual)
+		})
+	}
+}
+
+func Test_RenderUpstreamSplitsReplicated(t *testing.T) {
+	req := require.New(t)
+
+	u := &upstreamtypes.Upstream{
+		Name: "myapp",
+		Type: "replicated",
+		Files: []upstreamtypes.UpstreamFile{
+			{
+				Path: "app.yaml",
+				Content: []byte(`apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: included
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: excluded
+  annotations:
+    kots.io/exclude: "true"
+`),
+			},
+		},
+	}
+
+	base, err := RenderUpstream(u, &RenderOptions{SplitMultiDocYAML: true, Log: logger.NewLogger()})
+	req.NoError(err)
+	req.Len(base.Files, 2)
+
+	// each document is included or excluded on its own
+	resources, _, err := deduplicateOnContent(base.Files, false)
+	req.NoError(err)
+	req.Len(resources, 1)
+	assert.Equal(t, "app-configmap-included.yaml", resources[0].Path)
+}
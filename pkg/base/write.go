@@ -6,6 +6,8 @@ import (
 	"io/ioutil"
 	"os"
 	"path"
+	"path/filepath"
+	"sort"
 
 	"github.com/pkg/errors"
 	"github.com/replicatedhq/kots/pkg/k8sutil"
@@ -42,6 +44,7 @@ func (b *Base) WriteBase(options WriteOptions) error {
 	if err != nil {
 		return errors.Wrap(err, "failed to deduplicate content")
 	}
+	resources, hooks := separateHooks(resources)
 
 	kustomizeResources := []string{}
 	kustomizePatches := []kustomizetypes.PatchStrategicMerge{}
@@ -78,11 +81,29 @@ func (b *Base) WriteBase(options WriteOptions) error {
 		kustomizePatches = append(kustomizePatches, kustomizetypes.PatchStrategicMerge(path.Join(".", file.Path)))
 	}
 
+	kustomizeBases := []string{}
+	subBaseDirs := []string{}
+	for _, subBase := range b.Bases {
+		if subBase.Path == "" {
+			return errors.New("sub-base path is required")
+		}
+
+		subBaseOptions := options
+		subBaseOptions.BaseDir = path.Join(renderDir, subBase.Path)
+		if err := subBase.WriteBase(subBaseOptions); err != nil {
+			return errors.Wrapf(err, "failed to write base %s", subBase.Path)
+		}
+
+		kustomizeBases = append(kustomizeBases, path.Join(".", subBase.Path))
+		subBaseDirs = append(subBaseDirs, subBaseOptions.BaseDir)
+	}
+
 	kustomization := kustomizetypes.Kustomization{
 		TypeMeta: kustomizetypes.TypeMeta{
 			APIVersion: "kustomize.config.k8s.io/v1beta1",
 			Kind:       "Kustomization",
 		},
+		Bases:                 kustomizeBases,
 		Resources:             kustomizeResources,
 		PatchesStrategicMerge: kustomizePatches,
 	}
@@ -91,9 +112,127 @@ func (b *Base) WriteBase(options WriteOptions) error {
 		return errors.Wrap(err, "failed to write kustomization to file")
 	}
 
+	if err := writeHooks(renderDir, hooks, subBaseDirs); err != nil {
+		return errors.Wrap(err, "failed to write hooks")
+	}
+
+	return nil
+}
+
+// separateHooks splits the hook resources from the rest of the base, by the events that
+// they're applied on
+func separateHooks(files []BaseFile) ([]BaseFile, map[string][]BaseFile) {
+	resources := []BaseFile{}
+	hooks := map[string][]BaseFile{}
+
+	for _, file := range files {
+		events := file.hookEvents()
+		if len(events) == 0 {
+			resources = append(resources, file)
+			continue
+		}
+
+		for _, event := range events {
+			hooks[event] = append(hooks[event], file)
+		}
+	}
+
+	return resources, hooks
+}
+
+// writeHooks writes a kustomization to hooks/<event> for each event, so that a deployer can apply
+// them in phases. The resources are listed in weight order, and keep their weight annotation
+// so they can be ordered after they're built. The hooks of sub-bases are included as bases.
+func writeHooks(renderDir string, hooks map[string][]BaseFile, subBaseDirs []string) error {
+	subBaseHooks := map[string][]string{}
+	for _, subBaseDir := range subBaseDirs {
+		events, err := ListHookEvents(subBaseDir)
+		if err != nil {
+			return errors.Wrapf(err, "failed to list hooks in %s", subBaseDir)
+		}
+		for _, event := range events {
+			subBaseHooks[event] = append(subBaseHooks[event], subBaseDir)
+			if _, ok := hooks[event]; !ok {
+				hooks[event] = []BaseFile{}
+			}
+		}
+	}
+
+	for event, files := range hooks {
+		sort.SliceStable(files, func(i, j int) bool {
+			return files[i].hookWeight() < files[j].hookWeight()
+		})
+
+		hookDir := path.Join(renderDir, HooksDir, event)
+		if err := os.MkdirAll(hookDir, 0744); err != nil {
+			return errors.Wrap(err, "failed to mkdir")
+		}
+
+		kustomizeBases := []string{}
+		for _, subBaseDir := range subBaseHooks[event] {
+			relativeDir, err := filepath.Rel(hookDir, path.Join(subBaseDir, HooksDir, event))
+			if err != nil {
+				return errors.Wrap(err, "failed to determine relative path for sub-base hooks")
+			}
+			kustomizeBases = append(kustomizeBases, relativeDir)
+		}
+
+		kustomizeResources := []string{}
+		for _, file := range files {
+			fileRenderPath := path.Join(hookDir, file.Path)
+			if err := os.MkdirAll(path.Dir(fileRenderPath), 0744); err != nil {
+				return errors.Wrap(err, "failed to mkdir")
+			}
+
+			if err := ioutil.WriteFile(fileRenderPath, file.Content, 0644); err != nil {
+				return errors.Wrap(err, "failed to write hook file")
+			}
+
+			kustomizeResources = append(kustomizeResources, path.Join(".", file.Path))
+		}
+
+		kustomization := kustomizetypes.Kustomization{
+			TypeMeta: kustomizetypes.TypeMeta{
+				APIVersion: "kustomize.config.k8s.io/v1beta1",
+				Kind:       "Kustomization",
+			},
+			Bases:     kustomizeBases,
+			Resources: kustomizeResources,
+		}
+
+		if err := k8sutil.WriteKustomizationToFile(&kustomization, path.Join(hookDir, "kustomization.yaml")); err != nil {
+			return errors.Wrapf(err, "failed to write %s hook kustomization", event)
+		}
+	}
+
 	return nil
 }
 
+// ListHookEvents returns the events that there's a hook kustomization for in a base or overlay dir
+func ListHookEvents(dir string) ([]string, error) {
+	entries, err := ioutil.ReadDir(path.Join(dir, HooksDir))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []string{}, nil
+		}
+		return nil, errors.Wrap(err, "failed to read hooks dir")
+	}
+
+	events := []string{}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		if _, err := os.Stat(path.Join(dir, HooksDir, entry.Name(), "kustomization.yaml")); err != nil {
+			continue
+		}
+		events = append(events, entry.Name())
+	}
+	sort.Strings(events)
+
+	return events, nil
+}
+
 func deduplicateOnContent(files []BaseFile, excludeKotsKinds bool) ([]BaseFile, []BaseFile, error) {
 	resources := []BaseFile{}
 	patches := []BaseFile{}
@@ -1,10 +1,15 @@
 package base
 
 import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	kustomizetypes "sigs.k8s.io/kustomize/v3/pkg/types"
+	"sigs.k8s.io/yaml"
 )
 
 var (
@@ -144,3 +149,86 @@ func Test_DeduplicateOnContent(t *testing.T) {
 	}
 
 }
+
+func Test_WriteBaseSeparatesHooks(t *testing.T) {
+	req := require.New(t)
+
+	dir, err := ioutil.TempDir("", "kots")
+	req.NoError(err)
+	defer os.RemoveAll(dir)
+
+	b := Base{
+		Files: []BaseFile{
+			{
+				Path:    "deployment.yaml",
+				Content: []byte("apiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: app\n"),
+			},
+			{
+				Path:    "seed.yaml",
+				Content: []byte("apiVersion: batch/v1\nkind: Job\nmetadata:\n  name: seed\n  annotations:\n    kots.io/hook: post-install\n    kots.io/hook-weight: \"10\"\n"),
+			},
+			{
+				Path:    "migrate.yaml",
+				Content: []byte("apiVersion: batch/v1\nkind: Job\nmetadata:\n  name: migrate\n  annotations:\n    kots.io/hook: pre-install,post-install\n    kots.io/hook-weight: \"-5\"\n"),
+			},
+		},
+	}
+
+	err = b.WriteBase(WriteOptions{BaseDir: filepath.Join(dir, "base")})
+	req.NoError(err)
+
+	readKustomization := func(p string) *kustomizetypes.Kustomization {
+		content, err := ioutil.ReadFile(filepath.Join(dir, "base", p, "kustomization.yaml"))
+		req.NoError(err)
+		k := kustomizetypes.Kustomization{}
+		req.NoError(yaml.Unmarshal(content, &k))
+		return &k
+	}
+
+	assert.Equal(t, []string{"deployment.yaml"}, readKustomization(".").Resources)
+	assert.Equal(t, []string{"migrate.yaml"}, readKustomization("hooks/pre-install").Resources)
+	assert.Equal(t, []string{"migrate.yaml", "seed.yaml"}, readKustomization("hooks/post-install").Resources)
+
+	_, err = os.Stat(filepath.Join(dir, "base", "hooks", "post-install", "seed.yaml"))
+	req.NoError(err)
+}
+
+func Test_WriteBaseIncludesSubBaseHooks(t *testing.T) {
+	req := require.New(t)
+
+	dir, err := ioutil.TempDir("", "kots")
+	req.NoError(err)
+	defer os.RemoveAll(dir)
+
+	b := Base{
+		Files: []BaseFile{
+			{
+				Path:    "deployment.yaml",
+				Content: []byte("apiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: app\n"),
+			},
+		},
+		Bases: []Base{
+			{
+				Path: "charts/db",
+				Files: []BaseFile{
+					{
+						Path:    "migrate.yaml",
+						Content: []byte("apiVersion: batch/v1\nkind: Job\nmetadata:\n  name: migrate\n  annotations:\n    kots.io/hook: pre-upgrade\n"),
+					},
+				},
+			},
+		},
+	}
+
+	err = b.WriteBase(WriteOptions{BaseDir: filepath.Join(dir, "base")})
+	req.NoError(err)
+
+	events, err := ListHookEvents(filepath.Join(dir, "base"))
+	req.NoError(err)
+	assert.Equal(t, []string{"pre-upgrade"}, events)
+
+	resMap, err := KustomizeBuild(filepath.Join(dir, "base", "hooks", "pre-upgrade"))
+	req.NoError(err)
+	req.Len(resMap.Resources(), 1)
+	assert.Equal(t, "migrate", resMap.Resources()[0].GetName())
+}
@@ -30,6 +30,7 @@ func TemplateConfig(log *logger.Logger, configSpecData string, configValuesData
 
 	builder := template.Builder{}
 	builder.AddCtx(template.StaticCtx{})
+	builder.AddCtx(template.ClusterCtx{})
 
 	// get template context from config values
 	templateContext, err := base.UnmarshalConfigValuesContent([]byte(configValuesData))
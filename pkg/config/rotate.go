@@ -0,0 +1,263 @@
+package config
+
+import (
+	"bytes"
+	"encoding/base64"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+	kotsv1beta1 "github.com/replicatedhq/kots/kotskinds/apis/kots/v1beta1"
+	"github.com/replicatedhq/kots/pkg/crypto"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/serializer/json"
+	"k8s.io/client-go/kubernetes/scheme"
+)
+
+// RotateEncryptionKey replaces the encryption key of the app in appDir (the directory that contains upstream/)
+// with a new key. All password values are re-encrypted with the new key. Nothing is changed when any of
+// them can't be decrypted with the current key.
+func RotateEncryptionKey(appDir string) error {
+	userdataDir := filepath.Join(appDir, "upstream", "userdata")
+	installationFile := filepath.Join(userdataDir, "installation.yaml")
+	configValuesFile := filepath.Join(userdataDir, "config.yaml")
+
+	installationObj, err := readKotsKind(installationFile)
+	if err != nil {
+		return errors.Wrap(err, "failed to read installation")
+	}
+	installation, ok := installationObj.(*kotsv1beta1.Installation)
+	if !ok {
+		return errors.Errorf("%s is not an installation", installationFile)
+	}
+	if installation.Spec.EncryptionKey == "" {
+		return errors.New("installation does not have an encryption key")
+	}
+
+	oldCipher, err := crypto.AESCipherFromString(installation.Spec.EncryptionKey)
+	if err != nil {
+		return errors.Wrap(err, "failed to load current encryption key")
+	}
+
+	newCipher, err := crypto.NewAESCipher()
+	if err != nil {
+		return errors.Wrap(err, "failed to create new encryption key")
+	}
+
+	var configValuesContent []byte
+	if _, err := os.Stat(configValuesFile); err == nil {
+		configValuesObj, err := readKotsKind(configValuesFile)
+		if err != nil {
+			return errors.Wrap(err, "failed to read config values")
+		}
+		configValues, ok := configValuesObj.(*kotsv1beta1.ConfigValues)
+		if !ok {
+			return errors.Errorf("%s is not config values", configValuesFile)
+		}
+
+		if len(configValues.Spec.Values) > 0 {
+			config, err := findConfigInDir(filepath.Join(appDir, "upstream"))
+			if err != nil {
+				return errors.Wrap(err, "failed to find config")
+			}
+			if config == nil {
+				return errors.New("config values exist, but no config was found to identify password items")
+			}
+
+			if err := reencryptPasswordValues(config, configValues, oldCipher, newCipher); err != nil {
+				return errors.Wrap(err, "failed to re-encrypt password values")
+			}
+
+			configValuesContent, err = marshalKotsKind(configValues)
+			if err != nil {
+				return errors.Wrap(err, "failed to marshal config values")
+			}
+		}
+	} else if !os.IsNotExist(err) {
+		return errors.Wrap(err, "failed to check if config values exist")
+	}
+
+	installation.Spec.EncryptionKey = newCipher.ToString()
+	installationContent, err := marshalKotsKind(installation)
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal installation")
+	}
+
+	// both files are staged before either is replaced. the config values are replaced first, and
+	// restored from a backup when the installation with the new key can't be written, so that
+	// a failure can't leave values encrypted with a key that was never saved
+	stagedInstallation, err := stageFile(installationFile, installationContent)
+	if err != nil {
+		return errors.Wrap(err, "failed to stage installation")
+	}
+	defer os.Remove(stagedInstallation)
+
+	if configValuesContent == nil {
+		if err := renameFile(stagedInstallation, installationFile); err != nil {
+			return errors.Wrap(err, "failed to write installation")
+		}
+		return nil
+	}
+
+	originalConfigValuesContent, err := ioutil.ReadFile(configValuesFile)
+	if err != nil {
+		return errors.Wrap(err, "failed to read config values")
+	}
+	backupConfigValues, err := stageFile(configValuesFile, originalConfigValuesContent)
+	if err != nil {
+		return errors.Wrap(err, "failed to back up config values")
+	}
+	keepBackup := false
+	defer func() {
+		if !keepBackup {
+			os.Remove(backupConfigValues)
+		}
+	}()
+
+	stagedConfigValues, err := stageFile(configValuesFile, configValuesContent)
+	if err != nil {
+		return errors.Wrap(err, "failed to stage config values")
+	}
+	defer os.Remove(stagedConfigValues)
+
+	if err := renameFile(stagedConfigValues, configValuesFile); err != nil {
+		return errors.Wrap(err, "failed to write config values")
+	}
+
+	if err := renameFile(stagedInstallation, installationFile); err != nil {
+		if restoreErr := renameFile(backupConfigValues, configValuesFile); restoreErr != nil {
+			keepBackup = true
+			return errors.Wrapf(err, "failed to write installation, and failed to restore config values from %s: %v", backupConfigValues, restoreErr)
+		}
+		return errors.Wrap(err, "failed to write installation")
+	}
+
+	return nil
+}
+
+// renameFile replaces files with the staged files, it's a var so that tests can make it fail
+var renameFile = os.Rename
+
+// reencryptPasswordValues decrypts all password item values with oldCipher and encrypts them with newCipher.
+// All values are decrypted before any is changed, and the errors for all failed items are returned together.
+func reencryptPasswordValues(config *kotsv1beta1.Config, configValues *kotsv1beta1.ConfigValues, oldCipher *crypto.AESCipher, newCipher *crypto.AESCipher) error {
+	decryptedValues := map[string][]byte{}
+	failedItems := []string{}
+
+	for _, group := range config.Spec.Groups {
+		for _, item := range group.Items {
+			if item.Type != "password" {
+				continue
+			}
+
+			value, ok := configValues.Spec.Values[item.Name]
+			if !ok || value.Value == "" {
+				continue
+			}
+
+			decoded, err := base64.StdEncoding.DecodeString(value.Value)
+			if err != nil {
+				failedItems = append(failedItems, item.Name)
+				continue
+			}
+
+			decrypted, err := oldCipher.Decrypt(decoded)
+			if err != nil {
+				failedItems = append(failedItems, item.Name)
+				continue
+			}
+
+			decryptedValues[item.Name] = decrypted
+		}
+	}
+
+	if len(failedItems) > 0 {
+		return errors.Errorf("failed to decrypt %s with the current key", strings.Join(failedItems, ", "))
+	}
+
+	for name, decrypted := range decryptedValues {
+		value := configValues.Spec.Values[name]
+		value.Value = base64.StdEncoding.EncodeToString(newCipher.Encrypt(decrypted))
+		configValues.Spec.Values[name] = value
+	}
+
+	return nil
+}
+
+func findConfigInDir(dir string) (*kotsv1beta1.Config, error) {
+	var config *kotsv1beta1.Config
+
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || config != nil {
+			return nil
+		}
+
+		obj, err := readKotsKind(path)
+		if err != nil {
+			return nil
+		}
+		if c, ok := obj.(*kotsv1beta1.Config); ok {
+			config = c
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to walk upstream dir")
+	}
+
+	return config, nil
+}
+
+func readKotsKind(filename string) (runtime.Object, error) {
+	content, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read file")
+	}
+
+	decode := scheme.Codecs.UniversalDeserializer().Decode
+	obj, _, err := decode(content, nil, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to decode file")
+	}
+
+	return obj, nil
+}
+
+func marshalKotsKind(obj runtime.Object) ([]byte, error) {
+	s := json.NewYAMLSerializer(json.DefaultMetaFactory, scheme.Scheme, scheme.Scheme)
+
+	var b bytes.Buffer
+	if err := s.Encode(obj, &b); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// stageFile writes content to a temp file next to filename, so that it can be renamed over it
+func stageFile(filename string, content []byte) (string, error) {
+	f, err := ioutil.TempFile(filepath.Dir(filename), "."+filepath.Base(filename))
+	if err != nil {
+		return "", errors.Wrap(err, "failed to create temp file")
+	}
+	defer f.Close()
+
+	if _, err := f.Write(content); err != nil {
+		os.Remove(f.Name())
+		return "", errors.Wrap(err, "failed to write temp file")
+	}
+	if err := f.Sync(); err != nil {
+		os.Remove(f.Name())
+		return "", errors.Wrap(err, "failed to sync temp file")
+	}
+	if err := f.Chmod(0644); err != nil {
+		os.Remove(f.Name())
+		return "", errors.Wrap(err, "failed to set temp file mode")
+	}
+
+	return f.Name(), nil
+}
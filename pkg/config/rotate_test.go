@@ -0,0 +1,178 @@
+package config
+
+import (
+	"encoding/base64"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/pkg/errors"
+	kotsv1beta1 "github.com/replicatedhq/kots/kotskinds/apis/kots/v1beta1"
+	"github.com/replicatedhq/kots/pkg/crypto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const rotateTestConfig = `apiVersion: kots.io/v1beta1
+kind: Config
+metadata:
+  name: config
+spec:
+  groups:
+  - name: settings
+    items:
+    - name: password
+      type: password
+    - name: other_password
+      type: password
+    - name: hostname
+      type: text
+`
+
+func writeRotateTestApp(t *testing.T, encryptionKey string, values map[string]string) string {
+	req := require.New(t)
+
+	appDir, err := ioutil.TempDir("", "kots")
+	req.NoError(err)
+
+	userdataDir := filepath.Join(appDir, "upstream", "userdata")
+	req.NoError(os.MkdirAll(userdataDir, 0755))
+	req.NoError(ioutil.WriteFile(filepath.Join(appDir, "upstream", "config.yaml"), []byte(rotateTestConfig), 0644))
+
+	installation := &kotsv1beta1.Installation{
+		Spec: kotsv1beta1.InstallationSpec{
+			UpdateCursor:  "1",
+			EncryptionKey: encryptionKey,
+		},
+	}
+	installation.APIVersion = "kots.io/v1beta1"
+	installation.Kind = "Installation"
+	content, err := marshalKotsKind(installation)
+	req.NoError(err)
+	req.NoError(ioutil.WriteFile(filepath.Join(userdataDir, "installation.yaml"), content, 0644))
+
+	configValues := &kotsv1beta1.ConfigValues{
+		Spec: kotsv1beta1.ConfigValuesSpec{
+			Values: map[string]kotsv1beta1.ConfigValue{},
+		},
+	}
+	configValues.APIVersion = "kots.io/v1beta1"
+	configValues.Kind = "ConfigValues"
+	for name, value := range values {
+		configValues.Spec.Values[name] = kotsv1beta1.ConfigValue{Value: value}
+	}
+	content, err = marshalKotsKind(configValues)
+	req.NoError(err)
+	req.NoError(ioutil.WriteFile(filepath.Join(userdataDir, "config.yaml"), content, 0644))
+
+	return appDir
+}
+
+func readRotateTestApp(t *testing.T, appDir string) (*kotsv1beta1.Installation, *kotsv1beta1.ConfigValues) {
+	req := require.New(t)
+
+	installation, err := readKotsKind(filepath.Join(appDir, "upstream", "userdata", "installation.yaml"))
+	req.NoError(err)
+	configValues, err := readKotsKind(filepath.Join(appDir, "upstream", "userdata", "config.yaml"))
+	req.NoError(err)
+
+	return installation.(*kotsv1beta1.Installation), configValues.(*kotsv1beta1.ConfigValues)
+}
+
+func TestRotateEncryptionKey(t *testing.T) {
+	req := require.New(t)
+
+	oldCipher, err := crypto.NewAESCipher()
+	req.NoError(err)
+
+	appDir := writeRotateTestApp(t, oldCipher.ToString(), map[string]string{
+		"password":       base64.StdEncoding.EncodeToString(oldCipher.Encrypt([]byte("secret"))),
+		"other_password": "",
+		"hostname":       "example.com",
+	})
+	defer os.RemoveAll(appDir)
+
+	req.NoError(RotateEncryptionKey(appDir))
+
+	installation, configValues := readRotateTestApp(t, appDir)
+	assert.Equal(t, "1", installation.Spec.UpdateCursor)
+	assert.NotEqual(t, oldCipher.ToString(), installation.Spec.EncryptionKey)
+	assert.Equal(t, "example.com", configValues.Spec.Values["hostname"].Value)
+
+	newCipher, err := crypto.AESCipherFromString(installation.Spec.EncryptionKey)
+	req.NoError(err)
+
+	encrypted, err := base64.StdEncoding.DecodeString(configValues.Spec.Values["password"].Value)
+	req.NoError(err)
+	decrypted, err := newCipher.Decrypt(encrypted)
+	req.NoError(err)
+	assert.Equal(t, "secret", string(decrypted))
+
+	_, err = oldCipher.Decrypt(encrypted)
+	assert.Error(t, err)
+}
+
+func TestRotateEncryptionKeyRefusesUndecryptableValues(t *testing.T) {
+	req := require.New(t)
+
+	oldCipher, err := crypto.NewAESCipher()
+	req.NoError(err)
+	otherCipher, err := crypto.NewAESCipher()
+	req.NoError(err)
+
+	appDir := writeRotateTestApp(t, oldCipher.ToString(), map[string]string{
+		"password":       base64.StdEncoding.EncodeToString(oldCipher.Encrypt([]byte("secret"))),
+		"other_password": base64.StdEncoding.EncodeToString(otherCipher.Encrypt([]byte("secret"))),
+	})
+	defer os.RemoveAll(appDir)
+
+	installationBefore, configValuesBefore := readRotateTestApp(t, appDir)
+
+	err = RotateEncryptionKey(appDir)
+	req.Error(err)
+	assert.Contains(t, err.Error(), "other_password")
+
+	installationAfter, configValuesAfter := readRotateTestApp(t, appDir)
+	assert.Equal(t, installationBefore, installationAfter)
+	assert.Equal(t, configValuesBefore, configValuesAfter)
+}
+
+func TestRotateEncryptionKeyRestoresValuesWhenInstallationFails(t *testing.T) {
+	req := require.New(t)
+
+	oldCipher, err := crypto.NewAESCipher()
+	req.NoError(err)
+
+	appDir := writeRotateTestApp(t, oldCipher.ToString(), map[string]string{
+		"password": base64.StdEncoding.EncodeToString(oldCipher.Encrypt([]byte("secret"))),
+	})
+	defer os.RemoveAll(appDir)
+
+	installationFile := filepath.Join(appDir, "upstream", "userdata", "installation.yaml")
+	defer func() { renameFile = os.Rename }()
+	renameFile = func(oldpath string, newpath string) error {
+		if newpath == installationFile {
+			return errors.New("rename failed")
+		}
+		return os.Rename(oldpath, newpath)
+	}
+
+	err = RotateEncryptionKey(appDir)
+	req.Error(err)
+	assert.Contains(t, err.Error(), "failed to write installation")
+
+	installation, configValues := readRotateTestApp(t, appDir)
+	assert.Equal(t, oldCipher.ToString(), installation.Spec.EncryptionKey)
+
+	encrypted, err := base64.StdEncoding.DecodeString(configValues.Spec.Values["password"].Value)
+	req.NoError(err)
+	decrypted, err := oldCipher.Decrypt(encrypted)
+	req.NoError(err)
+	assert.Equal(t, "secret", string(decrypted))
+
+	// no staged or backup files are left behind
+	files, err := ioutil.ReadDir(filepath.Join(appDir, "upstream", "userdata"))
+	req.NoError(err)
+	assert.Len(t, files, 2)
+}
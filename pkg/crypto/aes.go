@@ -1,6 +1,7 @@
 package crypto
 
 import (
+	"bytes"
 	"crypto/aes"
 	"crypto/cipher"
 	"crypto/rand"
@@ -9,6 +10,8 @@ import (
 	"github.com/pkg/errors"
 )
 
+// AESCipher encrypts with AES-GCM. The nonce in the key string is only used to decrypt
+// legacy ciphertext, new ciphertext has a random nonce per message.
 type AESCipher struct {
 	key    []byte
 	cipher cipher.AEAD
@@ -17,6 +20,10 @@ type AESCipher struct {
 
 const keyLength = 24 // 192 bit
 
+// ciphertextV2Prefix marks ciphertext that is followed by its own nonce and the sealed message.
+// Legacy ciphertext is only the sealed message, using the nonce from the key string.
+var ciphertextV2Prefix = []byte("kots:v2:")
+
 func NewAESCipher() (*AESCipher, error) {
 	key := make([]byte, keyLength)
 	if _, err := rand.Read(key); err != nil {
@@ -98,10 +105,19 @@ func (c *AESCipher) ToString() string {
 	return base64.StdEncoding.EncodeToString(append(c.key, c.nonce...))
 }
 
+// Encrypt seals the message with a random nonce, and returns the versioned ciphertext
 func (c *AESCipher) Encrypt(in []byte) []byte {
-	return c.cipher.Seal(nil, c.nonce, in, nil)
+	nonce := make([]byte, c.cipher.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		panic(errors.Wrap(err, "failed to read nonce"))
+	}
+
+	out := append([]byte{}, ciphertextV2Prefix...)
+	out = append(out, nonce...)
+	return c.cipher.Seal(out, nonce, in, nil)
 }
 
+// Decrypt opens versioned ciphertext, and falls back to the legacy format that used the nonce from the key string
 func (c *AESCipher) Decrypt(in []byte) (result []byte, err error) {
 	defer func() {
 		if r := recover(); r != nil {
@@ -109,6 +125,44 @@ func (c *AESCipher) Decrypt(in []byte) (result []byte, err error) {
 		}
 	}()
 
+	if decrypted, ok := c.openV2(in); ok {
+		return decrypted, nil
+	}
+
 	result, err = c.cipher.Open(nil, c.nonce, in, nil)
 	return
 }
+
+// IsLegacyCiphertext returns true if the input was encrypted by this cipher in the legacy fixed nonce format
+func (c *AESCipher) IsLegacyCiphertext(in []byte) (isLegacy bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			isLegacy = false
+		}
+	}()
+
+	if _, ok := c.openV2(in); ok {
+		return false
+	}
+
+	_, err := c.cipher.Open(nil, c.nonce, in, nil)
+	return err == nil
+}
+
+func (c *AESCipher) openV2(in []byte) ([]byte, bool) {
+	if !bytes.HasPrefix(in, ciphertextV2Prefix) {
+		return nil, false
+	}
+
+	sealed := in[len(ciphertextV2Prefix):]
+	nonceSize := c.cipher.NonceSize()
+	if len(sealed) < nonceSize {
+		return nil, false
+	}
+
+	decrypted, err := c.cipher.Open(nil, sealed[:nonceSize], sealed[nonceSize:], nil)
+	if err != nil {
+		return nil, false
+	}
+	return decrypted, true
+}
@@ -0,0 +1,53 @@
+package crypto
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAESCipherEncrypt(t *testing.T) {
+	req := require.New(t)
+
+	cipher, err := NewAESCipher()
+	req.NoError(err)
+
+	first := cipher.Encrypt([]byte("password"))
+	second := cipher.Encrypt([]byte("password"))
+	assert.NotEqual(t, first, second, "each message should have its own nonce")
+	assert.False(t, cipher.IsLegacyCiphertext(first))
+
+	// a cipher loaded from the key string can decrypt
+	loaded, err := AESCipherFromString(cipher.ToString())
+	req.NoError(err)
+
+	for _, encrypted := range [][]byte{first, second} {
+		decrypted, err := loaded.Decrypt(encrypted)
+		req.NoError(err)
+		assert.Equal(t, "password", string(decrypted))
+	}
+}
+
+func TestAESCipherDecryptLegacy(t *testing.T) {
+	req := require.New(t)
+
+	cipher, err := NewAESCipher()
+	req.NoError(err)
+
+	legacy := cipher.cipher.Seal(nil, cipher.nonce, []byte("password"), nil)
+	assert.True(t, cipher.IsLegacyCiphertext(legacy))
+
+	decrypted, err := cipher.Decrypt(legacy)
+	req.NoError(err)
+	assert.Equal(t, "password", string(decrypted))
+
+	other, err := NewAESCipher()
+	req.NoError(err)
+	assert.False(t, other.IsLegacyCiphertext(legacy))
+	_, err = other.Decrypt(legacy)
+	assert.Error(t, err)
+
+	_, err = other.Decrypt(cipher.Encrypt([]byte("password")))
+	assert.Error(t, err)
+}
@@ -0,0 +1,49 @@
+package crypto
+
+import (
+	"crypto"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	ErrSignatureInvalid = errors.New("signature is invalid")
+	ErrSignatureMissing = errors.New("signature is missing")
+)
+
+// VerifySignature checks an RSA-PSS signature of message against the public key, such as
+// the app public key in a license. The cause of the returned error is ErrSignatureInvalid
+// when the signature doesn't match.
+func VerifySignature(message, signature, publicKeyPEM []byte) error {
+	pubBlock, _ := pem.Decode(publicKeyPEM)
+	if pubBlock == nil {
+		return errors.New("failed to decode public key PEM")
+	}
+	publicKey, err := x509.ParsePKIXPublicKey(pubBlock.Bytes)
+	if err != nil {
+		return errors.Wrap(err, "failed to load public key from PEM")
+	}
+	rsaPublicKey, ok := publicKey.(*rsa.PublicKey)
+	if !ok {
+		return errors.Errorf("unexpected public key type %T", publicKey)
+	}
+
+	var opts rsa.PSSOptions
+	opts.SaltLength = rsa.PSSSaltLengthAuto
+
+	newHash := crypto.MD5
+	pssh := newHash.New()
+	pssh.Write(message)
+	hashed := pssh.Sum(nil)
+
+	err = rsa.VerifyPSS(rsaPublicKey, newHash, hashed, signature, &opts)
+	if err != nil {
+		// this ordering makes errors.Cause a little more useful
+		return errors.Wrap(ErrSignatureInvalid, err.Error())
+	}
+
+	return nil
+}
@@ -0,0 +1,298 @@
+package registry
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/docker/distribution/registry/client/auth/challenge"
+	"github.com/pkg/errors"
+	"github.com/replicatedhq/kots/pkg/version"
+)
+
+const (
+	ociManifestMediaType      = "application/vnd.oci.image.manifest.v1+json"
+	dockerManifestMediaType   = "application/vnd.docker.distribution.manifest.v2+json"
+	helmChartLayerMediaType   = "application/vnd.cncf.helm.chart.content.v1.tar+gzip"
+	legacyChartLayerMediaType = "application/tar+gzip"
+)
+
+type ociDescriptor struct {
+	MediaType string `json:"mediaType"`
+	Digest    string `json:"digest"`
+	Size      int64  `json:"size"`
+}
+
+type ociManifest struct {
+	MediaType string          `json:"mediaType"`
+	Config    ociDescriptor   `json:"config"`
+	Layers    []ociDescriptor `json:"layers"`
+}
+
+// ociClient talks to the registry v2 api. It handles both basic auth and
+// bearer token challenges, using the username and password (if any) for either.
+type ociClient struct {
+	endpoint   string
+	repository string
+	username   string
+	password   string
+	token      string
+	httpClient *http.Client
+}
+
+// ListTags returns all tags of a repository in an OCI registry
+func ListTags(endpoint, repository, username, password string) ([]string, error) {
+	c := newOCIClient(endpoint, repository, username, password)
+
+	tags := []string{}
+	next := fmt.Sprintf("%s/v2/%s/tags/list", c.baseURL(), repository)
+	for next != "" {
+		resp, err := c.get(next, "")
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to list tags")
+		}
+
+		body, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to read tags response")
+		}
+		if resp.StatusCode != http.StatusOK {
+			return nil, errors.Errorf("unexpected status code listing tags: %d: %s", resp.StatusCode, errorResponseToString(body))
+		}
+
+		tagList := struct {
+			Tags []string `json:"tags"`
+		}{}
+		if err := json.Unmarshal(body, &tagList); err != nil {
+			return nil, errors.Wrap(err, "failed to unmarshal tags response")
+		}
+		tags = append(tags, tagList.Tags...)
+
+		next, err = c.nextPage(resp)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to parse link header")
+		}
+	}
+
+	return tags, nil
+}
+
+// PullHelmChart downloads the chart archive of a helm chart that was pushed to an OCI registry
+func PullHelmChart(endpoint, repository, tag, username, password string) ([]byte, error) {
+	c := newOCIClient(endpoint, repository, username, password)
+
+	manifestURL := fmt.Sprintf("%s/v2/%s/manifests/%s", c.baseURL(), repository, tag)
+	resp, err := c.get(manifestURL, strings.Join([]string{ociManifestMediaType, dockerManifestMediaType}, ", "))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get manifest")
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read manifest")
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, errors.Errorf("chart %s:%s not found", repository, tag)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("unexpected status code getting manifest: %d: %s", resp.StatusCode, errorResponseToString(body))
+	}
+
+	manifest := ociManifest{}
+	if err := json.Unmarshal(body, &manifest); err != nil {
+		return nil, errors.Wrap(err, "failed to unmarshal manifest")
+	}
+
+	var chartLayer *ociDescriptor
+	for i, layer := range manifest.Layers {
+		if layer.MediaType == helmChartLayerMediaType || layer.MediaType == legacyChartLayerMediaType {
+			chartLayer = &manifest.Layers[i]
+			break
+		}
+	}
+	if chartLayer == nil {
+		return nil, errors.Errorf("%s:%s is not a helm chart", repository, tag)
+	}
+
+	blobURL := fmt.Sprintf("%s/v2/%s/blobs/%s", c.baseURL(), repository, chartLayer.Digest)
+	resp, err = c.get(blobURL, "")
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get chart layer")
+	}
+	content, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read chart layer")
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("unexpected status code getting chart layer: %d", resp.StatusCode)
+	}
+
+	sum := sha256.Sum256(content)
+	if actual := "sha256:" + hex.EncodeToString(sum[:]); actual != chartLayer.Digest {
+		return nil, errors.Errorf("chart layer digest mismatch: expected %s, got %s", chartLayer.Digest, actual)
+	}
+
+	return content, nil
+}
+
+func newOCIClient(endpoint, repository, username, password string) *ociClient {
+	return &ociClient{
+		endpoint:   sanitizeEndpoint(endpoint),
+		repository: repository,
+		username:   username,
+		password:   password,
+		httpClient: http.DefaultClient,
+	}
+}
+
+// baseURL uses plain http for registries on the loopback interface, same as docker does
+func (c *ociClient) baseURL() string {
+	host := c.endpoint
+	if h, _, err := net.SplitHostPort(c.endpoint); err == nil {
+		host = h
+	}
+
+	if host == "localhost" {
+		return "http://" + c.endpoint
+	}
+	if ip := net.ParseIP(host); ip != nil && ip.IsLoopback() {
+		return "http://" + c.endpoint
+	}
+
+	return "https://" + c.endpoint
+}
+
+func (c *ociClient) get(u string, accept string) (*http.Response, error) {
+	resp, err := c.do(u, accept)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusUnauthorized {
+		return resp, nil
+	}
+
+	challenges := challenge.ResponseChallenges(resp)
+	resp.Body.Close()
+	if len(challenges) == 0 {
+		return nil, errors.New("registry returned 401 without an auth challenge")
+	}
+
+	switch challenges[0].Scheme {
+	case "basic":
+		if c.username == "" {
+			return nil, errors.New("registry requires authentication, but no credentials were found")
+		}
+	case "bearer":
+		token, err := c.getBearerToken(challenges[0].Parameters)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to get bearer token")
+		}
+		c.token = token
+	default:
+		return nil, errors.Errorf("unsupported auth scheme %q", challenges[0].Scheme)
+	}
+
+	return c.do(u, accept)
+}
+
+func (c *ociClient) do(u string, accept string) (*http.Response, error) {
+	req, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create request")
+	}
+
+	req.Header.Add("User-Agent", fmt.Sprintf("KOTS/%s", version.Version()))
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", c.token))
+	} else if c.username != "" {
+		req.Header.Set("Authorization", fmt.Sprintf("Basic %s", makeBasicAuthToken(c.username, c.password)))
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to execute request")
+	}
+
+	return resp, nil
+}
+
+func (c *ociClient) getBearerToken(params map[string]string) (string, error) {
+	realm := params["realm"]
+	if realm == "" {
+		return "", errors.New("bearer challenge is missing realm")
+	}
+
+	v := url.Values{}
+	if service := params["service"]; service != "" {
+		v.Set("service", service)
+	}
+	v.Set("scope", fmt.Sprintf("repository:%s:pull", c.repository))
+
+	req, err := http.NewRequest("GET", realm+"?"+v.Encode(), nil)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to create auth request")
+	}
+	req.Header.Add("User-Agent", fmt.Sprintf("KOTS/%s", version.Version()))
+	if c.username != "" {
+		req.Header.Set("Authorization", fmt.Sprintf("Basic %s", makeBasicAuthToken(c.username, c.password)))
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to execute auth request")
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to read auth response")
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", errors.New(errorResponseToString(body))
+	}
+
+	bearerToken, err := newBearerTokenFromJSONBlob(body)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to parse bearer token")
+	}
+
+	return bearerToken.Token, nil
+}
+
+// nextPage returns the next page url from the Link header, or an empty string if this was the last page
+func (c *ociClient) nextPage(resp *http.Response) (string, error) {
+	link := resp.Header.Get("Link")
+	if link == "" {
+		return "", nil
+	}
+
+	start := strings.Index(link, "<")
+	end := strings.Index(link, ">")
+	if start == -1 || end < start {
+		return "", errors.Errorf("malformed link header %q", link)
+	}
+
+	next, err := url.Parse(link[start+1 : end])
+	if err != nil {
+		return "", errors.Wrap(err, "failed to parse next page url")
+	}
+
+	base, err := url.Parse(c.baseURL())
+	if err != nil {
+		return "", errors.Wrap(err, "failed to parse base url")
+	}
+
+	return base.ResolveReference(next).String(), nil
+}
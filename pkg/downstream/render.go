@@ -0,0 +1,158 @@
+package downstream
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/replicatedhq/kots/pkg/base"
+	"sigs.k8s.io/kustomize/v3/pkg/resource"
+)
+
+// Render builds the kustomization of the downstream in renderDir, or of the midstream when name is
+// empty, and returns a file for each resource in the order that they can be applied. Namespaces and
+// custom resource definitions are first, then the rest in the order that kustomize sorts them.
+func Render(renderDir string, name string) ([]base.BaseFile, error) {
+	overlayDir, err := overlayDir(renderDir, name)
+	if err != nil {
+		return nil, err
+	}
+
+	return render(overlayDir, resourceLess)
+}
+
+// ListHooks returns the hook events that the downstream in renderDir, or the midstream when name is
+// empty, has hooks for
+func ListHooks(renderDir string, name string) ([]string, error) {
+	if _, err := overlayDir(renderDir, name); err != nil {
+		return nil, err
+	}
+
+	// hooks are listed from the midstream, which has the hooks of the current version, so that
+	// hooks that a downstream doesn't have yet fail to render instead of being left out
+	return base.ListHookEvents(filepath.Join(renderDir, "overlays", "midstream"))
+}
+
+// RenderHook builds the hook kustomization for an event in the downstream in renderDir, or in the
+// midstream when name is empty, and returns a file for each resource in the order of their hook
+// weight
+func RenderHook(renderDir string, name string, event string) ([]base.BaseFile, error) {
+	overlayDir, err := overlayDir(renderDir, name)
+	if err != nil {
+		return nil, err
+	}
+
+	hookDir := filepath.Join(overlayDir, base.HooksDir, event)
+	if _, err := os.Stat(filepath.Join(hookDir, "kustomization.yaml")); err != nil {
+		if os.IsNotExist(err) {
+			return nil, errors.Errorf("no %s hooks in %s, pull the application again to update it", event, overlayDir)
+		}
+		return nil, errors.Wrap(err, "failed to stat hook kustomization")
+	}
+
+	return render(hookDir, hookLess)
+}
+
+func overlayDir(renderDir string, name string) (string, error) {
+	dir := filepath.Join(renderDir, "overlays", "midstream")
+	if name != "" {
+		dir = filepath.Join(renderDir, "overlays", "downstreams", name)
+	}
+
+	if _, err := os.Stat(dir); err != nil {
+		if os.IsNotExist(err) {
+			if name == "" {
+				return "", errors.Errorf("no midstream in %s", renderDir)
+			}
+			return "", errors.Errorf("no downstream named %q in %s", name, renderDir)
+		}
+		return "", errors.Wrap(err, "failed to stat overlay dir")
+	}
+
+	return dir, nil
+}
+
+func render(kustomizationDir string, less func(a *resource.Resource, b *resource.Resource) bool) ([]base.BaseFile, error) {
+	resMap, err := base.KustomizeBuild(kustomizationDir)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to build kustomization")
+	}
+
+	resources := resMap.Resources()
+	sort.SliceStable(resources, func(i, j int) bool {
+		return less(resources[i], resources[j])
+	})
+
+	files := []base.BaseFile{}
+	for idx, res := range resources {
+		content, err := res.AsYAML()
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to marshal %s", res.CurId())
+		}
+
+		// files are numbered so that applying the directory keeps the order
+		files = append(files, base.BaseFile{
+			Path:    strings.ToLower(fmt.Sprintf("%03d-%s-%s.yaml", idx+1, res.GetKind(), res.GetName())),
+			Content: content,
+		})
+	}
+
+	return files, nil
+}
+
+// Manifest joins the rendered files into a single multi doc yaml stream
+func Manifest(files []base.BaseFile) []byte {
+	docs := []string{}
+	for _, file := range files {
+		docs = append(docs, strings.TrimSuffix(string(file.Content), "\n"))
+	}
+	if len(docs) == 0 {
+		return []byte{}
+	}
+	return []byte(strings.Join(docs, "\n---\n") + "\n")
+}
+
+func resourceLess(a *resource.Resource, b *resource.Resource) bool {
+	aRank, bRank := kindRank(a.GetKind()), kindRank(b.GetKind())
+	if aRank != bRank {
+		return aRank < bRank
+	}
+
+	aGvk, bGvk := a.GetGvk(), b.GetGvk()
+	if aGvk != bGvk {
+		return aGvk.IsLessThan(bGvk)
+	}
+	if a.GetNamespace() != b.GetNamespace() {
+		return a.GetNamespace() < b.GetNamespace()
+	}
+	return a.GetName() < b.GetName()
+}
+
+// hookLess orders hooks by their weight, and hooks with the same weight the same as other resources
+func hookLess(a *resource.Resource, b *resource.Resource) bool {
+	aWeight, bWeight := hookWeight(a), hookWeight(b)
+	if aWeight != bWeight {
+		return aWeight < bWeight
+	}
+	return resourceLess(a, b)
+}
+
+func hookWeight(res *resource.Resource) int {
+	weight, _ := strconv.Atoi(res.GetAnnotations()[base.HookWeightAnnotation])
+	return weight
+}
+
+// kindRank puts the kinds that other resources depend on being created first
+func kindRank(kind string) int {
+	switch kind {
+	case "Namespace":
+		return 0
+	case "CustomResourceDefinition":
+		return 1
+	}
+	return 2
+}
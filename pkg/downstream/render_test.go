@@ -0,0 +1,154 @@
+package downstream
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/replicatedhq/kots/pkg/base"
+	"github.com/replicatedhq/kots/pkg/midstream"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Render(t *testing.T) {
+	req := require.New(t)
+
+	renderDir, err := ioutil.TempDir("", "kots-render")
+	req.NoError(err)
+	defer os.RemoveAll(renderDir)
+
+	files := map[string]string{
+		"base/kustomization.yaml": `apiVersion: kustomize.config.k8s.io/v1beta1
+kind: Kustomization
+resources:
+- deployment.yaml
+- crd.yaml
+- namespace.yaml
+- service.yaml
+`,
+		"base/deployment.yaml": `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: app
+`,
+		"base/crd.yaml": `apiVersion: apiextensions.k8s.io/v1beta1
+kind: CustomResourceDefinition
+metadata:
+  name: databases.example.com
+`,
+		"base/namespace.yaml": `apiVersion: v1
+kind: Namespace
+metadata:
+  name: app
+`,
+		"base/service.yaml": `apiVersion: v1
+kind: Service
+metadata:
+  name: app
+`,
+		"overlays/midstream/kustomization.yaml": `apiVersion: kustomize.config.k8s.io/v1beta1
+kind: Kustomization
+bases:
+- ../../base
+`,
+		"overlays/downstreams/this-cluster/kustomization.yaml": `apiVersion: kustomize.config.k8s.io/v1beta1
+kind: Kustomization
+bases:
+- ../../midstream
+commonLabels:
+  cluster: this-cluster
+`,
+	}
+	for filename, content := range files {
+		p := filepath.Join(renderDir, filename)
+		req.NoError(os.MkdirAll(filepath.Dir(p), 0755))
+		req.NoError(ioutil.WriteFile(p, []byte(content), 0644))
+	}
+
+	rendered, err := Render(renderDir, "this-cluster")
+	req.NoError(err)
+
+	paths := []string{}
+	for _, file := range rendered {
+		paths = append(paths, file.Path)
+	}
+	assert.Equal(t, []string{
+		"001-namespace-app.yaml",
+		"002-customresourcedefinition-databases.example.com.yaml",
+		"003-service-app.yaml",
+		"004-deployment-app.yaml",
+	}, paths)
+	assert.Contains(t, string(rendered[3].Content), "cluster: this-cluster")
+
+	manifest := string(Manifest(rendered))
+	assert.Contains(t, manifest, "kind: Namespace\nmetadata:\n  labels:\n    cluster: this-cluster\n  name: app\n---\napiVersion: apiextensions.k8s.io/v1beta1\n")
+
+	midstream, err := Render(renderDir, "")
+	req.NoError(err)
+	assert.Len(t, midstream, 4)
+	assert.NotContains(t, string(midstream[3].Content), "cluster: this-cluster")
+
+	_, err = Render(renderDir, "other-cluster")
+	req.Error(err)
+}
+
+func Test_RenderHooks(t *testing.T) {
+	req := require.New(t)
+
+	renderDir, err := ioutil.TempDir("", "kots-render")
+	req.NoError(err)
+	defer os.RemoveAll(renderDir)
+
+	b := base.Base{
+		Files: []base.BaseFile{
+			{
+				Path:    "deployment.yaml",
+				Content: []byte("apiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: app\n"),
+			},
+			{
+				Path:    "seed.yaml",
+				Content: []byte("apiVersion: batch/v1\nkind: Job\nmetadata:\n  name: seed\n  annotations:\n    kots.io/hook: pre-install\n    kots.io/hook-weight: \"10\"\n"),
+			},
+			{
+				Path:    "migrate.yaml",
+				Content: []byte("apiVersion: batch/v1\nkind: Job\nmetadata:\n  name: migrate\n  annotations:\n    kots.io/hook: pre-install\n    kots.io/hook-weight: \"-5\"\n"),
+			},
+		},
+	}
+	baseDir := filepath.Join(renderDir, "base")
+	req.NoError(b.WriteBase(base.WriteOptions{BaseDir: baseDir}))
+
+	m, err := midstream.CreateMidstream(&b, nil, nil, nil)
+	req.NoError(err)
+	midstreamDir := filepath.Join(renderDir, "overlays", "midstream")
+	req.NoError(m.WriteMidstream(midstream.WriteOptions{MidstreamDir: midstreamDir, BaseDir: baseDir}))
+
+	d, err := CreateDownstream(m, "this-cluster")
+	req.NoError(err)
+	req.NoError(d.WriteDownstream(WriteOptions{
+		DownstreamDir: filepath.Join(renderDir, "overlays", "downstreams", "this-cluster"),
+		MidstreamDir:  midstreamDir,
+	}))
+
+	hooks, err := ListHooks(renderDir, "this-cluster")
+	req.NoError(err)
+	assert.Equal(t, []string{"pre-install"}, hooks)
+
+	rendered, err := Render(renderDir, "this-cluster")
+	req.NoError(err)
+	req.Len(rendered, 1)
+	assert.Equal(t, "001-deployment-app.yaml", rendered[0].Path)
+
+	rendered, err = RenderHook(renderDir, "this-cluster", "pre-install")
+	req.NoError(err)
+	paths := []string{}
+	for _, file := range rendered {
+		paths = append(paths, file.Path)
+	}
+	assert.Equal(t, []string{"001-job-migrate.yaml", "002-job-seed.yaml"}, paths)
+
+	_, err = RenderHook(renderDir, "this-cluster", "post-install")
+	req.Error(err)
+}
@@ -6,7 +6,9 @@ import (
 	"path/filepath"
 
 	"github.com/pkg/errors"
+	"github.com/replicatedhq/kots/pkg/base"
 	"github.com/replicatedhq/kots/pkg/k8sutil"
+	kustomizetypes "sigs.k8s.io/kustomize/v3/pkg/types"
 )
 
 type WriteOptions struct {
@@ -22,7 +24,13 @@ func (d *Downstream) WriteDownstream(options WriteOptions) error {
 
 	renderDir := options.DownstreamDir
 
-	_, err = os.Stat(renderDir)
+	// hooks that are new in this version of the midstream need a downstream, even when the
+	// downstream already exists
+	if err := writeHooks(options); err != nil {
+		return errors.Wrap(err, "failed to write hooks")
+	}
+
+	_, err = os.Stat(path.Join(renderDir, "kustomization.yaml"))
 	if err == nil {
 		// We intentionally don't support overwriting downstreams...  this is user-created content
 		// and the user should be intentional about removing it
@@ -49,3 +57,42 @@ func (d *Downstream) WriteDownstream(options WriteOptions) error {
 
 	return nil
 }
+
+// writeHooks writes a kustomization to hooks/<event> for each of the hook events in the midstream
+// that the downstream doesn't already have a hook kustomization for
+func writeHooks(options WriteOptions) error {
+	events, err := base.ListHookEvents(options.MidstreamDir)
+	if err != nil {
+		return errors.Wrap(err, "failed to list hook events")
+	}
+
+	for _, event := range events {
+		hookDir := filepath.Join(options.DownstreamDir, base.HooksDir, event)
+		fileRenderPath := filepath.Join(hookDir, "kustomization.yaml")
+		if _, err := os.Stat(fileRenderPath); err == nil {
+			continue
+		}
+
+		relativeMidstreamDir, err := filepath.Rel(hookDir, filepath.Join(options.MidstreamDir, base.HooksDir, event))
+		if err != nil {
+			return errors.Wrap(err, "failed to determine relative path for midstream hooks from downstream")
+		}
+
+		if err := os.MkdirAll(hookDir, 0744); err != nil {
+			return errors.Wrap(err, "failed to mkdir")
+		}
+
+		kustomization := kustomizetypes.Kustomization{
+			TypeMeta: kustomizetypes.TypeMeta{
+				APIVersion: "kustomize.config.k8s.io/v1beta1",
+				Kind:       "Kustomization",
+			},
+			Bases: []string{relativeMidstreamDir},
+		}
+		if err := k8sutil.WriteKustomizationToFile(&kustomization, fileRenderPath); err != nil {
+			return errors.Wrapf(err, "failed to write %s hook kustomization", event)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,58 @@
+package k8sutil
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+	"sigs.k8s.io/controller-runtime/pkg/client/config"
+)
+
+// GetClusterCapabilities returns the kubernetes version and the group versions served by the cluster
+func GetClusterCapabilities(kubeconfig string) (string, []string, error) {
+	cfg, err := clientcmd.BuildConfigFromFlags("", kubeconfig)
+	if err != nil {
+		return "", nil, errors.Wrap(err, "failed to get cluster config")
+	}
+
+	return getCapabilities(cfg)
+}
+
+// GetCurrentClusterCapabilities returns the kubernetes version and the group versions served by the
+// cluster that kots is running in, or the cluster of the current kubeconfig
+func GetCurrentClusterCapabilities() (string, []string, error) {
+	cfg, err := config.GetConfig()
+	if err != nil {
+		return "", nil, errors.Wrap(err, "failed to get cluster config")
+	}
+
+	return getCapabilities(cfg)
+}
+
+func getCapabilities(cfg *rest.Config) (string, []string, error) {
+	discoveryClient, err := discovery.NewDiscoveryClientForConfig(cfg)
+	if err != nil {
+		return "", nil, errors.Wrap(err, "failed to create discovery client")
+	}
+
+	serverVersion, err := discoveryClient.ServerVersion()
+	if err != nil {
+		return "", nil, errors.Wrap(err, "failed to get server version")
+	}
+
+	groups, err := discoveryClient.ServerGroups()
+	if err != nil {
+		return "", nil, errors.Wrap(err, "failed to get server groups")
+	}
+
+	apiVersions := []string{}
+	for _, group := range groups.Groups {
+		for _, version := range group.Versions {
+			apiVersions = append(apiVersions, version.GroupVersion)
+		}
+	}
+
+	return strings.TrimPrefix(serverVersion.GitVersion, "v"), apiVersions, nil
+}
@@ -0,0 +1,111 @@
+package midstream
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+	"github.com/replicatedhq/kots/pkg/base"
+	"github.com/replicatedhq/kots/pkg/k8sdoc"
+	"github.com/replicatedhq/kots/pkg/k8sutil"
+	kustomizetypes "sigs.k8s.io/kustomize/v3/pkg/types"
+)
+
+// separateHookDocs splits the objects to patch with the pull secret into the objects in the
+// base kustomization, and the objects in each of the hook kustomizations of the base
+func (m *Midstream) separateHookDocs(options WriteOptions) ([]*k8sdoc.Doc, map[string][]*k8sdoc.Doc, error) {
+	events, err := base.ListHookEvents(options.BaseDir)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed to list hook events")
+	}
+
+	hookDocs := map[string][]*k8sdoc.Doc{}
+	inHooks := map[string]bool{}
+	for _, event := range events {
+		resMap, err := base.KustomizeBuild(filepath.Join(options.BaseDir, base.HooksDir, event))
+		if err != nil {
+			return nil, nil, errors.Wrapf(err, "failed to build %s hooks", event)
+		}
+
+		inEvent := map[string]bool{}
+		for _, res := range resMap.Resources() {
+			inEvent[docKey(res.GetKind(), res.GetName())] = true
+		}
+
+		hookDocs[event] = []*k8sdoc.Doc{}
+		for _, doc := range m.DocForPatches {
+			key := docKey(doc.Kind, doc.Metadata.Name)
+			if inEvent[key] {
+				hookDocs[event] = append(hookDocs[event], doc)
+				inHooks[key] = true
+			}
+		}
+	}
+
+	docs := []*k8sdoc.Doc{}
+	for _, doc := range m.DocForPatches {
+		if !inHooks[docKey(doc.Kind, doc.Metadata.Name)] {
+			docs = append(docs, doc)
+		}
+	}
+
+	return docs, hookDocs, nil
+}
+
+// writeHooks writes a kustomization to hooks/<event> for each of the hook events in the base, with
+// the same images and pull secret as the midstream. The hook kustomizations are written by kots on
+// every pull, changes to them should be made in the downstream hook kustomizations.
+func (m *Midstream) writeHooks(options WriteOptions, hookDocs map[string][]*k8sdoc.Doc) error {
+	hooksDir := filepath.Join(options.MidstreamDir, base.HooksDir)
+	if err := os.RemoveAll(hooksDir); err != nil {
+		return errors.Wrap(err, "failed to remove previous hooks")
+	}
+
+	for event, docs := range hookDocs {
+		hookDir := filepath.Join(hooksDir, event)
+		if err := os.MkdirAll(hookDir, 0744); err != nil {
+			return errors.Wrap(err, "failed to mkdir")
+		}
+
+		relativeBaseDir, err := filepath.Rel(hookDir, filepath.Join(options.BaseDir, base.HooksDir, event))
+		if err != nil {
+			return errors.Wrap(err, "failed to determine relative path for base hooks from midstream")
+		}
+
+		kustomization := kustomizetypes.Kustomization{
+			TypeMeta: kustomizetypes.TypeMeta{
+				APIVersion: "kustomize.config.k8s.io/v1beta1",
+				Kind:       "Kustomization",
+			},
+			Bases:  []string{relativeBaseDir},
+			Images: m.Kustomization.Images,
+		}
+
+		// the pull secret is included with the hooks, since pre-install hooks are applied
+		// before the rest of the midstream
+		secretFilename, err := m.writePullSecret(hookDir)
+		if err != nil {
+			return errors.Wrap(err, "failed to write secret")
+		}
+		if secretFilename != "" {
+			kustomization.Resources = append(kustomization.Resources, secretFilename)
+		}
+
+		if err := m.writeObjectsWithPullSecret(hookDir, docs); err != nil {
+			return errors.Wrap(err, "failed to write patches")
+		}
+		if len(docs) > 0 {
+			kustomization.PatchesStrategicMerge = append(kustomization.PatchesStrategicMerge, patchesFilename)
+		}
+
+		if err := k8sutil.WriteKustomizationToFile(&kustomization, filepath.Join(hookDir, "kustomization.yaml")); err != nil {
+			return errors.Wrapf(err, "failed to write %s hook kustomization", event)
+		}
+	}
+
+	return nil
+}
+
+func docKey(kind string, name string) string {
+	return kind + "/" + name
+}
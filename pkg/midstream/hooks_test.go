@@ -0,0 +1,76 @@
+package midstream
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/replicatedhq/kots/pkg/base"
+	"github.com/replicatedhq/kots/pkg/k8sdoc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/kustomize/v3/pkg/image"
+)
+
+func Test_WriteMidstreamHooks(t *testing.T) {
+	req := require.New(t)
+
+	dir, err := ioutil.TempDir("", "kots")
+	req.NoError(err)
+	defer os.RemoveAll(dir)
+
+	b := base.Base{
+		Files: []base.BaseFile{
+			{
+				Path:    "deployment.yaml",
+				Content: []byte("apiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: app\nspec:\n  template:\n    spec:\n      containers:\n      - name: app\n        image: registry.example.com/app:1\n"),
+			},
+			{
+				Path:    "migrate.yaml",
+				Content: []byte("apiVersion: batch/v1\nkind: Job\nmetadata:\n  name: migrate\n  annotations:\n    kots.io/hook: pre-install\nspec:\n  template:\n    spec:\n      containers:\n      - name: migrate\n        image: registry.example.com/migrate:1\n"),
+			},
+		},
+	}
+	baseDir := filepath.Join(dir, "base")
+	req.NoError(b.WriteBase(base.WriteOptions{BaseDir: baseDir}))
+
+	docForPatches := []*k8sdoc.Doc{
+		{APIVersion: "apps/v1", Kind: "Deployment", Metadata: k8sdoc.Metadata{Name: "app"}},
+		{APIVersion: "batch/v1", Kind: "Job", Metadata: k8sdoc.Metadata{Name: "migrate"}},
+	}
+	pullSecret := &corev1.Secret{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "Secret"},
+		ObjectMeta: metav1.ObjectMeta{Name: "kotsadm-replicated-registry"},
+	}
+	images := []image.Image{
+		{Name: "registry.example.com/migrate", NewName: "airgap.example.com/migrate"},
+		{Name: "registry.example.com/app", NewName: "airgap.example.com/app"},
+	}
+
+	m, err := CreateMidstream(&b, images, docForPatches, pullSecret)
+	req.NoError(err)
+
+	midstreamDir := filepath.Join(dir, "overlays", "midstream")
+	req.NoError(m.WriteMidstream(WriteOptions{MidstreamDir: midstreamDir, BaseDir: baseDir}))
+
+	// the midstream only patches the objects in the base kustomization
+	resMap, err := base.KustomizeBuild(midstreamDir)
+	req.NoError(err)
+	req.Len(resMap.Resources(), 2)
+
+	resMap, err = base.KustomizeBuild(filepath.Join(midstreamDir, "hooks", "pre-install"))
+	req.NoError(err)
+	kinds := []string{}
+	for _, res := range resMap.Resources() {
+		kinds = append(kinds, res.GetKind())
+	}
+	assert.ElementsMatch(t, []string{"Job", "Secret"}, kinds)
+
+	content, err := resMap.AsYaml()
+	req.NoError(err)
+	assert.Contains(t, string(content), "image: airgap.example.com/migrate:1")
+	assert.Contains(t, string(content), "imagePullSecrets:\n      - name: kotsadm-replicated-registry")
+}
@@ -45,7 +45,13 @@ func (m *Midstream) WriteMidstream(options WriteOptions) error {
 		return errors.Wrap(err, "failed to mkdir")
 	}
 
-	secretFilename, err := m.writePullSecret(options)
+	// objects in hooks aren't in the base kustomization, so they're patched in the hook overlays
+	docsForPatches, hookDocsForPatches, err := m.separateHookDocs(options)
+	if err != nil {
+		return errors.Wrap(err, "failed to find objects in hooks")
+	}
+
+	secretFilename, err := m.writePullSecret(options.MidstreamDir)
 	if err != nil {
 		return errors.Wrap(err, "failed to write secret")
 	}
@@ -54,9 +60,12 @@ func (m *Midstream) WriteMidstream(options WriteOptions) error {
 		m.Kustomization.Resources = append(m.Kustomization.Resources, secretFilename)
 	}
 
-	if err := m.writeObjectsWithPullSecret(options); err != nil {
+	if err := m.writeObjectsWithPullSecret(options.MidstreamDir, docsForPatches); err != nil {
 		return errors.Wrap(err, "failed to write patches")
 	}
+	if len(docsForPatches) > 0 {
+		m.Kustomization.PatchesStrategicMerge = append(m.Kustomization.PatchesStrategicMerge, patchesFilename)
+	}
 
 	m.mergeKustomization(existingKustomization)
 
@@ -64,6 +73,10 @@ func (m *Midstream) WriteMidstream(options WriteOptions) error {
 		return errors.Wrap(err, "failed to write kustomization")
 	}
 
+	if err := m.writeHooks(options, hookDocsForPatches); err != nil {
+		return errors.Wrap(err, "failed to write hooks")
+	}
+
 	return nil
 }
 
@@ -102,12 +115,12 @@ func (m *Midstream) writeKustomization(options WriteOptions) error {
 	return nil
 }
 
-func (m *Midstream) writePullSecret(options WriteOptions) (string, error) {
+func (m *Midstream) writePullSecret(dir string) (string, error) {
 	if m.PullSecret == nil {
 		return "", nil
 	}
 
-	absFilename := filepath.Join(options.MidstreamDir, secretFilename)
+	absFilename := filepath.Join(dir, secretFilename)
 
 	b, err := k8syaml.Marshal(m.PullSecret)
 	if err != nil {
@@ -121,9 +134,9 @@ func (m *Midstream) writePullSecret(options WriteOptions) (string, error) {
 	return secretFilename, nil
 }
 
-func (m *Midstream) writeObjectsWithPullSecret(options WriteOptions) error {
-	filename := filepath.Join(options.MidstreamDir, patchesFilename)
-	if len(m.DocForPatches) == 0 {
+func (m *Midstream) writeObjectsWithPullSecret(dir string, docs []*k8sdoc.Doc) error {
+	filename := filepath.Join(dir, patchesFilename)
+	if len(docs) == 0 {
 		err := os.Remove(filename)
 		if err != nil && !os.IsNotExist(err) {
 			return errors.Wrap(err, "failed to delete pull secret patches")
@@ -138,7 +151,7 @@ func (m *Midstream) writeObjectsWithPullSecret(options WriteOptions) error {
 	}
 	defer f.Close()
 
-	for _, o := range m.DocForPatches {
+	for _, o := range docs {
 		withPullSecret := obejctWithPullSecret(o, m.PullSecret)
 
 		b, err := yaml.Marshal(withPullSecret)
@@ -154,8 +167,6 @@ func (m *Midstream) writeObjectsWithPullSecret(options WriteOptions) error {
 		}
 	}
 
-	m.Kustomization.PatchesStrategicMerge = append(m.Kustomization.PatchesStrategicMerge, patchesFilename)
-
 	return nil
 }
 
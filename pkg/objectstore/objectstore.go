@@ -0,0 +1,155 @@
+package objectstore
+
+import (
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+	"github.com/pkg/errors"
+)
+
+// Location is a parsed s3://bucket/key uri. The key can also be a prefix or a pattern,
+// depending on where the location is used.
+type Location struct {
+	Bucket string
+	Key    string
+}
+
+func IsURL(uri string) bool {
+	return strings.HasPrefix(uri, "s3://")
+}
+
+func ParseURL(uri string) (*Location, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse uri")
+	}
+
+	if u.Scheme != "s3" {
+		return nil, errors.Errorf("unexpected scheme %q", u.Scheme)
+	}
+	if u.Host == "" {
+		return nil, errors.New("missing bucket")
+	}
+
+	location := Location{
+		Bucket: u.Host,
+		Key:    strings.TrimPrefix(u.Path, "/"),
+	}
+
+	return &location, nil
+}
+
+// NewClient creates a client with the credentials and region from the environment or the shared
+// aws config, the same as the aws cli. KOTS_S3_ENDPOINT can be set to use s3 compatible storage,
+// such as minio.
+func NewClient() (s3iface.S3API, error) {
+	config := aws.NewConfig()
+	if endpoint := os.Getenv("KOTS_S3_ENDPOINT"); endpoint != "" {
+		config = config.WithEndpoint(endpoint).WithS3ForcePathStyle(true)
+	}
+
+	sess, err := session.NewSessionWithOptions(session.Options{
+		Config:            *config,
+		SharedConfigState: session.SharedConfigEnable,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create session")
+	}
+
+	// minio and most other s3 compatible storage ignore the region, but the client requires one
+	if aws.StringValue(sess.Config.Region) == "" {
+		sess.Config.Region = aws.String("us-east-1")
+	}
+
+	return s3.New(sess), nil
+}
+
+// Download writes the content of the object to w. The latest version is downloaded
+// when versionID is empty.
+func Download(client s3iface.S3API, bucket string, key string, versionID string, w io.Writer) error {
+	input := &s3.GetObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	}
+	if versionID != "" {
+		input.VersionId = aws.String(versionID)
+	}
+
+	output, err := client.GetObject(input)
+	if err != nil {
+		return errors.Wrapf(err, "failed to get s3://%s/%s", bucket, key)
+	}
+	defer output.Body.Close()
+
+	if _, err := io.Copy(w, output.Body); err != nil {
+		return errors.Wrapf(err, "failed to download s3://%s/%s", bucket, key)
+	}
+
+	return nil
+}
+
+// DownloadPrefix downloads all of the objects under the prefix to destDir, at their
+// path relative to the prefix
+func DownloadPrefix(client s3iface.S3API, bucket string, prefix string, destDir string) error {
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix = prefix + "/"
+	}
+
+	keys := []string{}
+	input := &s3.ListObjectsV2Input{
+		Bucket: aws.String(bucket),
+		Prefix: aws.String(prefix),
+	}
+	err := client.ListObjectsV2Pages(input, func(page *s3.ListObjectsV2Output, lastPage bool) bool {
+		for _, object := range page.Contents {
+			keys = append(keys, aws.StringValue(object.Key))
+		}
+		return true
+	})
+	if err != nil {
+		return errors.Wrapf(err, "failed to list s3://%s/%s", bucket, prefix)
+	}
+
+	for _, key := range keys {
+		relPath := strings.TrimPrefix(key, prefix)
+		if relPath == "" || strings.HasSuffix(relPath, "/") {
+			continue
+		}
+
+		filename := filepath.Join(destDir, filepath.FromSlash(relPath))
+		if !strings.HasPrefix(filename, filepath.Clean(destDir)+string(os.PathSeparator)) {
+			return errors.Errorf("key %s is outside of the prefix", key)
+		}
+
+		if err := downloadToFile(client, bucket, key, filename); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func downloadToFile(client s3iface.S3API, bucket string, key string, filename string) error {
+	if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+		return errors.Wrap(err, "failed to mkdir")
+	}
+
+	f, err := os.Create(filename)
+	if err != nil {
+		return errors.Wrapf(err, "failed to create %s", filename)
+	}
+	defer f.Close()
+
+	if err := Download(client, bucket, key, "", f); err != nil {
+		return err
+	}
+
+	return f.Close()
+}
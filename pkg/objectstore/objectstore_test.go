@@ -0,0 +1,152 @@
+package objectstore
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_ParseURL(t *testing.T) {
+	tests := []struct {
+		name      string
+		uri       string
+		expected  *Location
+		expectErr bool
+	}{
+		{
+			name:     "key",
+			uri:      "s3://bucket/path/to/app.tar.gz",
+			expected: &Location{Bucket: "bucket", Key: "path/to/app.tar.gz"},
+		},
+		{
+			name:     "bucket only",
+			uri:      "s3://bucket",
+			expected: &Location{Bucket: "bucket", Key: ""},
+		},
+		{
+			name:      "wrong scheme",
+			uri:       "https://bucket/app.tar.gz",
+			expectErr: true,
+		},
+		{
+			name:      "missing bucket",
+			uri:       "s3:///app.tar.gz",
+			expectErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := require.New(t)
+
+			location, err := ParseURL(test.uri)
+			if test.expectErr {
+				req.Error(err)
+				return
+			}
+			req.NoError(err)
+			assert.Equal(t, test.expected, location)
+		})
+	}
+}
+
+type fakeS3 struct {
+	s3iface.S3API
+	objects map[string]string
+}
+
+func (f *fakeS3) GetObject(input *s3.GetObjectInput) (*s3.GetObjectOutput, error) {
+	content, ok := f.objects[aws.StringValue(input.Key)]
+	if !ok {
+		return nil, awserr.New(s3.ErrCodeNoSuchKey, "not found", nil)
+	}
+	return &s3.GetObjectOutput{Body: ioutil.NopCloser(bytes.NewReader([]byte(content)))}, nil
+}
+
+func (f *fakeS3) ListObjectsV2Pages(input *s3.ListObjectsV2Input, fn func(*s3.ListObjectsV2Output, bool) bool) error {
+	page := &s3.ListObjectsV2Output{}
+	for key := range f.objects {
+		if strings.HasPrefix(key, aws.StringValue(input.Prefix)) {
+			page.Contents = append(page.Contents, &s3.Object{Key: aws.String(key)})
+		}
+	}
+	fn(page, true)
+	return nil
+}
+
+func Test_DownloadPrefix(t *testing.T) {
+	tests := []struct {
+		name      string
+		objects   map[string]string
+		expected  map[string]string
+		expectErr bool
+	}{
+		{
+			name: "nested",
+			objects: map[string]string{
+				"bundle/app.tar.gz":       "app",
+				"bundle/images/nginx.tar": "nginx",
+				"bundle/":                 "",
+				"bundle-other/app.tar.gz": "other",
+				"unrelated/manifest.yaml": "unrelated",
+			},
+			expected: map[string]string{
+				"app.tar.gz":       "app",
+				"images/nginx.tar": "nginx",
+			},
+		},
+		{
+			name: "outside of prefix",
+			objects: map[string]string{
+				"bundle/../escape.yaml": "escape",
+			},
+			expectErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := require.New(t)
+
+			destDir, err := ioutil.TempDir("", "kots")
+			req.NoError(err)
+			defer os.RemoveAll(destDir)
+
+			err = DownloadPrefix(&fakeS3{objects: test.objects}, "bucket", "bundle", destDir)
+			if test.expectErr {
+				req.Error(err)
+				return
+			}
+			req.NoError(err)
+
+			actual := map[string]string{}
+			err = filepath.Walk(destDir, func(path string, info os.FileInfo, err error) error {
+				if err != nil || info.IsDir() {
+					return err
+				}
+				content, err := ioutil.ReadFile(path)
+				if err != nil {
+					return err
+				}
+				relPath, err := filepath.Rel(destDir, path)
+				if err != nil {
+					return err
+				}
+				actual[filepath.ToSlash(relPath)] = string(content)
+				return nil
+			})
+			req.NoError(err)
+			assert.Equal(t, test.expected, actual)
+		})
+	}
+}
@@ -0,0 +1,173 @@
+package pull
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+	kotsv1beta1 "github.com/replicatedhq/kots/kotskinds/apis/kots/v1beta1"
+	"github.com/replicatedhq/kots/pkg/base"
+	"github.com/replicatedhq/kots/pkg/upstream"
+	upstreamtypes "github.com/replicatedhq/kots/pkg/upstream/types"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/client-go/kubernetes/scheme"
+)
+
+// reservedComponentNames are the dirs in upstream/ and base/ that kots writes, which upstreams
+// in a composition can't be written to
+var reservedComponentNames = map[string]bool{
+	"userdata":    true,
+	base.HooksDir: true,
+}
+
+// parseCompositionFromFile returns the composition in filename, or nil when filename
+// isn't a file that contains a composition
+func parseCompositionFromFile(filename string) (*kotsv1beta1.Composition, error) {
+	info, err := os.Stat(filename)
+	if err != nil || info.IsDir() {
+		return nil, nil
+	}
+
+	contents, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read composition file")
+	}
+
+	decode := scheme.Codecs.UniversalDeserializer().Decode
+	decoded, gvk, err := decode(contents, nil, nil)
+	if err != nil {
+		return nil, nil
+	}
+
+	if gvk.Group != "kots.io" || gvk.Version != "v1beta1" || gvk.Kind != "Composition" {
+		return nil, nil
+	}
+
+	composition := decoded.(*kotsv1beta1.Composition)
+	if err := validateComposition(composition); err != nil {
+		return nil, errors.Wrap(err, "invalid composition")
+	}
+
+	return composition, nil
+}
+
+func validateComposition(composition *kotsv1beta1.Composition) error {
+	if composition.Name == "" {
+		return errors.New("metadata.name is required")
+	}
+	if len(composition.Spec.Upstreams) == 0 {
+		return errors.New("at least one upstream is required")
+	}
+
+	names := map[string]bool{}
+	for _, component := range composition.Spec.Upstreams {
+		if component.Name == "" || component.URI == "" {
+			return errors.New("upstreams require a name and a uri")
+		}
+		// the name is the dir that the upstream is written to under upstream/ and base/
+		if errs := validation.IsDNS1123Label(component.Name); len(errs) > 0 {
+			return errors.Errorf("upstream name %q is invalid: %s", component.Name, strings.Join(errs, ", "))
+		}
+		if reservedComponentNames[component.Name] {
+			return errors.Errorf("upstream name %q is reserved", component.Name)
+		}
+		if names[component.Name] {
+			return errors.Errorf("upstream %s is listed more than once", component.Name)
+		}
+		names[component.Name] = true
+	}
+
+	return nil
+}
+
+// pullComposition fetches each upstream in the composition to its own dir under upstream/, with its
+// own update cursor, and renders it as a sub-base. The returned base includes all of the sub-bases,
+// so that a single midstream is created for the application.
+func pullComposition(composition *kotsv1beta1.Composition, fetchOptions upstream.FetchOptions, componentCursors map[string]string, writeUpstreamOptions upstreamtypes.WriteOptions, renderOptions base.RenderOptions) (*upstreamtypes.Upstream, *base.Base, error) {
+	log := renderOptions.Log
+
+	// components are named for their upstream, which is also the release name of helm charts,
+	// so they're written to the app dir of the composition
+	componentWriteOptions := writeUpstreamOptions
+	componentWriteOptions.CreateAppDir = false
+	if writeUpstreamOptions.CreateAppDir {
+		componentWriteOptions.RootDir = filepath.Join(writeUpstreamOptions.RootDir, composition.Name)
+	}
+
+	b := base.Base{}
+	for _, component := range composition.Spec.Upstreams {
+		cursor, err := componentCursor(componentCursors, componentWriteOptions.RootDir, component.Name)
+		if err != nil {
+			return nil, nil, errors.Wrapf(err, "failed to get cursor of upstream %s", component.Name)
+		}
+
+		componentFetchOptions := fetchOptions
+		componentFetchOptions.CurrentCursor = cursor
+		if component.HelmRepoURI != "" {
+			componentFetchOptions.HelmRepoURI = component.HelmRepoURI
+		}
+
+		log.ActionWithSpinner("Pulling upstream %s", component.Name)
+		u, err := upstream.FetchUpstream(component.URI, &componentFetchOptions)
+		if err != nil {
+			log.FinishSpinnerWithError()
+			return nil, nil, errors.Wrapf(err, "failed to fetch upstream %s", component.Name)
+		}
+		u.Name = component.Name
+		u.Path = component.Name
+
+		componentBase, err := writeAndRenderComponent(u, componentWriteOptions, renderOptions)
+		u.Cleanup()
+		if err != nil {
+			log.FinishSpinnerWithError()
+			return nil, nil, errors.Wrapf(err, "failed to pull upstream %s", component.Name)
+		}
+		log.FinishSpinner()
+
+		componentBase.Path = component.Name
+		b.Bases = append(b.Bases, *componentBase)
+	}
+
+	u := &upstreamtypes.Upstream{
+		Name: composition.Name,
+		Type: "composition",
+	}
+
+	return u, &b, nil
+}
+
+// componentCursor returns the cursor of an upstream in a composition, from componentCursors when
+// it's pinned there, or else the cursor that it was last pulled at to appDir
+func componentCursor(componentCursors map[string]string, appDir string, name string) (string, error) {
+	if cursor, ok := componentCursors[name]; ok {
+		return cursor, nil
+	}
+	if appDir == "" {
+		return "", nil
+	}
+
+	installation, err := parseInstallationFromFile(filepath.Join(appDir, "upstream", name, "userdata", "installation.yaml"))
+	if err != nil {
+		return "", errors.Wrap(err, "failed to parse installation")
+	}
+	if installation == nil {
+		return "", nil
+	}
+
+	return installation.Spec.UpdateCursor, nil
+}
+
+func writeAndRenderComponent(u *upstreamtypes.Upstream, writeUpstreamOptions upstreamtypes.WriteOptions, renderOptions base.RenderOptions) (*base.Base, error) {
+	if err := upstream.WriteUpstream(u, writeUpstreamOptions); err != nil {
+		return nil, errors.Wrap(err, "failed to write upstream")
+	}
+
+	b, err := base.RenderUpstream(u, &renderOptions)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to render upstream")
+	}
+
+	return b, nil
+}
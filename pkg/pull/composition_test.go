@@ -0,0 +1,206 @@
+package pull
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/replicatedhq/kots/pkg/k8sutil"
+	"github.com/replicatedhq/kots/pkg/upstream"
+	upstreamtypes "github.com/replicatedhq/kots/pkg/upstream/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_PullComposition(t *testing.T) {
+	req := require.New(t)
+
+	dir, err := ioutil.TempDir("", "kots")
+	req.NoError(err)
+	defer os.RemoveAll(dir)
+
+	for name, content := range map[string]string{
+		"app/deployment.yaml":    "apiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: app\n",
+		"addons/configmap.yaml":  "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: addons\n",
+		"composition.yaml":       "apiVersion: kots.io/v1beta1\nkind: Composition\nmetadata:\n  name: product\nspec:\n  upstreams:\n  - name: app\n    uri: " + filepath.Join(dir, "app") + "\n  - name: addons\n    uri: " + filepath.Join(dir, "addons") + "\n",
+		"not-a-composition.yaml": "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: other\n",
+	} {
+		req.NoError(os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0755))
+		req.NoError(ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644))
+	}
+
+	composition, err := parseCompositionFromFile(filepath.Join(dir, "not-a-composition.yaml"))
+	req.NoError(err)
+	assert.Nil(t, composition)
+
+	rootDir := filepath.Join(dir, "out")
+	renderDir, err := Pull(filepath.Join(dir, "composition.yaml"), PullOptions{
+		RootDir:      rootDir,
+		Downstreams:  []string{"this-cluster"},
+		CreateAppDir: true,
+		Silent:       true,
+	})
+	req.NoError(err)
+	assert.Equal(t, filepath.Join(rootDir, "product"), renderDir)
+
+	// each upstream has its own installation, to keep its update cursor
+	for _, name := range []string{"app", "addons"} {
+		_, err := os.Stat(filepath.Join(renderDir, "upstream", name, "userdata", "installation.yaml"))
+		assert.NoError(t, err, name)
+	}
+
+	k, err := k8sutil.ReadKustomizationFromFile(filepath.Join(renderDir, "base", "kustomization.yaml"))
+	req.NoError(err)
+	assert.Equal(t, []string{"addons", "app"}, k.Bases)
+
+	k, err = k8sutil.ReadKustomizationFromFile(filepath.Join(renderDir, "base", "app", "kustomization.yaml"))
+	req.NoError(err)
+	assert.Equal(t, []string{"deployment.yaml"}, k.Resources)
+
+	k, err = k8sutil.ReadKustomizationFromFile(filepath.Join(renderDir, "overlays", "midstream", "kustomization.yaml"))
+	req.NoError(err)
+	assert.Equal(t, []string{"../../base"}, k.Bases)
+}
+
+// releasesFetcher has the same releases for every upstream, and records the cursors that each
+// upstream was fetched and listed from
+type releasesFetcher struct {
+	releases []string
+	fetched  map[string]string
+	listed   map[string]string
+}
+
+func (f *releasesFetcher) GetUpdates(upstreamURI string, fetchOptions *upstream.FetchOptions) ([]upstream.Update, error) {
+	f.listed[upstreamURI] = fetchOptions.CurrentCursor
+
+	updates := []upstream.Update{}
+	found := fetchOptions.CurrentCursor == ""
+	for _, release := range f.releases {
+		if found {
+			updates = append(updates, upstream.Update{Cursor: release, VersionLabel: release})
+		}
+		if release == fetchOptions.CurrentCursor {
+			found = true
+		}
+	}
+	return updates, nil
+}
+
+func (f *releasesFetcher) Fetch(upstreamURI string, fetchOptions *upstream.FetchOptions) (*upstreamtypes.Upstream, error) {
+	f.fetched[upstreamURI] = fetchOptions.CurrentCursor
+
+	cursor := fetchOptions.CurrentCursor
+	if cursor == "" {
+		cursor = f.releases[len(f.releases)-1]
+	}
+	return &upstreamtypes.Upstream{
+		URI:          upstreamURI,
+		Type:         "local",
+		UpdateCursor: cursor,
+		VersionLabel: cursor,
+		Files: []upstreamtypes.UpstreamFile{
+			{
+				Path:    "configmap.yaml",
+				Content: []byte(fmt.Sprintf("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: %s\n", filepath.Base(upstreamURI))),
+			},
+		},
+	}, nil
+}
+
+func Test_PullCompositionKeepsComponentCursors(t *testing.T) {
+	req := require.New(t)
+
+	dir, err := ioutil.TempDir("", "kots")
+	req.NoError(err)
+	defer os.RemoveAll(dir)
+
+	fetcher := &releasesFetcher{
+		releases: []string{"1", "2"},
+		fetched:  map[string]string{},
+		listed:   map[string]string{},
+	}
+	upstream.RegisterFetcher("releases", fetcher)
+
+	compositionFile := filepath.Join(dir, "composition.yaml")
+	composition := "apiVersion: kots.io/v1beta1\nkind: Composition\nmetadata:\n  name: product\nspec:\n  upstreams:\n  - name: app\n    uri: releases://app\n  - name: addons\n    uri: releases://addons\n"
+	req.NoError(ioutil.WriteFile(compositionFile, []byte(composition), 0644))
+
+	rootDir := filepath.Join(dir, "out")
+	pullOptions := PullOptions{
+		RootDir:          rootDir,
+		Downstreams:      []string{"this-cluster"},
+		CreateAppDir:     true,
+		ComponentCursors: map[string]string{"app": "1"},
+		Silent:           true,
+	}
+	renderDir, err := Pull(compositionFile, pullOptions)
+	req.NoError(err)
+	assert.Equal(t, map[string]string{"releases://app": "1", "releases://addons": ""}, fetcher.fetched)
+
+	// the second pull fetches each upstream at the cursor that it was installed at
+	pullOptions.ComponentCursors = nil
+	_, err = Pull(compositionFile, pullOptions)
+	req.NoError(err)
+	assert.Equal(t, map[string]string{"releases://app": "1", "releases://addons": "2"}, fetcher.fetched)
+
+	updates, err := GetUpdates(compositionFile, GetUpdatesOptions{
+		AppDir: renderDir,
+		Silent: true,
+	})
+	req.NoError(err)
+	assert.Equal(t, map[string]string{"releases://app": "1", "releases://addons": "2"}, fetcher.listed)
+	assert.Equal(t, []upstream.Update{{Component: "app", Cursor: "2", VersionLabel: "2"}}, updates)
+}
+
+func Test_validateComposition(t *testing.T) {
+	tests := []struct {
+		name        string
+		composition string
+		expectedErr string
+	}{
+		{
+			name:        "no upstreams",
+			composition: "apiVersion: kots.io/v1beta1\nkind: Composition\nmetadata:\n  name: product\n",
+			expectedErr: "at least one upstream is required",
+		},
+		{
+			name:        "duplicate upstream",
+			composition: "apiVersion: kots.io/v1beta1\nkind: Composition\nmetadata:\n  name: product\nspec:\n  upstreams:\n  - name: app\n    uri: replicated://app\n  - name: app\n    uri: helm://stable/redis\n",
+			expectedErr: "upstream app is listed more than once",
+		},
+		{
+			name:        "upstream name outside of the app dir",
+			composition: "apiVersion: kots.io/v1beta1\nkind: Composition\nmetadata:\n  name: product\nspec:\n  upstreams:\n  - name: ../app\n    uri: replicated://app\n",
+			expectedErr: `upstream name "../app" is invalid`,
+		},
+		{
+			name:        "upstream name with a dir",
+			composition: "apiVersion: kots.io/v1beta1\nkind: Composition\nmetadata:\n  name: product\nspec:\n  upstreams:\n  - name: a/b\n    uri: replicated://app\n",
+			expectedErr: `upstream name "a/b" is invalid`,
+		},
+		{
+			name:        "upstream name that kots writes to",
+			composition: "apiVersion: kots.io/v1beta1\nkind: Composition\nmetadata:\n  name: product\nspec:\n  upstreams:\n  - name: userdata\n    uri: replicated://app\n",
+			expectedErr: `upstream name "userdata" is reserved`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := require.New(t)
+
+			f, err := ioutil.TempFile("", "composition")
+			req.NoError(err)
+			defer os.Remove(f.Name())
+			_, err = f.WriteString(test.composition)
+			req.NoError(err)
+			req.NoError(f.Close())
+
+			_, err = parseCompositionFromFile(f.Name())
+			req.Error(err)
+			assert.Contains(t, err.Error(), test.expectedErr)
+		})
+	}
+}
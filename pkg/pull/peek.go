@@ -2,17 +2,24 @@ package pull
 
 import (
 	"github.com/pkg/errors"
+	kotsv1beta1 "github.com/replicatedhq/kots/kotskinds/apis/kots/v1beta1"
 	"github.com/replicatedhq/kots/pkg/logger"
 	"github.com/replicatedhq/kots/pkg/upstream"
 )
 
 type GetUpdatesOptions struct {
-	HelmRepoURI   string
-	Namespace     string
-	LocalPath     string
-	LicenseFile   string
-	CurrentCursor string
-	Silent        bool
+	HelmRepoURI       string
+	HelmRepoOptions   upstream.HelmRepoOptions
+	HTTPClientOptions upstream.HTTPClientOptions
+	Namespace         string
+	LocalPath         string
+	LicenseFile       string
+	CurrentCursor     string
+	// ComponentCursors are the current cursors of the upstreams in a composition, by upstream name.
+	// Upstreams that aren't in it are listed from the cursor they were pulled at to AppDir.
+	ComponentCursors map[string]string
+	AppDir           string
+	Silent           bool
 }
 
 // GetUpdates will retrieve all later versions of the application specified in upstreamURI
@@ -28,6 +35,8 @@ func GetUpdates(upstreamURI string, getUpdatesOptions GetUpdatesOptions) ([]upst
 
 	fetchOptions := upstream.FetchOptions{}
 	fetchOptions.HelmRepoURI = getUpdatesOptions.HelmRepoURI
+	fetchOptions.HelmRepoOptions = getUpdatesOptions.HelmRepoOptions
+	fetchOptions.HTTPClientOptions = getUpdatesOptions.HTTPClientOptions
 	fetchOptions.LocalPath = getUpdatesOptions.LocalPath
 	fetchOptions.CurrentCursor = getUpdatesOptions.CurrentCursor
 
@@ -46,6 +55,14 @@ func GetUpdates(upstreamURI string, getUpdatesOptions GetUpdatesOptions) ([]upst
 		fetchOptions.License = license
 	}
 
+	composition, err := parseCompositionFromFile(upstreamURI)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse composition")
+	}
+	if composition != nil {
+		return getCompositionUpdates(composition, fetchOptions, getUpdatesOptions.ComponentCursors, getUpdatesOptions.AppDir, log)
+	}
+
 	log.ActionWithSpinner("Listing releases")
 	v, err := upstream.GetUpdatesUpstream(upstreamURI, &fetchOptions)
 	if err != nil {
@@ -55,3 +72,35 @@ func GetUpdates(upstreamURI string, getUpdatesOptions GetUpdatesOptions) ([]upst
 
 	return v, nil
 }
+
+// getCompositionUpdates lists the updates of each upstream in the composition, from its own cursor
+func getCompositionUpdates(composition *kotsv1beta1.Composition, fetchOptions upstream.FetchOptions, componentCursors map[string]string, appDir string, log *logger.Logger) ([]upstream.Update, error) {
+	updates := []upstream.Update{}
+	for _, component := range composition.Spec.Upstreams {
+		cursor, err := componentCursor(componentCursors, appDir, component.Name)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to get cursor of upstream %s", component.Name)
+		}
+
+		componentFetchOptions := fetchOptions
+		componentFetchOptions.CurrentCursor = cursor
+		if component.HelmRepoURI != "" {
+			componentFetchOptions.HelmRepoURI = component.HelmRepoURI
+		}
+
+		log.ActionWithSpinner("Listing releases of %s", component.Name)
+		componentUpdates, err := upstream.GetUpdatesUpstream(component.URI, &componentFetchOptions)
+		if err != nil {
+			log.FinishSpinnerWithError()
+			return nil, errors.Wrapf(err, "failed to fetch upstream %s", component.Name)
+		}
+		log.FinishSpinner()
+
+		for _, update := range componentUpdates {
+			update.Component = component.Name
+			updates = append(updates, update)
+		}
+	}
+
+	return updates, nil
+}
@@ -10,29 +10,39 @@ import (
 	"github.com/pkg/errors"
 	kotsv1beta1 "github.com/replicatedhq/kots/kotskinds/apis/kots/v1beta1"
 	"github.com/replicatedhq/kots/pkg/base"
+	kotscrypto "github.com/replicatedhq/kots/pkg/crypto"
 	"github.com/replicatedhq/kots/pkg/docker/registry"
 	"github.com/replicatedhq/kots/pkg/downstream"
 	"github.com/replicatedhq/kots/pkg/k8sdoc"
 	"github.com/replicatedhq/kots/pkg/logger"
 	"github.com/replicatedhq/kots/pkg/midstream"
 	"github.com/replicatedhq/kots/pkg/upstream"
+	"github.com/replicatedhq/kots/pkg/upstream/cache"
 	upstreamtypes "github.com/replicatedhq/kots/pkg/upstream/types"
+	"github.com/replicatedhq/kots/pkg/validate"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/client-go/kubernetes/scheme"
 	"sigs.k8s.io/kustomize/v3/pkg/image"
 )
 
 type PullOptions struct {
-	HelmRepoURI         string
-	RootDir             string
-	Namespace           string
-	Downstreams         []string
-	LocalPath           string
-	LicenseFile         string
-	InstallationFile    string
-	AirgapRoot          string
-	ConfigFile          string
-	UpdateCursor        string
+	HelmRepoURI       string
+	HelmRepoOptions   upstream.HelmRepoOptions
+	HTTPClientOptions upstream.HTTPClientOptions
+	Cache             *cache.Cache
+	// SkipReleaseSignature downloads releases without verifying them against the app public key
+	SkipReleaseSignature bool
+	RootDir              string
+	Namespace            string
+	Downstreams          []string
+	LocalPath            string
+	LicenseFile          string
+	InstallationFile     string
+	AirgapRoot           string
+	ConfigFile           string
+	UpdateCursor         string
+	// ComponentCursors pins the upstreams of a composition to a cursor, by upstream name
+	ComponentCursors    map[string]string
 	ExcludeKotsKinds    bool
 	ExcludeAdminConsole bool
 	SharedPassword      string
@@ -41,7 +51,15 @@ type PullOptions struct {
 	RewriteImages       bool
 	RewriteImageOptions RewriteImageOptions
 	HelmOptions         []string
-	ReportWriter        io.Writer
+	JsonnetTLAs         []string
+	JsonnetExtVars      []string
+	KubernetesVersion   string
+	APIVersions         []string
+	// Validate checks the rendered resources against the schemas of KubernetesVersion in SchemaDir
+	// before they are written
+	Validate     bool
+	SchemaDir    string
+	ReportWriter io.Writer
 }
 
 type RewriteImageOptions struct {
@@ -54,7 +72,7 @@ type RewriteImageOptions struct {
 
 // PullApplicationMetadata will return the application metadata yaml, if one is
 // available for the upstream
-func PullApplicationMetadata(upstreamURI string) ([]byte, error) {
+func PullApplicationMetadata(upstreamURI string, httpClientOptions upstream.HTTPClientOptions) ([]byte, error) {
 	u, err := url.ParseRequestURI(upstreamURI)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to parse uri")
@@ -65,7 +83,7 @@ func PullApplicationMetadata(upstreamURI string) ([]byte, error) {
 		return nil, nil
 	}
 
-	data, err := upstream.GetApplicationMetadata(u)
+	data, err := upstream.GetApplicationMetadata(u, httpClientOptions)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to get application metadata")
 	}
@@ -101,17 +119,22 @@ func Pull(upstreamURI string, pullOptions PullOptions) (string, error) {
 
 	log.Initialize()
 
-	uri, err := url.ParseRequestURI(upstreamURI)
+	// local paths are not valid request uris, but are still valid upstreams
+	uri, err := url.Parse(upstreamURI)
 	if err != nil {
 		return "", errors.Wrap(err, "failed to parse uri")
 	}
 
 	fetchOptions := upstream.FetchOptions{}
 	fetchOptions.HelmRepoURI = pullOptions.HelmRepoURI
+	fetchOptions.HelmRepoOptions = pullOptions.HelmRepoOptions
+	fetchOptions.HTTPClientOptions = pullOptions.HTTPClientOptions
+	fetchOptions.Cache = pullOptions.Cache
 	fetchOptions.RootDir = pullOptions.RootDir
 	fetchOptions.UseAppDir = pullOptions.CreateAppDir
 	fetchOptions.LocalPath = pullOptions.LocalPath
 	fetchOptions.CurrentCursor = pullOptions.UpdateCursor
+	fetchOptions.SkipReleaseSignature = pullOptions.SkipReleaseSignature
 
 	if pullOptions.LicenseFile != "" {
 		license, err := parseLicenseFromFile(pullOptions.LicenseFile)
@@ -126,6 +149,9 @@ func Pull(upstreamURI string, pullOptions PullOptions) (string, error) {
 		}
 
 		fetchOptions.License = license
+
+		// a license without a key can't verify releases, and fails with the error if one is downloaded
+		fetchOptions.AppPublicKey, fetchOptions.AppPublicKeyErr = GetAppPublicKey(license)
 	}
 	if pullOptions.ConfigFile != "" {
 		config, err := parseConfigValuesFromFile(pullOptions.ConfigFile)
@@ -141,6 +167,14 @@ func Pull(upstreamURI string, pullOptions PullOptions) (string, error) {
 		}
 		if installation != nil {
 			fetchOptions.EncryptionKey = installation.Spec.EncryptionKey
+
+			// an application that's pulled again is rendered for the same cluster, unless another is set
+			if pullOptions.KubernetesVersion == "" {
+				pullOptions.KubernetesVersion = installation.Spec.KubernetesVersion
+			}
+			if len(pullOptions.APIVersions) == 0 {
+				pullOptions.APIVersions = installation.Spec.APIVersions
+			}
 		}
 	}
 
@@ -157,43 +191,70 @@ func Pull(upstreamURI string, pullOptions PullOptions) (string, error) {
 		fetchOptions.Airgap = airgap
 	}
 
-	log.ActionWithSpinner("Pulling upstream")
-	u, err := upstream.FetchUpstream(upstreamURI, &fetchOptions)
+	composition, err := parseCompositionFromFile(upstreamURI)
 	if err != nil {
-		log.FinishSpinnerWithError()
-		return "", errors.Wrap(err, "failed to fetch upstream")
+		return "", errors.Wrap(err, "failed to parse composition")
 	}
 
-	includeAdminConsole := uri.Scheme == "replicated" && !pullOptions.ExcludeAdminConsole
+	// the admin console is only included for a single replicated upstream
+	includeAdminConsole := composition == nil && uri.Scheme == "replicated" && !pullOptions.ExcludeAdminConsole
 
 	writeUpstreamOptions := upstreamtypes.WriteOptions{
 		RootDir:             pullOptions.RootDir,
 		CreateAppDir:        pullOptions.CreateAppDir,
 		IncludeAdminConsole: includeAdminConsole,
 		SharedPassword:      pullOptions.SharedPassword,
+		KubernetesVersion:   pullOptions.KubernetesVersion,
+		APIVersions:         pullOptions.APIVersions,
 	}
-	if err := upstream.WriteUpstream(u, writeUpstreamOptions); err != nil {
-		log.FinishSpinnerWithError()
-		return "", errors.Wrap(err, "failed to write upstream")
-	}
-	log.FinishSpinner()
-
-	replicatedRegistryInfo := registry.ProxyEndpointFromLicense(fetchOptions.License)
 
 	renderOptions := base.RenderOptions{
 		SplitMultiDocYAML: true,
 		Namespace:         pullOptions.Namespace,
 		HelmOptions:       pullOptions.HelmOptions,
+		JsonnetTLAs:       pullOptions.JsonnetTLAs,
+		JsonnetExtVars:    pullOptions.JsonnetExtVars,
+		KubernetesVersion: pullOptions.KubernetesVersion,
+		APIVersions:       pullOptions.APIVersions,
 		Log:               log,
 	}
-	log.ActionWithSpinner("Creating base")
 
-	b, err := base.RenderUpstream(u, &renderOptions)
-	if err != nil {
-		return "", errors.Wrap(err, "failed to render upstream")
+	var u *upstreamtypes.Upstream
+	var b *base.Base
+	if composition != nil {
+		u, b, err = pullComposition(composition, fetchOptions, pullOptions.ComponentCursors, writeUpstreamOptions, renderOptions)
+		if err != nil {
+			return "", errors.Wrap(err, "failed to pull composition")
+		}
+	} else {
+		u, b, err = pullUpstream(upstreamURI, fetchOptions, writeUpstreamOptions, renderOptions)
+		if err != nil {
+			return "", err
+		}
 	}
+	defer u.Cleanup()
 
-	log.FinishSpinner()
+	if pullOptions.Validate {
+		log.ActionWithSpinner("Validating resources")
+		validationErrors, err := validate.ValidateBase(b, validate.Options{
+			SchemaDir:         pullOptions.SchemaDir,
+			KubernetesVersion: pullOptions.KubernetesVersion,
+		})
+		if err != nil {
+			log.FinishSpinnerWithError()
+			return "", errors.Wrap(err, "failed to validate base")
+		}
+		if len(validationErrors) > 0 {
+			log.FinishSpinnerWithError()
+			for _, validationError := range validationErrors {
+				log.ChildActionWithoutSpinner("%s", validationError.Error())
+			}
+			return "", errors.Errorf("%d resources failed validation", len(validationErrors))
+		}
+		log.FinishSpinner()
+	}
+
+	replicatedRegistryInfo := registry.ProxyEndpointFromLicense(fetchOptions.License)
 
 	writeBaseOptions := base.WriteOptions{
 		BaseDir:          u.GetBaseDir(writeUpstreamOptions),
@@ -387,6 +448,37 @@ func Pull(upstreamURI string, pullOptions PullOptions) (string, error) {
 	return filepath.Join(pullOptions.RootDir, u.Name), nil
 }
 
+// pullUpstream fetches the upstream, writes it to the upstream dir and renders it as a base
+func pullUpstream(upstreamURI string, fetchOptions upstream.FetchOptions, writeUpstreamOptions upstreamtypes.WriteOptions, renderOptions base.RenderOptions) (*upstreamtypes.Upstream, *base.Base, error) {
+	log := renderOptions.Log
+
+	log.ActionWithSpinner("Pulling upstream")
+	u, err := upstream.FetchUpstream(upstreamURI, &fetchOptions)
+	if err != nil {
+		log.FinishSpinnerWithError()
+		return nil, nil, errors.Wrap(err, "failed to fetch upstream")
+	}
+
+	if err := upstream.WriteUpstream(u, writeUpstreamOptions); err != nil {
+		log.FinishSpinnerWithError()
+		u.Cleanup()
+		return nil, nil, errors.Wrap(err, "failed to write upstream")
+	}
+	log.FinishSpinner()
+
+	log.ActionWithSpinner("Creating base")
+
+	b, err := base.RenderUpstream(u, &renderOptions)
+	if err != nil {
+		u.Cleanup()
+		return nil, nil, errors.Wrap(err, "failed to render upstream")
+	}
+
+	log.FinishSpinner()
+
+	return u, b, nil
+}
+
 func parseLicenseFromFile(filename string) (*kotsv1beta1.License, error) {
 	contents, err := ioutil.ReadFile(filename)
 	if err != nil {
@@ -521,7 +613,7 @@ func publicKeysMatch(license *kotsv1beta1.License, airgap *kotsv1beta1.Airgap) e
 		return errors.Wrap(err, "failed to get public key from license")
 	}
 
-	if err := verify([]byte(license.Spec.AppSlug), []byte(airgap.Spec.Signature), publicKey); err != nil {
+	if err := kotscrypto.VerifySignature([]byte(license.Spec.AppSlug), []byte(airgap.Spec.Signature), publicKey); err != nil {
 		return errors.Wrap(err, "failed to verify bundle signature")
 	}
 
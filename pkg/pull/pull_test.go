@@ -0,0 +1,51 @@
+package pull
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_PullKeepsClusterCapabilities(t *testing.T) {
+	req := require.New(t)
+
+	dir, err := ioutil.TempDir("", "kots")
+	req.NoError(err)
+	defer os.RemoveAll(dir)
+
+	srcDir := filepath.Join(dir, "manifests")
+	req.NoError(os.MkdirAll(srcDir, 0755))
+	req.NoError(ioutil.WriteFile(filepath.Join(srcDir, "configmap.yaml"), []byte("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: app\n"), 0644))
+
+	rootDir := filepath.Join(dir, "out")
+	_, err = Pull(srcDir, PullOptions{
+		RootDir:           rootDir,
+		KubernetesVersion: "1.18.2",
+		APIVersions:       []string{"v1", "networking.k8s.io/v1"},
+		Silent:            true,
+	})
+	req.NoError(err)
+
+	installationFile := filepath.Join(rootDir, "upstream", "userdata", "installation.yaml")
+	installation, err := parseInstallationFromFile(installationFile)
+	req.NoError(err)
+	assert.Equal(t, "1.18.2", installation.Spec.KubernetesVersion)
+	assert.Equal(t, []string{"v1", "networking.k8s.io/v1"}, installation.Spec.APIVersions)
+
+	// pulling again from the installation renders for the same cluster
+	_, err = Pull(srcDir, PullOptions{
+		RootDir:          rootDir,
+		InstallationFile: installationFile,
+		Silent:           true,
+	})
+	req.NoError(err)
+
+	installation, err = parseInstallationFromFile(installationFile)
+	req.NoError(err)
+	assert.Equal(t, "1.18.2", installation.Spec.KubernetesVersion)
+	assert.Equal(t, []string{"v1", "networking.k8s.io/v1"}, installation.Spec.APIVersions)
+}
@@ -1,20 +1,17 @@
 package pull
 
 import (
-	"crypto"
-	"crypto/rsa"
-	"crypto/x509"
 	"encoding/json"
-	"encoding/pem"
 	"fmt"
 
 	"github.com/pkg/errors"
 	kotsv1beta1 "github.com/replicatedhq/kots/kotskinds/apis/kots/v1beta1"
+	kotscrypto "github.com/replicatedhq/kots/pkg/crypto"
 )
 
 var (
-	ErrSignatureInvalid = errors.New("signature is invalid")
-	ErrSignatureMissing = errors.New("signature is missing")
+	ErrSignatureInvalid = kotscrypto.ErrSignatureInvalid
+	ErrSignatureMissing = kotscrypto.ErrSignatureMissing
 )
 
 type InnerSignature struct {
@@ -59,11 +56,11 @@ func VerifySignature(license *kotsv1beta1.License) (*kotsv1beta1.License, error)
 		return nil, errors.New("unknown global key")
 	}
 
-	if err := verify([]byte(innerSignature.PublicKey), keySignature.Signature, globalKeyPEM); err != nil {
+	if err := kotscrypto.VerifySignature([]byte(innerSignature.PublicKey), keySignature.Signature, globalKeyPEM); err != nil {
 		return nil, errors.Wrap(err, "failed to verify key signature")
 	}
 
-	if err := verify(outerSignature.LicenseData, innerSignature.LicenseSignature, []byte(innerSignature.PublicKey)); err != nil {
+	if err := kotscrypto.VerifySignature(outerSignature.LicenseData, innerSignature.LicenseSignature, []byte(innerSignature.PublicKey)); err != nil {
 		return nil, errors.Wrap(err, "failed to verify license signature")
 	}
 
@@ -82,30 +79,6 @@ func VerifySignature(license *kotsv1beta1.License) (*kotsv1beta1.License, error)
 	return verifiedLicense, nil
 }
 
-func verify(message, signature, publicKeyPEM []byte) error {
-	pubBlock, _ := pem.Decode(publicKeyPEM)
-	publicKey, err := x509.ParsePKIXPublicKey(pubBlock.Bytes)
-	if err != nil {
-		return errors.Wrap(err, "failed to load public key from PEM")
-	}
-
-	var opts rsa.PSSOptions
-	opts.SaltLength = rsa.PSSSaltLengthAuto
-
-	newHash := crypto.MD5
-	pssh := newHash.New()
-	pssh.Write(message)
-	hashed := pssh.Sum(nil)
-
-	err = rsa.VerifyPSS(publicKey.(*rsa.PublicKey), newHash, hashed, signature, &opts)
-	if err != nil {
-		// this ordering makes errors.Cause a little more useful
-		return errors.Wrap(ErrSignatureInvalid, err.Error())
-	}
-
-	return nil
-}
-
 func verifyLicenseData(outerLicense *kotsv1beta1.License, innerLicense *kotsv1beta1.License) error {
 	if outerLicense.Spec.AppSlug != innerLicense.Spec.AppSlug {
 		return errors.New("\"appSlug\" field has changed")
@@ -175,7 +148,7 @@ func VerifyOldSignature(license *kotsv1beta1.License) (*kotsv1beta1.License, err
 		return nil, errors.New("unknown global key")
 	}
 
-	if err := verify([]byte(signature.PublicKey), keySignature.Signature, globalKeyPEM); err != nil {
+	if err := kotscrypto.VerifySignature([]byte(signature.PublicKey), keySignature.Signature, globalKeyPEM); err != nil {
 		return nil, errors.Wrap(err, "failed to verify key signature")
 	}
 
@@ -184,7 +157,7 @@ func VerifyOldSignature(license *kotsv1beta1.License) (*kotsv1beta1.License, err
 		return nil, errors.Wrap(err, "failed to convert license to message")
 	}
 
-	if err := verify(licenseMessage, signature.LicenseSignature, []byte(signature.PublicKey)); err != nil {
+	if err := kotscrypto.VerifySignature(licenseMessage, signature.LicenseSignature, []byte(signature.PublicKey)); err != nil {
 		return nil, errors.Wrap(err, "failed to verify license signature")
 	}
 
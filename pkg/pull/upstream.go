@@ -3,10 +3,35 @@ package pull
 import (
 	"fmt"
 
+	"github.com/replicatedhq/kots/pkg/base"
+	"github.com/replicatedhq/kots/pkg/upstream"
 	"github.com/replicatedhq/kots/pkg/util"
 )
 
+// RegisterUpstreamType makes upstream uris with the scheme available to Pull and GetUpdates.
+// The upstreams that the fetcher returns must have upstreamType set, so that they are
+// rendered by the renderer.
+func RegisterUpstreamType(scheme string, upstreamType string, fetcher upstream.Fetcher, renderer base.Renderer) {
+	upstream.RegisterFetcher(scheme, fetcher)
+	base.RegisterRenderer(upstreamType, renderer)
+}
+
 func RewriteUpstream(upstreamURI string) string {
+	// local paths are stored as absolute paths, so that the upstream doesn't depend on the
+	// dir that kots was run in
+	if util.IsLocalPath(upstreamURI) {
+		localPath, err := util.LocalPath(upstreamURI)
+		if err != nil {
+			return upstreamURI
+		}
+		return localPath
+	}
+
+	// a composition file lists the upstreams itself
+	if composition, _ := parseCompositionFromFile(upstreamURI); composition != nil {
+		return upstreamURI
+	}
+
 	if !util.IsURL(upstreamURI) {
 		upstreamURI = fmt.Sprintf("replicated://%s", upstreamURI)
 	}
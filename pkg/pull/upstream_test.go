@@ -1,12 +1,25 @@
 package pull
 
 import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestRewriteUpstream(t *testing.T) {
+	// a dir with the name of an app slug, such as the dir an app was pulled to, isn't a local upstream
+	slugDir, err := ioutil.TempDir(".", "app-slug")
+	require.NoError(t, err)
+	defer os.RemoveAll(slugDir)
+	slug := filepath.Base(slugDir)
+
+	cwd, err := os.Getwd()
+	require.NoError(t, err)
+
 	tests := []struct {
 		upstreamURI string
 		expected    string
@@ -23,6 +36,22 @@ func TestRewriteUpstream(t *testing.T) {
 			upstreamURI: "helm://stable/mysql",
 			expected:    "helm://stable/mysql",
 		},
+		{
+			upstreamURI: os.TempDir(),
+			expected:    os.TempDir(),
+		},
+		{
+			upstreamURI: slug,
+			expected:    "replicated://" + slug,
+		},
+		{
+			upstreamURI: "./" + slug,
+			expected:    filepath.Join(cwd, slug),
+		},
+		{
+			upstreamURI: "file://" + os.TempDir(),
+			expected:    os.TempDir(),
+		},
 	}
 	for _, test := range tests {
 		t.Run(test.upstreamURI, func(t *testing.T) {
@@ -12,7 +12,9 @@ import (
 	"github.com/replicatedhq/kots/pkg/k8sdoc"
 	"github.com/replicatedhq/kots/pkg/logger"
 	"github.com/replicatedhq/kots/pkg/midstream"
+	"github.com/replicatedhq/kots/pkg/pull"
 	"github.com/replicatedhq/kots/pkg/upstream"
+	"github.com/replicatedhq/kots/pkg/upstream/cache"
 	upstreamtypes "github.com/replicatedhq/kots/pkg/upstream/types"
 	corev1 "k8s.io/api/core/v1"
 	"sigs.k8s.io/kustomize/v3/pkg/image"
@@ -36,6 +38,13 @@ type RewriteOptions struct {
 	RegistryUsername  string
 	RegistryPassword  string
 	RegistryNamespace string
+	Cache             *cache.Cache
+	// SkipReleaseSignature downloads releases without verifying them against the app public key
+	SkipReleaseSignature bool
+	// KubernetesVersion and APIVersions are the target cluster to render for, and default to the
+	// cluster that the installation was rendered for
+	KubernetesVersion string
+	APIVersions       []string
 }
 
 func Rewrite(rewriteOptions RewriteOptions) error {
@@ -47,13 +56,27 @@ func Rewrite(rewriteOptions RewriteOptions) error {
 
 	log.Initialize()
 
+	kubernetesVersion := rewriteOptions.KubernetesVersion
+	if kubernetesVersion == "" {
+		kubernetesVersion = rewriteOptions.Installation.Spec.KubernetesVersion
+	}
+	apiVersions := rewriteOptions.APIVersions
+	if len(apiVersions) == 0 {
+		apiVersions = rewriteOptions.Installation.Spec.APIVersions
+	}
+
 	fetchOptions := &upstream.FetchOptions{
-		RootDir:             rewriteOptions.RootDir,
-		LocalPath:           rewriteOptions.UpstreamPath,
-		CurrentCursor:       rewriteOptions.Installation.Spec.UpdateCursor,
-		CurrentVersionLabel: rewriteOptions.Installation.Spec.VersionLabel,
-		EncryptionKey:       rewriteOptions.Installation.Spec.EncryptionKey,
-		License:             rewriteOptions.License,
+		RootDir:              rewriteOptions.RootDir,
+		LocalPath:            rewriteOptions.UpstreamPath,
+		CurrentCursor:        rewriteOptions.Installation.Spec.UpdateCursor,
+		CurrentVersionLabel:  rewriteOptions.Installation.Spec.VersionLabel,
+		EncryptionKey:        rewriteOptions.Installation.Spec.EncryptionKey,
+		License:              rewriteOptions.License,
+		Cache:                rewriteOptions.Cache,
+		SkipReleaseSignature: rewriteOptions.SkipReleaseSignature,
+	}
+	if rewriteOptions.License != nil {
+		fetchOptions.AppPublicKey, fetchOptions.AppPublicKeyErr = pull.GetAppPublicKey(rewriteOptions.License)
 	}
 
 	log.ActionWithSpinner("Pulling upstream")
@@ -62,6 +85,7 @@ func Rewrite(rewriteOptions RewriteOptions) error {
 		log.FinishSpinnerWithError()
 		return errors.Wrap(err, "failed to load upstream")
 	}
+	defer u.Cleanup()
 
 	includeAdminConsole := false
 
@@ -69,6 +93,8 @@ func Rewrite(rewriteOptions RewriteOptions) error {
 		RootDir:             rewriteOptions.RootDir,
 		CreateAppDir:        rewriteOptions.CreateAppDir,
 		IncludeAdminConsole: includeAdminConsole,
+		KubernetesVersion:   kubernetesVersion,
+		APIVersions:         apiVersions,
 	}
 	if err := upstream.WriteUpstream(u, writeUpstreamOptions); err != nil {
 		log.FinishSpinnerWithError()
@@ -81,6 +107,8 @@ func Rewrite(rewriteOptions RewriteOptions) error {
 	renderOptions := base.RenderOptions{
 		SplitMultiDocYAML: true,
 		Namespace:         rewriteOptions.K8sNamespace,
+		KubernetesVersion: kubernetesVersion,
+		APIVersions:       apiVersions,
 		Log:               log,
 	}
 	log.ActionWithSpinner("Creating base")
@@ -0,0 +1,57 @@
+package template
+
+import (
+	"text/template"
+
+	"k8s.io/client-go/kubernetes/scheme"
+)
+
+// DefaultKubernetesVersion is the target kubernetes version when it's not known
+const DefaultKubernetesVersion = "1.16.0"
+
+// ClusterCtx describes the cluster that the application is rendered for.
+// Empty fields fall back to DefaultKubernetesVersion and DefaultAPIVersions.
+type ClusterCtx struct {
+	KubernetesVersion string
+	APIVersions       []string
+}
+
+// FuncMap represents the available functions in the ClusterCtx.
+func (ctx ClusterCtx) FuncMap() template.FuncMap {
+	return template.FuncMap{
+		"KubernetesVersion":   ctx.kubernetesVersion,
+		"APIVersionAvailable": ctx.apiVersionAvailable,
+	}
+}
+
+func (ctx ClusterCtx) kubernetesVersion() string {
+	if ctx.KubernetesVersion == "" {
+		return DefaultKubernetesVersion
+	}
+	return ctx.KubernetesVersion
+}
+
+// apiVersionAvailable returns true if the group version, such as "networking.k8s.io/v1", is served by the cluster
+func (ctx ClusterCtx) apiVersionAvailable(apiVersion string) bool {
+	apiVersions := ctx.APIVersions
+	if len(apiVersions) == 0 {
+		apiVersions = DefaultAPIVersions()
+	}
+
+	for _, v := range apiVersions {
+		if v == apiVersion {
+			return true
+		}
+	}
+	return false
+}
+
+// DefaultAPIVersions are the group versions known to the kubernetes client, used when
+// the api versions of the target cluster are not known
+func DefaultAPIVersions() []string {
+	apiVersions := []string{}
+	for _, gv := range scheme.Scheme.PrioritizedVersionsAllGroups() {
+		apiVersions = append(apiVersions, gv.String())
+	}
+	return apiVersions
+}
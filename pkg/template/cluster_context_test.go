@@ -0,0 +1,61 @@
+package template
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClusterContext(t *testing.T) {
+	tests := []struct {
+		name     string
+		ctx      ClusterCtx
+		template string
+		expected string
+	}{
+		{
+			name:     "default kubernetes version",
+			ctx:      ClusterCtx{},
+			template: `repl{{ KubernetesVersion }}`,
+			expected: DefaultKubernetesVersion,
+		},
+		{
+			name:     "kubernetes version",
+			ctx:      ClusterCtx{KubernetesVersion: "1.19.2"},
+			template: `repl{{ KubernetesVersion }}`,
+			expected: "1.19.2",
+		},
+		{
+			name:     "default api versions",
+			ctx:      ClusterCtx{},
+			template: `{{repl if APIVersionAvailable "apps/v1" }}apps/v1{{repl else }}none{{repl end }}`,
+			expected: "apps/v1",
+		},
+		{
+			name:     "ingress api version available",
+			ctx:      ClusterCtx{APIVersions: []string{"v1", "networking.k8s.io/v1"}},
+			template: `{{repl if APIVersionAvailable "networking.k8s.io/v1" }}networking.k8s.io/v1{{repl else }}extensions/v1beta1{{repl end }}`,
+			expected: "networking.k8s.io/v1",
+		},
+		{
+			name:     "ingress api version not available",
+			ctx:      ClusterCtx{APIVersions: []string{"v1", "extensions/v1beta1"}},
+			template: `{{repl if APIVersionAvailable "networking.k8s.io/v1" }}networking.k8s.io/v1{{repl else }}extensions/v1beta1{{repl end }}`,
+			expected: "extensions/v1beta1",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := require.New(t)
+
+			builder := Builder{}
+			builder.AddCtx(test.ctx)
+
+			actual, err := builder.RenderTemplate(test.name, test.template)
+			req.NoError(err)
+			assert.Equal(t, test.expected, actual)
+		})
+	}
+}
@@ -0,0 +1,406 @@
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// DefaultMaxSize is the size limit of the cache when none is configured
+const DefaultMaxSize int64 = 2 << 30
+
+// ErrDigestMismatch is returned when a cached archive doesn't match the digest that was stored
+// with it, because it was modified or truncated on disk
+var ErrDigestMismatch = errors.New("cached archive does not match its digest")
+
+// Cache stores fetched upstream archives in a directory. Archives are stored by their sha256
+// digest, and an index entry for each upstream uri and cursor points to the archive that was
+// fetched for it, so that a release can be rendered again without downloading it.
+type Cache struct {
+	dir     string
+	maxSize int64
+}
+
+// Entry is the index entry for an archive in the cache
+type Entry struct {
+	Key          string `json:"key"`
+	URI          string `json:"uri"`
+	Cursor       string `json:"cursor"`
+	VersionLabel string `json:"versionLabel,omitempty"`
+	// Signature is the signature of the digest that the archive was downloaded with, if any
+	Signature  []byte    `json:"signature,omitempty"`
+	Digest     string    `json:"digest"`
+	Size       int64     `json:"size"`
+	CreatedAt  time.Time `json:"createdAt"`
+	LastUsedAt time.Time `json:"lastUsedAt"`
+}
+
+// New returns the cache in dir. When maxSize is 0, DefaultMaxSize is used.
+func New(dir string, maxSize int64) *Cache {
+	if maxSize == 0 {
+		maxSize = DefaultMaxSize
+	}
+
+	return &Cache{
+		dir:     dir,
+		maxSize: maxSize,
+	}
+}
+
+// DefaultDir is the cache dir from the KOTS_CACHE_DIR environment variable, or kots in the
+// user's cache dir
+func DefaultDir() string {
+	if dir := os.Getenv("KOTS_CACHE_DIR"); dir != "" {
+		return dir
+	}
+
+	userCacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return filepath.Join(os.TempDir(), "kots-cache")
+	}
+	return filepath.Join(userCacheDir, "kots")
+}
+
+// FromEnv returns the cache in KOTS_CACHE_DIR, or nil if the variable is not set
+func FromEnv() *Cache {
+	dir := os.Getenv("KOTS_CACHE_DIR")
+	if dir == "" {
+		return nil
+	}
+	return New(dir, 0)
+}
+
+// Key identifies an archive by the parts of the request that determine its content,
+// such as the upstream uri, the license and the cursor
+func Key(parts ...string) string {
+	h := sha256.Sum256([]byte(strings.Join(parts, "\x00")))
+	return hex.EncodeToString(h[:])
+}
+
+func (c *Cache) Dir() string {
+	return c.dir
+}
+
+func (c *Cache) MaxSize() int64 {
+	return c.maxSize
+}
+
+// Get returns the entry for key and the path of its archive, or a nil entry when the key is not
+// in the cache. The archive is checked against its digest. An archive that doesn't match is
+// removed, and ErrDigestMismatch is returned.
+func (c *Cache) Get(key string) (*Entry, string, error) {
+	entry, err := c.readEntry(key)
+	if err != nil {
+		return nil, "", errors.Wrap(err, "failed to read cache entry")
+	}
+	if entry == nil {
+		return nil, "", nil
+	}
+
+	blobPath := c.blobPath(entry.Digest)
+	if err := verifyDigest(blobPath, entry.Digest); err != nil {
+		c.removeEntry(entry)
+		if os.IsNotExist(errors.Cause(err)) {
+			return nil, "", nil
+		}
+		return nil, "", err
+	}
+
+	entry.LastUsedAt = time.Now()
+	if err := c.writeEntry(entry); err != nil {
+		return nil, "", errors.Wrap(err, "failed to update cache entry")
+	}
+
+	return entry, blobPath, nil
+}
+
+// Put streams the archive in r to the cache. The key, uri, cursor, version label and signature
+// are taken from entry, the rest is filled in. Least recently used entries are removed
+// afterwards until the cache is within its size limit.
+func (c *Cache) Put(entry Entry, r io.Reader) (*Entry, string, error) {
+	blobsDir := filepath.Join(c.dir, "blobs", "sha256")
+	if err := os.MkdirAll(blobsDir, 0755); err != nil {
+		return nil, "", errors.Wrap(err, "failed to create cache dir")
+	}
+
+	tmpFile, err := ioutil.TempFile(blobsDir, ".tmp")
+	if err != nil {
+		return nil, "", errors.Wrap(err, "failed to create temp file")
+	}
+	defer os.Remove(tmpFile.Name())
+	defer tmpFile.Close()
+
+	h := sha256.New()
+	size, err := io.Copy(io.MultiWriter(tmpFile, h), r)
+	if err != nil {
+		return nil, "", errors.Wrap(err, "failed to write archive")
+	}
+	if err := tmpFile.Close(); err != nil {
+		return nil, "", errors.Wrap(err, "failed to close archive")
+	}
+
+	digest := hex.EncodeToString(h.Sum(nil))
+	blobPath := c.blobPath(digest)
+	if err := os.Rename(tmpFile.Name(), blobPath); err != nil {
+		return nil, "", errors.Wrap(err, "failed to move archive into cache")
+	}
+
+	now := time.Now()
+	entry.Digest = digest
+	entry.Size = size
+	entry.CreatedAt = now
+	entry.LastUsedAt = now
+	if err := c.writeEntry(&entry); err != nil {
+		return nil, "", errors.Wrap(err, "failed to write cache entry")
+	}
+
+	if _, err := c.evict(c.maxSize, entry.Key); err != nil {
+		return nil, "", errors.Wrap(err, "failed to enforce cache size limit")
+	}
+
+	return &entry, blobPath, nil
+}
+
+// List returns all entries, the most recently used first
+func (c *Cache) List() ([]Entry, error) {
+	entriesDir := filepath.Join(c.dir, "entries")
+	files, err := ioutil.ReadDir(entriesDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []Entry{}, nil
+		}
+		return nil, errors.Wrap(err, "failed to read cache entries")
+	}
+
+	entries := []Entry{}
+	for _, file := range files {
+		if file.IsDir() || filepath.Ext(file.Name()) != ".json" {
+			continue
+		}
+
+		entry, err := c.readEntry(strings.TrimSuffix(file.Name(), ".json"))
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to read cache entry %s", file.Name())
+		}
+		if entry != nil {
+			entries = append(entries, *entry)
+		}
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].LastUsedAt.After(entries[j].LastUsedAt)
+	})
+
+	return entries, nil
+}
+
+// Prune removes entries whose archive is missing or doesn't match its digest, archives that no
+// entry refers to, and then the least recently used entries until the cache is no larger than
+// maxSize. A maxSize of 0 empties the cache. The removed entries are returned.
+func (c *Cache) Prune(maxSize int64) ([]Entry, error) {
+	entries, err := c.List()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list cache entries")
+	}
+
+	removed := []Entry{}
+	for _, entry := range entries {
+		if err := verifyDigest(c.blobPath(entry.Digest), entry.Digest); err != nil {
+			if err := c.removeEntry(&entry); err != nil {
+				return nil, errors.Wrap(err, "failed to remove invalid cache entry")
+			}
+			removed = append(removed, entry)
+		}
+	}
+
+	evicted, err := c.evict(maxSize, "")
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to evict cache entries")
+	}
+	removed = append(removed, evicted...)
+
+	if err := c.removeUnreferencedBlobs(); err != nil {
+		return nil, errors.Wrap(err, "failed to remove unreferenced archives")
+	}
+
+	return removed, nil
+}
+
+// evict removes the least recently used entries, except keep, until the archives they
+// refer to are no larger than maxSize
+func (c *Cache) evict(maxSize int64, keep string) ([]Entry, error) {
+	entries, err := c.List()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list cache entries")
+	}
+
+	// entries with the same content share an archive, it's counted once
+	refs := map[string]int{}
+	var total int64
+	for _, entry := range entries {
+		if refs[entry.Digest] == 0 {
+			total += entry.Size
+		}
+		refs[entry.Digest]++
+	}
+
+	removed := []Entry{}
+	for i := len(entries) - 1; i >= 0 && total > maxSize; i-- {
+		entry := entries[i]
+		if entry.Key == keep {
+			continue
+		}
+
+		if err := c.removeEntry(&entry); err != nil {
+			return nil, errors.Wrap(err, "failed to remove cache entry")
+		}
+		removed = append(removed, entry)
+
+		refs[entry.Digest]--
+		if refs[entry.Digest] == 0 {
+			total -= entry.Size
+		}
+	}
+
+	return removed, nil
+}
+
+// removeEntry removes the index entry, and the archive when no other entry refers to it
+func (c *Cache) removeEntry(entry *Entry) error {
+	if err := os.Remove(c.entryPath(entry.Key)); err != nil && !os.IsNotExist(err) {
+		return errors.Wrap(err, "failed to remove entry")
+	}
+
+	entries, err := c.List()
+	if err != nil {
+		return errors.Wrap(err, "failed to list cache entries")
+	}
+	for _, other := range entries {
+		if other.Digest == entry.Digest {
+			return nil
+		}
+	}
+
+	if err := os.Remove(c.blobPath(entry.Digest)); err != nil && !os.IsNotExist(err) {
+		return errors.Wrap(err, "failed to remove archive")
+	}
+	return nil
+}
+
+func (c *Cache) removeUnreferencedBlobs() error {
+	entries, err := c.List()
+	if err != nil {
+		return errors.Wrap(err, "failed to list cache entries")
+	}
+	referenced := map[string]bool{}
+	for _, entry := range entries {
+		referenced[entry.Digest] = true
+	}
+
+	blobsDir := filepath.Join(c.dir, "blobs", "sha256")
+	files, err := ioutil.ReadDir(blobsDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return errors.Wrap(err, "failed to read archives")
+	}
+
+	for _, file := range files {
+		if referenced[file.Name()] {
+			continue
+		}
+		// archives that are still being written by another process
+		if strings.HasPrefix(file.Name(), ".tmp") && time.Since(file.ModTime()) < time.Hour {
+			continue
+		}
+		if err := os.RemoveAll(filepath.Join(blobsDir, file.Name())); err != nil {
+			return errors.Wrapf(err, "failed to remove %s", file.Name())
+		}
+	}
+
+	return nil
+}
+
+func (c *Cache) readEntry(key string) (*Entry, error) {
+	content, err := ioutil.ReadFile(c.entryPath(key))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, errors.Wrap(err, "failed to read entry")
+	}
+
+	entry := Entry{}
+	if err := json.Unmarshal(content, &entry); err != nil {
+		return nil, errors.Wrap(err, "failed to unmarshal entry")
+	}
+	return &entry, nil
+}
+
+func (c *Cache) writeEntry(entry *Entry) error {
+	entriesDir := filepath.Join(c.dir, "entries")
+	if err := os.MkdirAll(entriesDir, 0755); err != nil {
+		return errors.Wrap(err, "failed to create entries dir")
+	}
+
+	content, err := json.MarshalIndent(entry, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal entry")
+	}
+
+	tmpFile, err := ioutil.TempFile(entriesDir, ".tmp")
+	if err != nil {
+		return errors.Wrap(err, "failed to create temp file")
+	}
+	defer os.Remove(tmpFile.Name())
+	defer tmpFile.Close()
+
+	if _, err := tmpFile.Write(content); err != nil {
+		return errors.Wrap(err, "failed to write entry")
+	}
+	if err := tmpFile.Close(); err != nil {
+		return errors.Wrap(err, "failed to close entry")
+	}
+
+	if err := os.Rename(tmpFile.Name(), c.entryPath(entry.Key)); err != nil {
+		return errors.Wrap(err, "failed to move entry into cache")
+	}
+	return nil
+}
+
+func (c *Cache) entryPath(key string) string {
+	return filepath.Join(c.dir, "entries", fmt.Sprintf("%s.json", key))
+}
+
+func (c *Cache) blobPath(digest string) string {
+	return filepath.Join(c.dir, "blobs", "sha256", digest)
+}
+
+func verifyDigest(blobPath string, digest string) error {
+	f, err := os.Open(blobPath)
+	if err != nil {
+		return errors.Wrap(err, "failed to open archive")
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return errors.Wrap(err, "failed to read archive")
+	}
+
+	if hex.EncodeToString(h.Sum(nil)) != digest {
+		return ErrDigestMismatch
+	}
+	return nil
+}
@@ -0,0 +1,148 @@
+package cache
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_PutGet(t *testing.T) {
+	req := require.New(t)
+
+	dir, err := ioutil.TempDir("", "kots-cache")
+	req.NoError(err)
+	defer os.RemoveAll(dir)
+
+	c := New(dir, 0)
+
+	entry, archivePath, err := c.Get(Key("replicated://app", "1"))
+	req.NoError(err)
+	assert.Nil(t, entry)
+	assert.Empty(t, archivePath)
+
+	_, _, err = c.Put(Entry{Key: Key("replicated://app", "1"), URI: "replicated://app", Cursor: "1", VersionLabel: "1.0.0"}, strings.NewReader("release"))
+	req.NoError(err)
+
+	entry, archivePath, err = c.Get(Key("replicated://app", "1"))
+	req.NoError(err)
+	req.NotNil(entry)
+	assert.Equal(t, "replicated://app", entry.URI)
+	assert.Equal(t, "1", entry.Cursor)
+	assert.Equal(t, "1.0.0", entry.VersionLabel)
+	assert.Equal(t, int64(len("release")), entry.Size)
+
+	content, err := ioutil.ReadFile(archivePath)
+	req.NoError(err)
+	assert.Equal(t, "release", string(content))
+
+	// a modified archive is removed, and reported as a mismatch
+	req.NoError(ioutil.WriteFile(archivePath, []byte("modified"), 0644))
+	_, _, err = c.Get(Key("replicated://app", "1"))
+	assert.Equal(t, ErrDigestMismatch, err)
+
+	entry, _, err = c.Get(Key("replicated://app", "1"))
+	req.NoError(err)
+	assert.Nil(t, entry)
+	_, err = os.Stat(archivePath)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func Test_PutEvictsLeastRecentlyUsed(t *testing.T) {
+	req := require.New(t)
+
+	dir, err := ioutil.TempDir("", "kots-cache")
+	req.NoError(err)
+	defer os.RemoveAll(dir)
+
+	c := New(dir, 20)
+
+	_, _, err = c.Put(Entry{Key: Key("1"), URI: "replicated://app", Cursor: "1"}, strings.NewReader(strings.Repeat("1", 10)))
+	req.NoError(err)
+	_, _, err = c.Put(Entry{Key: Key("2"), URI: "replicated://app", Cursor: "2"}, strings.NewReader(strings.Repeat("2", 10)))
+	req.NoError(err)
+
+	// using 1 makes 2 the least recently used
+	entry, _, err := c.Get(Key("1"))
+	req.NoError(err)
+	req.NotNil(entry)
+
+	_, _, err = c.Put(Entry{Key: Key("3"), URI: "replicated://app", Cursor: "3"}, strings.NewReader(strings.Repeat("3", 10)))
+	req.NoError(err)
+
+	entries, err := c.List()
+	req.NoError(err)
+	cursors := []string{}
+	for _, entry := range entries {
+		cursors = append(cursors, entry.Cursor)
+	}
+	assert.Equal(t, []string{"3", "1"}, cursors)
+}
+
+func Test_PutSharesArchives(t *testing.T) {
+	req := require.New(t)
+
+	dir, err := ioutil.TempDir("", "kots-cache")
+	req.NoError(err)
+	defer os.RemoveAll(dir)
+
+	c := New(dir, 10)
+
+	// the same release on two cursors only counts once against the limit
+	first, firstPath, err := c.Put(Entry{Key: Key("1"), URI: "replicated://app", Cursor: "1"}, strings.NewReader(strings.Repeat("x", 10)))
+	req.NoError(err)
+	second, secondPath, err := c.Put(Entry{Key: Key("2"), URI: "replicated://app", Cursor: "2"}, strings.NewReader(strings.Repeat("x", 10)))
+	req.NoError(err)
+	assert.Equal(t, first.Digest, second.Digest)
+	assert.Equal(t, firstPath, secondPath)
+
+	entries, err := c.List()
+	req.NoError(err)
+	assert.Len(t, entries, 2)
+}
+
+func Test_Prune(t *testing.T) {
+	req := require.New(t)
+
+	dir, err := ioutil.TempDir("", "kots-cache")
+	req.NoError(err)
+	defer os.RemoveAll(dir)
+
+	c := New(dir, 0)
+
+	_, corruptPath, err := c.Put(Entry{Key: Key("1"), URI: "replicated://app", Cursor: "1"}, strings.NewReader("one"))
+	req.NoError(err)
+	_, _, err = c.Put(Entry{Key: Key("2"), URI: "replicated://app", Cursor: "2"}, strings.NewReader("two"))
+	req.NoError(err)
+	_, _, err = c.Put(Entry{Key: Key("3"), URI: "replicated://app", Cursor: "3"}, strings.NewReader("three"))
+	req.NoError(err)
+	req.NoError(ioutil.WriteFile(corruptPath, []byte("corrupt"), 0644))
+
+	removed, err := c.Prune(int64(len("three")))
+	req.NoError(err)
+	cursors := []string{}
+	for _, entry := range removed {
+		cursors = append(cursors, entry.Cursor)
+	}
+	assert.ElementsMatch(t, []string{"1", "2"}, cursors)
+
+	entries, err := c.List()
+	req.NoError(err)
+	req.Len(entries, 1)
+	assert.Equal(t, "3", entries[0].Cursor)
+
+	blobs, err := ioutil.ReadDir(dir + "/blobs/sha256")
+	req.NoError(err)
+	assert.Len(t, blobs, 1)
+
+	removed, err = c.Prune(0)
+	req.NoError(err)
+	assert.Len(t, removed, 1)
+
+	entries, err = c.List()
+	req.NoError(err)
+	assert.Empty(t, entries)
+}
@@ -1,11 +1,10 @@
 package upstream
 
 import (
-	"net/url"
-
 	"github.com/pkg/errors"
 	kotsv1beta1 "github.com/replicatedhq/kots/kotskinds/apis/kots/v1beta1"
 	"github.com/replicatedhq/kots/pkg/crypto"
+	"github.com/replicatedhq/kots/pkg/upstream/cache"
 	"github.com/replicatedhq/kots/pkg/upstream/types"
 	"github.com/replicatedhq/kots/pkg/util"
 )
@@ -15,6 +14,8 @@ type FetchOptions struct {
 	UseAppDir           bool
 	HelmRepoName        string
 	HelmRepoURI         string
+	HelmRepoOptions     HelmRepoOptions
+	HTTPClientOptions   HTTPClientOptions
 	HelmOptions         []string
 	LocalPath           string
 	License             *kotsv1beta1.License
@@ -23,8 +24,19 @@ type FetchOptions struct {
 	EncryptionKey       string
 	CurrentCursor       string
 	CurrentVersionLabel string
+	// Cache is checked for releases that were fetched before, no cache is used when it's nil
+	Cache *cache.Cache
+	// AppPublicKey verifies the signature of releases downloaded from replicated.app
+	AppPublicKey []byte
+	// AppPublicKeyErr is the error getting the app public key from the license, which is
+	// returned when a release is verified without a key
+	AppPublicKeyErr      error
+	SkipReleaseSignature bool
 }
 
+// releaseVerifier checks the signature of a release archive, given its sha256 digest
+type releaseVerifier func(digest string, signature []byte) error
+
 func FetchUpstream(upstreamURI string, fetchOptions *FetchOptions) (*types.Upstream, error) {
 	upstream, err := downloadUpstream(upstreamURI, fetchOptions)
 	if err != nil {
@@ -35,37 +47,23 @@ func FetchUpstream(upstreamURI string, fetchOptions *FetchOptions) (*types.Upstr
 }
 
 func downloadUpstream(upstreamURI string, fetchOptions *FetchOptions) (*types.Upstream, error) {
-	if !util.IsURL(upstreamURI) {
-		return readFilesFromPath(upstreamURI)
-	}
-
-	var cipher *crypto.AESCipher
-	if fetchOptions.EncryptionKey != "" {
-		c, err := crypto.AESCipherFromString(fetchOptions.EncryptionKey)
+	if util.IsLocalPath(upstreamURI) {
+		localPath, err := util.LocalPath(upstreamURI)
 		if err != nil {
-			return nil, errors.Wrap(err, "failed to create cipher")
+			return nil, errors.Wrap(err, "failed to get local path")
 		}
-		cipher = c
+		return readFilesFromPath(localPath)
+	}
+	if !util.IsURL(upstreamURI) {
+		return nil, errors.Errorf("upstream %q is not a uri, local paths must start with ./, ../, / or ~", upstreamURI)
 	}
 
-	u, err := url.ParseRequestURI(upstreamURI)
+	fetcher, err := fetcherForURI(upstreamURI)
 	if err != nil {
-		return nil, errors.Wrap(err, "parse request uri failed")
-	}
-	if u.Scheme == "helm" {
-		return downloadHelm(u, fetchOptions.HelmRepoURI)
-	}
-	if u.Scheme == "replicated" {
-		return downloadReplicated(u, fetchOptions.LocalPath, fetchOptions.RootDir, fetchOptions.UseAppDir, fetchOptions.License, fetchOptions.ConfigValues, pickCursor(fetchOptions), pickVersionLabel(fetchOptions), cipher)
-	}
-	if u.Scheme == "git" {
-		return downloadGit(upstreamURI)
-	}
-	if u.Scheme == "http" || u.Scheme == "https" {
-		return downloadHttp(upstreamURI)
+		return nil, err
 	}
 
-	return nil, errors.Errorf("unknown protocol scheme %q", u.Scheme)
+	return fetcher.Fetch(upstreamURI, fetchOptions)
 }
 
 func pickVersionLabel(fetchOptions *FetchOptions) string {
@@ -81,3 +79,30 @@ func pickCursor(fetchOptions *FetchOptions) string {
 	}
 	return fetchOptions.CurrentCursor
 }
+
+func pickReleaseVerifier(fetchOptions *FetchOptions) releaseVerifier {
+	if fetchOptions.SkipReleaseSignature {
+		return nil
+	}
+	return func(digest string, signature []byte) error {
+		return verifyReleaseSignature(fetchOptions.AppPublicKey, fetchOptions.AppPublicKeyErr, digest, signature)
+	}
+}
+
+func verifyReleaseSignature(appPublicKey []byte, appPublicKeyErr error, digest string, signature []byte) error {
+	if len(signature) == 0 {
+		return errors.Wrap(crypto.ErrSignatureMissing, "release is not signed")
+	}
+	if len(appPublicKey) == 0 {
+		if appPublicKeyErr != nil {
+			return errors.Wrap(appPublicKeyErr, "failed to get the app public key from the license to verify the release signature")
+		}
+		return errors.New("license does not include an app public key to verify the release signature")
+	}
+
+	if err := crypto.VerifySignature([]byte(digest), signature, appPublicKey); err != nil {
+		return errors.Wrap(err, "release signature does not match")
+	}
+
+	return nil
+}
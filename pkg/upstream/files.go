@@ -1,14 +1,136 @@
 package upstream
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
 	"github.com/pkg/errors"
 	"github.com/replicatedhq/kots/pkg/upstream/types"
 )
 
-func readFilesFromPath(filepath string) (*types.Upstream, error) {
-	return nil, errors.New("readFilesFromPath not implemented")
+func getUpdatesLocal(localPath string, currentCursor string) ([]Update, error) {
+	files, err := readLocalFiles(localPath)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read local files")
+	}
+
+	cursor := contentHash(files)
+	if cursor == currentCursor {
+		return []Update{}, nil
+	}
+
+	return []Update{{Cursor: cursor, VersionLabel: cursor[:7]}}, nil
+}
+
+func readFilesFromPath(localPath string) (*types.Upstream, error) {
+	files, err := readLocalFiles(localPath)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read local files")
+	}
+	if len(files) == 0 {
+		return nil, errors.Errorf("no files found in %s", localPath)
+	}
+
+	absPath, err := filepath.Abs(localPath)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get absolute path")
+	}
+
+	cursor := contentHash(files)
+
+	upstream := &types.Upstream{
+		URI:          absPath,
+		Name:         filepath.Base(absPath),
+		Type:         "local",
+		Files:        files,
+		UpdateCursor: cursor,
+		VersionLabel: cursor[:7],
+	}
+
+	return upstream, nil
 }
 
 func readFilesFromURI(upstreamURI string) (*types.Upstream, error) {
 	return nil, errors.New("readFilesFromURI not implemented")
 }
+
+// readLocalFiles reads all files in localPath, skipping hidden files and directories
+// such as .git. The returned paths are relative to localPath and sorted.
+func readLocalFiles(localPath string) ([]types.UpstreamFile, error) {
+	info, err := os.Stat(localPath)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to stat local path")
+	}
+	if !info.IsDir() {
+		return nil, errors.Errorf("%s is not a directory", localPath)
+	}
+
+	upstreamFiles := []types.UpstreamFile{}
+	err = filepath.Walk(localPath,
+		func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+
+			if path != localPath && strings.HasPrefix(info.Name(), ".") {
+				if info.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+
+			if !info.Mode().IsRegular() {
+				return nil
+			}
+
+			contents, err := ioutil.ReadFile(path)
+			if err != nil {
+				return err
+			}
+
+			relPath, err := filepath.Rel(localPath, path)
+			if err != nil {
+				return err
+			}
+
+			upstreamFiles = append(upstreamFiles, types.UpstreamFile{
+				Path:    filepath.ToSlash(relPath),
+				Content: contents,
+			})
+
+			return nil
+		})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to walk local path")
+	}
+
+	sort.Slice(upstreamFiles, func(i, j int) bool {
+		return upstreamFiles[i].Path < upstreamFiles[j].Path
+	})
+
+	return upstreamFiles, nil
+}
+
+// contentHash is a sha256 over the paths and contents of all files, so that
+// adding, removing, renaming or editing any file produces a new cursor
+func contentHash(files []types.UpstreamFile) string {
+	sorted := make([]types.UpstreamFile, len(files))
+	copy(sorted, files)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Path < sorted[j].Path
+	})
+
+	h := sha256.New()
+	for _, file := range sorted {
+		fileHash := sha256.Sum256(file.Content)
+		fmt.Fprintf(h, "%s\x00%x\n", file.Path, fileHash)
+	}
+
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,54 @@
+package upstream
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_readFilesFromPath(t *testing.T) {
+	req := require.New(t)
+
+	srcDir, err := ioutil.TempDir("", "kots-local")
+	req.NoError(err)
+	defer os.RemoveAll(srcDir)
+
+	files := map[string]string{
+		"deployment.yaml":     "apiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: web\n",
+		"nested/service.yaml": "apiVersion: v1\nkind: Service\nmetadata:\n  name: web\n",
+		".git/HEAD":           "ref: refs/heads/master\n",
+	}
+	for name, content := range files {
+		req.NoError(os.MkdirAll(filepath.Dir(filepath.Join(srcDir, name)), 0755))
+		req.NoError(ioutil.WriteFile(filepath.Join(srcDir, name), []byte(content), 0644))
+	}
+
+	u, err := readFilesFromPath(srcDir)
+	req.NoError(err)
+
+	assert.Equal(t, "local", u.Type)
+	assert.Equal(t, filepath.Base(srcDir), u.Name)
+	req.Len(u.Files, 2)
+	assert.Equal(t, "deployment.yaml", u.Files[0].Path)
+	assert.Equal(t, "nested/service.yaml", u.Files[1].Path)
+
+	// reading the same content again is not an update
+	updates, err := getUpdatesLocal(srcDir, u.UpdateCursor)
+	req.NoError(err)
+	assert.Empty(t, updates)
+
+	// editing a file is
+	req.NoError(ioutil.WriteFile(filepath.Join(srcDir, "deployment.yaml"), []byte("edited"), 0644))
+	updates, err = getUpdatesLocal(srcDir, u.UpdateCursor)
+	req.NoError(err)
+	req.Len(updates, 1)
+	assert.NotEqual(t, u.UpdateCursor, updates[0].Cursor)
+
+	edited, err := readFilesFromPath(srcDir)
+	req.NoError(err)
+	assert.Equal(t, updates[0].Cursor, edited.UpdateCursor)
+}
@@ -1,10 +1,314 @@
 package upstream
 
 import (
+	"io"
+	"io/ioutil"
+	"net/url"
+	"path"
+	"strings"
+
 	"github.com/pkg/errors"
 	"github.com/replicatedhq/kots/pkg/upstream/types"
+	git "gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+	"gopkg.in/src-d/go-git.v4/storage/memory"
 )
 
-func downloadGit(gitURI string) (*types.Upstream, error) {
-	return nil, errors.New("downloadGit not implemented")
+// GitUpstream is a parsed git:// upstream uri. The uri format is
+// git://host/org/repo//subdir@ref, where both the subdir and the ref are optional.
+// When the host is empty (git:///path/to/repo), the repo is read from the local filesystem.
+type GitUpstream struct {
+	RepoURI string
+	Subdir  string
+	Ref     string
+}
+
+func getUpdatesGit(upstreamURI string, currentCursor string) ([]Update, error) {
+	gitUpstream, err := parseGitURL(upstreamURI)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse git uri")
+	}
+
+	return getUpdatesGitRepo(gitUpstream, currentCursor)
+}
+
+func getUpdatesGitRepo(gitUpstream *GitUpstream, currentCursor string) ([]Update, error) {
+	repo, err := cloneGitRepo(gitUpstream)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to clone git repo")
+	}
+
+	branch, err := gitUpstream.trackedBranch(repo)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to find tracked branch")
+	}
+	if branch == nil {
+		// pinned to a tag or a commit, there is nothing to track
+		return []Update{}, nil
+	}
+
+	tags, err := gitTagsByCommit(repo)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list tags")
+	}
+
+	commits, err := repo.Log(&git.LogOptions{From: branch.Hash()})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read commit log")
+	}
+	defer commits.Close()
+
+	updates := []Update{}
+	for {
+		commit, err := commits.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to get next commit")
+		}
+
+		if commit.Hash.String() == currentCursor {
+			break
+		}
+
+		updates = append([]Update{{
+			Cursor:       commit.Hash.String(),
+			VersionLabel: gitVersionLabel(commit.Hash, tags),
+		}}, updates...)
+
+		// without a cursor, only the head of the branch is interesting
+		if currentCursor == "" {
+			break
+		}
+	}
+
+	return updates, nil
+}
+
+func downloadGit(upstreamURI string) (*types.Upstream, error) {
+	gitUpstream, err := parseGitURL(upstreamURI)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse git uri")
+	}
+
+	repo, err := cloneGitRepo(gitUpstream)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to clone git repo")
+	}
+
+	revision := "HEAD"
+	if gitUpstream.Ref != "" {
+		revision = gitUpstream.Ref
+	}
+	hash, err := resolveGitRevision(repo, revision)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to resolve ref %q", revision)
+	}
+
+	commit, err := repo.CommitObject(*hash)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get commit")
+	}
+
+	files, err := gitCommitToFiles(commit, gitUpstream.Subdir)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read files from commit")
+	}
+	if len(files) == 0 {
+		return nil, errors.Errorf("no files found in %q at %s", gitUpstream.Subdir, hash.String())
+	}
+
+	tags, err := gitTagsByCommit(repo)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list tags")
+	}
+
+	upstream := &types.Upstream{
+		URI:          upstreamURI,
+		Name:         gitUpstream.name(),
+		Type:         "git",
+		Files:        files,
+		UpdateCursor: hash.String(),
+		VersionLabel: gitVersionLabel(*hash, tags),
+	}
+
+	return upstream, nil
+}
+
+func parseGitURL(upstreamURI string) (*GitUpstream, error) {
+	u, err := url.ParseRequestURI(upstreamURI)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse uri")
+	}
+
+	if u.Scheme != "git" {
+		return nil, errors.Errorf("unexpected scheme %q", u.Scheme)
+	}
+
+	gitUpstream := GitUpstream{}
+
+	repoPath := u.Path
+	if idx := strings.LastIndex(repoPath, "@"); idx != -1 {
+		gitUpstream.Ref = repoPath[idx+1:]
+		repoPath = repoPath[:idx]
+	}
+
+	// the first "//" after the leading slash separates the repo from the subdir
+	if idx := strings.Index(strings.TrimPrefix(repoPath, "/"), "//"); idx != -1 {
+		idx++
+		gitUpstream.Subdir = strings.Trim(repoPath[idx+2:], "/")
+		repoPath = repoPath[:idx]
+	}
+
+	repoPath = strings.TrimSuffix(repoPath, "/")
+	if repoPath == "" {
+		return nil, errors.New("missing repo path")
+	}
+
+	if u.Host == "" {
+		gitUpstream.RepoURI = repoPath
+	} else {
+		gitUpstream.RepoURI = "git://" + u.Host + repoPath
+	}
+
+	return &gitUpstream, nil
+}
+
+func (g *GitUpstream) name() string {
+	return strings.TrimSuffix(path.Base(g.RepoURI), ".git")
+}
+
+// trackedBranch returns the remote branch that this upstream follows, or nil
+// if the ref is a tag or a commit
+func (g *GitUpstream) trackedBranch(repo *git.Repository) (*plumbing.Reference, error) {
+	if g.Ref == "" {
+		head, err := repo.Head()
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to get head")
+		}
+		return head, nil
+	}
+
+	ref, err := repo.Reference(plumbing.NewRemoteReferenceName("origin", g.Ref), true)
+	if err == plumbing.ErrReferenceNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get branch reference")
+	}
+
+	return ref, nil
+}
+
+func cloneGitRepo(gitUpstream *GitUpstream) (*git.Repository, error) {
+	repo, err := git.Clone(memory.NewStorage(), nil, &git.CloneOptions{
+		URL:        gitUpstream.RepoURI,
+		NoCheckout: true,
+		Tags:       git.AllTags,
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to clone %s", gitUpstream.RepoURI)
+	}
+
+	return repo, nil
+}
+
+// resolveGitRevision resolves a branch, tag or commit sha. Branches only exist as
+// remote references in a fresh clone, so those are tried last.
+func resolveGitRevision(repo *git.Repository, revision string) (*plumbing.Hash, error) {
+	hash, err := repo.ResolveRevision(plumbing.Revision(revision))
+	if err == nil {
+		return hash, nil
+	}
+
+	hash, remoteErr := repo.ResolveRevision(plumbing.Revision("origin/" + revision))
+	if remoteErr == nil {
+		return hash, nil
+	}
+
+	return nil, err
+}
+
+func gitTagsByCommit(repo *git.Repository) (map[plumbing.Hash]string, error) {
+	iter, err := repo.Tags()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get tags")
+	}
+	defer iter.Close()
+
+	tags := map[plumbing.Hash]string{}
+	err = iter.ForEach(func(ref *plumbing.Reference) error {
+		hash := ref.Hash()
+
+		// annotated tags point to a tag object instead of a commit
+		if tag, err := repo.TagObject(hash); err == nil {
+			commit, err := tag.Commit()
+			if err != nil {
+				return nil
+			}
+			hash = commit.Hash
+		}
+
+		tags[hash] = ref.Name().Short()
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to iterate tags")
+	}
+
+	return tags, nil
+}
+
+func gitVersionLabel(hash plumbing.Hash, tags map[plumbing.Hash]string) string {
+	if tag, ok := tags[hash]; ok {
+		return tag
+	}
+
+	return hash.String()[:7]
+}
+
+func gitCommitToFiles(commit *object.Commit, subdir string) ([]types.UpstreamFile, error) {
+	tree, err := commit.Tree()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get tree")
+	}
+
+	if subdir != "" {
+		subtree, err := tree.Tree(subdir)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to find %q in tree", subdir)
+		}
+		tree = subtree
+	}
+
+	upstreamFiles := []types.UpstreamFile{}
+	err = tree.Files().ForEach(func(f *object.File) error {
+		if !f.Mode.IsFile() {
+			return nil
+		}
+
+		reader, err := f.Reader()
+		if err != nil {
+			return errors.Wrapf(err, "failed to open %s", f.Name)
+		}
+		defer reader.Close()
+
+		content, err := ioutil.ReadAll(reader)
+		if err != nil {
+			return errors.Wrapf(err, "failed to read %s", f.Name)
+		}
+
+		upstreamFiles = append(upstreamFiles, types.UpstreamFile{
+			Path:    f.Name,
+			Content: content,
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to walk tree")
+	}
+
+	return upstreamFiles, nil
 }
@@ -0,0 +1,188 @@
+package upstream
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	git "gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+)
+
+func Test_parseGitURL(t *testing.T) {
+	tests := []struct {
+		name     string
+		uri      string
+		expected GitUpstream
+	}{
+		{
+			name: "repo only",
+			uri:  "git://github.com/org/repo",
+			expected: GitUpstream{
+				RepoURI: "git://github.com/org/repo",
+			},
+		},
+		{
+			name: "repo with ref",
+			uri:  "git://github.com/org/repo.git@v1.0.0",
+			expected: GitUpstream{
+				RepoURI: "git://github.com/org/repo.git",
+				Ref:     "v1.0.0",
+			},
+		},
+		{
+			name: "repo with subdir and ref",
+			uri:  "git://github.com/org/repo//deploy/manifests@main",
+			expected: GitUpstream{
+				RepoURI: "git://github.com/org/repo",
+				Subdir:  "deploy/manifests",
+				Ref:     "main",
+			},
+		},
+		{
+			name: "local repo with subdir",
+			uri:  "git:///tmp/repo.git//manifests",
+			expected: GitUpstream{
+				RepoURI: "/tmp/repo.git",
+				Subdir:  "manifests",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := require.New(t)
+
+			actual, err := parseGitURL(test.uri)
+			req.NoError(err)
+			assert.Equal(t, test.expected, *actual)
+		})
+	}
+}
+
+func Test_downloadGit(t *testing.T) {
+	req := require.New(t)
+
+	bareDir, commits, cleanup := createTestGitRepo(t)
+	defer cleanup()
+
+	tests := []struct {
+		name                 string
+		uri                  string
+		expectedCursor       string
+		expectedVersionLabel string
+		expectedFiles        []string
+	}{
+		{
+			name:                 "head",
+			uri:                  "git://" + bareDir + "//manifests",
+			expectedCursor:       commits[1].String(),
+			expectedVersionLabel: commits[1].String()[:7],
+			expectedFiles:        []string{"deployment.yaml", "service.yaml"},
+		},
+		{
+			name:                 "tag",
+			uri:                  "git://" + bareDir + "//manifests@v1.0.0",
+			expectedCursor:       commits[0].String(),
+			expectedVersionLabel: "v1.0.0",
+			expectedFiles:        []string{"deployment.yaml"},
+		},
+		{
+			name:                 "commit",
+			uri:                  "git://" + bareDir + "@" + commits[0].String(),
+			expectedCursor:       commits[0].String(),
+			expectedVersionLabel: "v1.0.0",
+			expectedFiles:        []string{"README.md", "manifests/deployment.yaml"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			u, err := downloadGit(test.uri)
+			req.NoError(err)
+
+			assert.Equal(t, "git", u.Type)
+			assert.Equal(t, "repo", u.Name)
+			assert.Equal(t, test.expectedCursor, u.UpdateCursor)
+			assert.Equal(t, test.expectedVersionLabel, u.VersionLabel)
+
+			paths := []string{}
+			for _, f := range u.Files {
+				paths = append(paths, f.Path)
+			}
+			assert.ElementsMatch(t, test.expectedFiles, paths)
+		})
+	}
+}
+
+func Test_getUpdatesGit(t *testing.T) {
+	req := require.New(t)
+
+	bareDir, commits, cleanup := createTestGitRepo(t)
+	defer cleanup()
+
+	updates, err := getUpdatesGit("git://"+bareDir+"//manifests@master", commits[0].String())
+	req.NoError(err)
+	assert.Equal(t, []Update{{Cursor: commits[1].String(), VersionLabel: commits[1].String()[:7]}}, updates)
+
+	updates, err = getUpdatesGit("git://"+bareDir+"//manifests@master", commits[1].String())
+	req.NoError(err)
+	assert.Empty(t, updates)
+
+	updates, err = getUpdatesGit("git://"+bareDir+"//manifests@v1.0.0", commits[0].String())
+	req.NoError(err)
+	assert.Empty(t, updates)
+}
+
+// createTestGitRepo creates a bare repo with two commits on master, the first one tagged v1.0.0
+func createTestGitRepo(t *testing.T) (string, []plumbing.Hash, func()) {
+	req := require.New(t)
+
+	workDir, err := ioutil.TempDir("", "kots-git")
+	req.NoError(err)
+	cleanup := func() {
+		os.RemoveAll(workDir)
+	}
+
+	srcDir := filepath.Join(workDir, "src")
+	repo, err := git.PlainInit(srcDir, false)
+	req.NoError(err)
+	worktree, err := repo.Worktree()
+	req.NoError(err)
+
+	commitFiles := func(files map[string]string) plumbing.Hash {
+		for name, content := range files {
+			req.NoError(os.MkdirAll(filepath.Dir(filepath.Join(srcDir, name)), 0755))
+			req.NoError(ioutil.WriteFile(filepath.Join(srcDir, name), []byte(content), 0644))
+			_, err := worktree.Add(name)
+			req.NoError(err)
+		}
+		hash, err := worktree.Commit("commit", &git.CommitOptions{
+			Author: &object.Signature{Name: "test", Email: "test@example.com", When: time.Now()},
+		})
+		req.NoError(err)
+		return hash
+	}
+
+	first := commitFiles(map[string]string{
+		"README.md":                 "readme",
+		"manifests/deployment.yaml": "apiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: web\n",
+	})
+	_, err = repo.CreateTag("v1.0.0", first, nil)
+	req.NoError(err)
+
+	second := commitFiles(map[string]string{
+		"manifests/service.yaml": "apiVersion: v1\nkind: Service\nmetadata:\n  name: web\n",
+	})
+
+	bareDir := filepath.Join(workDir, "repo.git")
+	_, err = git.PlainClone(bareDir, true, &git.CloneOptions{URL: srcDir, Tags: git.AllTags})
+	req.NoError(err)
+
+	return bareDir, []plumbing.Hash{first, second}, cleanup
+}
@@ -4,63 +4,95 @@ import (
 	"archive/tar"
 	"bytes"
 	"compress/gzip"
-	"fmt"
 	"io"
 	"io/ioutil"
 	"net/url"
 	"os"
 	"path"
 	"path/filepath"
+	"sort"
 	"strings"
 
-	"github.com/Masterminds/semver"
+	semver "github.com/Masterminds/semver/v3"
 	"github.com/pkg/errors"
+	"github.com/replicatedhq/kots/pkg/upstream/cache"
 	"github.com/replicatedhq/kots/pkg/upstream/types"
 	"github.com/replicatedhq/kots/pkg/util"
 	"k8s.io/helm/cmd/helm/search"
-	"k8s.io/helm/pkg/downloader"
 	"k8s.io/helm/pkg/getter"
 	"k8s.io/helm/pkg/helm/environment"
-	"k8s.io/helm/pkg/helm/helmpath"
 	"k8s.io/helm/pkg/repo"
 )
 
-func getUpdatesHelm(u *url.URL, repoURI string) ([]Update, error) {
-	repoName, chartName, _, err := parseHelmURL(u)
+func getUpdatesHelm(u *url.URL, repoURI string, repoOptions HelmRepoOptions, currentCursor string) ([]Update, error) {
+	repoName, chartName, chartVersion, err := parseHelmURL(u)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to parse helm uri")
 	}
 
+	helmRepo, err := resolveHelmRepo(repoName, repoURI, repoOptions)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to resolve helm repo")
+	}
+
 	helmHome, err := ioutil.TempDir("", "kots")
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to create temporary helm home")
 	}
 	defer os.RemoveAll(helmHome)
 
-	i, err := helmLoadRepositoriesIndex(helmHome, repoName, repoURI)
+	i, err := helmLoadRepositoriesIndex(helmHome, helmRepo)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to load helm repositories")
 	}
 
-	var updates []Update
-	for _, result := range i.All() {
-		if result.Chart.GetName() != chartName {
-			continue
+	chartVersions, err := helmChartVersions(i, chartName, chartVersion)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list chart versions")
+	}
+
+	currentVersion, _ := semver.NewVersion(currentCursor)
+
+	updates := []Update{}
+	for _, chartVersion := range chartVersions {
+		if currentVersion != nil {
+			v, err := semver.NewVersion(chartVersion.GetVersion())
+			if err == nil && !v.GreaterThan(currentVersion) {
+				continue
+			}
 		}
 
-		updates = append(updates, Update{Cursor: result.Chart.GetVersion()})
+		updates = append(updates, Update{
+			Cursor:       chartVersion.GetVersion(),
+			VersionLabel: chartVersion.GetVersion(),
+			AppVersion:   chartVersion.GetAppVersion(),
+			Description:  chartVersion.GetDescription(),
+		})
 	}
 	return updates, nil
 }
 
-func downloadHelm(u *url.URL, repoURI string) (*types.Upstream, error) {
+func downloadHelm(u *url.URL, repoURI string, repoOptions HelmRepoOptions, upstreamCache *cache.Cache) (*types.Upstream, error) {
 	repoName, chartName, chartVersion, err := parseHelmURL(u)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to parse helm uri")
 	}
 
-	if repoURI == "" {
-		repoURI = getKnownHelmRepoURI(repoName)
+	helmRepo, err := resolveHelmRepo(repoName, repoURI, repoOptions)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to resolve helm repo")
+	}
+
+	// archives are cached by their exact version, a version constraint
+	// always needs the index to find the version it resolves to
+	if upstreamCache != nil && chartVersion != "" {
+		entry, archivePath, err := upstreamCache.Get(helmCacheKey(helmRepo, chartName, chartVersion))
+		if err != nil && err != cache.ErrDigestMismatch {
+			return nil, errors.Wrap(err, "failed to read chart from cache")
+		}
+		if entry != nil {
+			return helmArchiveToUpstream(u, chartName, entry.Cursor, archivePath)
+		}
 	}
 
 	helmHome, err := ioutil.TempDir("", "kots")
@@ -69,76 +101,81 @@ func downloadHelm(u *url.URL, repoURI string) (*types.Upstream, error) {
 	}
 	defer os.RemoveAll(helmHome)
 
-	i, err := helmLoadRepositoriesIndex(helmHome, repoName, repoURI)
+	i, err := helmLoadRepositoriesIndex(helmHome, helmRepo)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to load helm repositories")
 	}
 
-	if chartVersion == "" {
-		highestChartVersion := semver.MustParse("0.0.0")
-		for _, result := range i.All() {
-			if result.Chart.GetName() != chartName {
-				continue
-			}
-
-			v, err := semver.NewVersion(result.Chart.GetVersion())
-			if err != nil {
-				return nil, errors.Wrap(err, "unable to parse chart version")
-			}
-
-			if v.GreaterThan(highestChartVersion) {
-				highestChartVersion = v
-			}
+	chartVersions, err := helmChartVersions(i, chartName, chartVersion)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list chart versions")
+	}
+	if len(chartVersions) == 0 {
+		if chartVersion == "" {
+			return nil, errors.Errorf("chart %s not found", chartName)
 		}
-
-		chartVersion = highestChartVersion.String()
+		return nil, errors.Errorf("no version of chart %s matches %q", chartName, chartVersion)
 	}
 
-	for _, result := range i.All() {
-		if result.Chart.GetName() != chartName {
-			continue
-		}
+	// the highest version that satisfies the constraint
+	result := chartVersions[len(chartVersions)-1]
+	chartVersion = result.GetVersion()
 
-		if result.Chart.GetVersion() != chartVersion {
-			continue
-		}
+	if len(result.URLs) == 0 {
+		return nil, errors.Errorf("chart %s version %s has no download urls", chartName, chartVersion)
+	}
 
-		dl := downloader.ChartDownloader{
-			HelmHome: helmpath.Home(helmHome),
-			Out:      os.Stdout,
-			Getters:  getter.All(environment.EnvSettings{}),
-		}
+	archive, err := downloadHelmChartArchive(helmRepo, result.URLs[0])
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to download chart")
+	}
 
-		archiveDir, err := ioutil.TempDir("", "archive")
-		if err != nil {
-			return nil, errors.Wrap(err, "failed to create archive directory for chart")
+	if upstreamCache != nil {
+		entry := cache.Entry{
+			Key:          helmCacheKey(helmRepo, chartName, chartVersion),
+			URI:          u.String(),
+			Cursor:       chartVersion,
+			VersionLabel: chartVersion,
 		}
-		defer os.RemoveAll(archiveDir)
-
-		chartRef, err := repo.FindChartInRepoURL(repoURI, result.Chart.GetName(), chartVersion, "", "", "", getter.All(environment.EnvSettings{}))
+		_, archivePath, err := upstreamCache.Put(entry, bytes.NewReader(archive))
 		if err != nil {
-			return nil, errors.Wrap(err, "failed to find chart in repo url")
+			return nil, errors.Wrap(err, "failed to cache chart")
 		}
+		return helmArchiveToUpstream(u, chartName, chartVersion, archivePath)
+	}
 
-		_, _, err = dl.DownloadTo(chartRef, result.Chart.GetVersion(), archiveDir)
-		if err != nil {
-			return nil, errors.Wrap(err, "failed to download chart")
-		}
+	archiveFile, err := ioutil.TempFile("", "chart")
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create temp file for chart archive")
+	}
+	defer os.Remove(archiveFile.Name())
+	defer archiveFile.Close()
 
-		upstream, err := chartArchiveToSparseUpstream(path.Join(archiveDir, fmt.Sprintf("%s-%s.tgz", chartName, chartVersion)))
-		if err != nil {
-			return nil, errors.Wrap(err, "failed to parse chart archive as upstream")
-		}
+	if _, err := archiveFile.Write(archive); err != nil {
+		return nil, errors.Wrap(err, "failed to write chart archive")
+	}
 
-		upstream.URI = u.RequestURI()
-		upstream.Name = chartName
-		upstream.UpdateCursor = chartVersion
-		upstream.VersionLabel = chartVersion
+	return helmArchiveToUpstream(u, chartName, chartVersion, archiveFile.Name())
+}
 
-		return upstream, nil
+func helmArchiveToUpstream(u *url.URL, chartName string, chartVersion string, archivePath string) (*types.Upstream, error) {
+	upstream, err := chartArchiveToSparseUpstream(archivePath)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse chart archive as upstream")
 	}
 
-	return nil, errors.New("chart version not found")
+	upstream.URI = u.RequestURI()
+	upstream.Name = chartName
+	upstream.UpdateCursor = chartVersion
+	upstream.VersionLabel = chartVersion
+
+	return upstream, nil
+}
+
+// helmCacheKey identifies a chart archive by the repo it was downloaded from, not by the
+// repo alias, which can point to a different repo in another config
+func helmCacheKey(helmRepo *HelmRepo, chartName string, chartVersion string) string {
+	return cache.Key("helm", helmRepo.URL, chartName, chartVersion)
 }
 
 func chartArchiveToSparseUpstream(chartArchivePath string) (*types.Upstream, error) {
@@ -155,15 +192,7 @@ func chartArchiveToSparseUpstream(chartArchivePath string) (*types.Upstream, err
 	return upstream, nil
 }
 
-func helmLoadRepositoriesIndex(helmHome, repoName, repoURI string) (*search.Index, error) {
-	if repoURI == "" {
-		repoURI = getKnownHelmRepoURI(repoName)
-	}
-
-	if repoURI == "" {
-		return nil, errors.New("unknown helm repo uri, try passing the repo uri")
-	}
-
+func helmLoadRepositoriesIndex(helmHome string, helmRepo *HelmRepo) (*search.Index, error) {
 	if err := os.MkdirAll(filepath.Join(helmHome, "repository"), 0755); err != nil {
 		return nil, errors.Wrap(err, "failed to make directory for helm home")
 	}
@@ -188,12 +217,9 @@ repositories: []`
 		return nil, err
 	}
 
-	c := repo.Entry{
-		Name:  repoName,
-		Cache: repoIndexFile.Name(),
-		URL:   repoURI,
-	}
-	r, err := repo.NewChartRepository(&c, getter.All(environment.EnvSettings{}))
+	c := helmRepo.toRepoEntry()
+	c.Cache = repoIndexFile.Name()
+	r, err := repo.NewChartRepository(c, getter.All(environment.EnvSettings{}))
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to create chart repository")
 	}
@@ -205,7 +231,7 @@ repositories: []`
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to load repositories file")
 	}
-	rf.Update(&c)
+	rf.Update(c)
 
 	i := search.NewIndex()
 	for _, re := range rf.Repositories {
@@ -221,6 +247,57 @@ repositories: []`
 	return i, nil
 }
 
+// downloadHelmChartArchive downloads chartURL, which can be relative to the repo url,
+// using the credentials and tls files of the repo
+func downloadHelmChartArchive(helmRepo *HelmRepo, chartURL string) ([]byte, error) {
+	u, err := url.Parse(chartURL)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse chart url")
+	}
+
+	if !u.IsAbs() {
+		repoURL, err := url.Parse(helmRepo.URL)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to parse repo url")
+		}
+		repoURL.Path = strings.TrimSuffix(repoURL.Path, "/") + "/"
+		u = repoURL.ResolveReference(u)
+	}
+
+	getterConstructor, err := getter.All(environment.EnvSettings{}).ByScheme(u.Scheme)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to find getter for %s", u.Scheme)
+	}
+	g, err := getterConstructor(u.String(), helmRepo.CertFile, helmRepo.KeyFile, helmRepo.CAFile)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create getter")
+	}
+	if httpGetter, ok := g.(*getter.HttpGetter); ok {
+		httpGetter.SetCredentials(helmRepo.Username, helmRepo.Password)
+	}
+
+	data, err := g.Get(u.String())
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get chart archive")
+	}
+
+	return data.Bytes(), nil
+}
+
+func (h *HelmRepo) toRepoEntry() *repo.Entry {
+	return &repo.Entry{
+		Name:     h.Name,
+		URL:      h.URL,
+		Username: h.Username,
+		Password: h.Password,
+		CertFile: h.CertFile,
+		KeyFile:  h.KeyFile,
+		CAFile:   h.CAFile,
+	}
+}
+
+// parseHelmURL returns the repo, chart name and version of a helm://repo/chart@version uri.
+// The version can be an exact version or a semver constraint such as "~10.5" or ">=2.0 <3.0".
 func parseHelmURL(u *url.URL) (string, string, string, error) {
 	repo := u.Host
 	chartName := strings.TrimLeft(u.Path, "/")
@@ -235,6 +312,65 @@ func parseHelmURL(u *url.URL) (string, string, string, error) {
 	return repo, chartName, chartVersion, nil
 }
 
+// helmChartVersions returns the versions of chartName in the index that match versionConstraint,
+// sorted from lowest to highest. A version that exactly matches the constraint string is always
+// returned as is. Otherwise, versions that are not valid semver are skipped, and pre-releases
+// are only included when the constraint itself contains a pre-release, such as ">=2.0.0-0".
+// An empty constraint matches all versions except pre-releases.
+func helmChartVersions(i *search.Index, chartName string, versionConstraint string) ([]*repo.ChartVersion, error) {
+	var constraint *semver.Constraints
+	if versionConstraint != "" {
+		for _, result := range i.All() {
+			if result.Chart.GetName() == chartName && result.Chart.GetVersion() == versionConstraint {
+				return []*repo.ChartVersion{result.Chart}, nil
+			}
+		}
+
+		c, err := semver.NewConstraint(versionConstraint)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to parse version constraint %q", versionConstraint)
+		}
+		constraint = c
+	}
+
+	type matchingVersion struct {
+		version      *semver.Version
+		chartVersion *repo.ChartVersion
+	}
+	matchingVersions := []matchingVersion{}
+	for _, result := range i.All() {
+		if result.Chart.GetName() != chartName {
+			continue
+		}
+
+		v, err := semver.NewVersion(result.Chart.GetVersion())
+		if err != nil {
+			continue
+		}
+
+		if constraint == nil {
+			if v.Prerelease() != "" {
+				continue
+			}
+		} else if !constraint.Check(v) {
+			continue
+		}
+
+		matchingVersions = append(matchingVersions, matchingVersion{version: v, chartVersion: result.Chart})
+	}
+
+	sort.Slice(matchingVersions, func(i, j int) bool {
+		return matchingVersions[i].version.LessThan(matchingVersions[j].version)
+	})
+
+	chartVersions := []*repo.ChartVersion{}
+	for _, m := range matchingVersions {
+		chartVersions = append(chartVersions, m.chartVersion)
+	}
+
+	return chartVersions, nil
+}
+
 func getKnownHelmRepoURI(repoName string) string {
 	val, ok := KnownRepos[repoName]
 	if !ok {
@@ -289,33 +425,37 @@ func readTarGz(source string) ([]types.UpstreamFile, error) {
 		}
 	}
 
-	// remove any common prefix from all files
-	if len(upstreamFiles) > 0 {
-		firstFileDir, _ := path.Split(upstreamFiles[0].Path)
-		commonPrefix := strings.Split(firstFileDir, string(os.PathSeparator))
+	return stripCommonPrefix(upstreamFiles), nil
+}
 
-		for _, file := range upstreamFiles {
-			d, _ := path.Split(file.Path)
-			dirs := strings.Split(d, string(os.PathSeparator))
+// stripCommonPrefix removes any directory prefix that is shared by all files
+func stripCommonPrefix(upstreamFiles []types.UpstreamFile) []types.UpstreamFile {
+	if len(upstreamFiles) == 0 {
+		return upstreamFiles
+	}
 
-			commonPrefix = util.CommonSlicePrefix(commonPrefix, dirs)
+	firstFileDir, _ := path.Split(upstreamFiles[0].Path)
+	commonPrefix := strings.Split(firstFileDir, string(os.PathSeparator))
 
-		}
+	for _, file := range upstreamFiles {
+		d, _ := path.Split(file.Path)
+		dirs := strings.Split(d, string(os.PathSeparator))
 
-		cleanedUpstreamFiles := []types.UpstreamFile{}
-		for _, file := range upstreamFiles {
-			d, f := path.Split(file.Path)
-			d2 := strings.Split(d, string(os.PathSeparator))
+		commonPrefix = util.CommonSlicePrefix(commonPrefix, dirs)
 
-			cleanedUpstreamFile := file
-			d2 = d2[len(commonPrefix):]
-			cleanedUpstreamFile.Path = path.Join(path.Join(d2...), f)
+	}
 
-			cleanedUpstreamFiles = append(cleanedUpstreamFiles, cleanedUpstreamFile)
-		}
+	cleanedUpstreamFiles := []types.UpstreamFile{}
+	for _, file := range upstreamFiles {
+		d, f := path.Split(file.Path)
+		d2 := strings.Split(d, string(os.PathSeparator))
+
+		cleanedUpstreamFile := file
+		d2 = d2[len(commonPrefix):]
+		cleanedUpstreamFile.Path = path.Join(path.Join(d2...), f)
 
-		upstreamFiles = cleanedUpstreamFiles
+		cleanedUpstreamFiles = append(cleanedUpstreamFiles, cleanedUpstreamFile)
 	}
 
-	return upstreamFiles, nil
+	return cleanedUpstreamFiles
 }
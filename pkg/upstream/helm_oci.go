@@ -0,0 +1,233 @@
+package upstream
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	semver "github.com/Masterminds/semver/v3"
+	"github.com/pkg/errors"
+	kotsv1beta1 "github.com/replicatedhq/kots/kotskinds/apis/kots/v1beta1"
+	"github.com/replicatedhq/kots/pkg/docker/registry"
+	"github.com/replicatedhq/kots/pkg/upstream/types"
+	"k8s.io/client-go/kubernetes/scheme"
+)
+
+// OCIHelmUpstream is a parsed oci://registry/org/chart@version uri. The version is optional,
+// the highest semver tag in the repository is used when it's missing.
+type OCIHelmUpstream struct {
+	Registry   string
+	Repository string
+	Version    string
+}
+
+func getUpdatesOCIHelm(u *url.URL, currentCursor string) ([]Update, error) {
+	ociUpstream, err := parseOCIHelmURL(u)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse oci uri")
+	}
+
+	versions, err := ociUpstream.listVersions()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list chart versions")
+	}
+
+	currentVersion, _ := semver.NewVersion(currentCursor)
+
+	updates := []Update{}
+	for _, v := range versions {
+		if currentVersion != nil && !v.GreaterThan(currentVersion) {
+			continue
+		}
+
+		updates = append(updates, Update{Cursor: v.Original(), VersionLabel: v.Original()})
+	}
+	return updates, nil
+}
+
+func downloadOCIHelm(u *url.URL) (*types.Upstream, error) {
+	ociUpstream, err := parseOCIHelmURL(u)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse oci uri")
+	}
+
+	archive, chartVersion, err := ociUpstream.pullChart()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to pull chart")
+	}
+
+	archiveFile, err := ioutil.TempFile("", "chart")
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create temp file for chart archive")
+	}
+	defer os.Remove(archiveFile.Name())
+	defer archiveFile.Close()
+
+	if _, err := archiveFile.Write(archive); err != nil {
+		return nil, errors.Wrap(err, "failed to write chart archive")
+	}
+
+	upstream, err := chartArchiveToSparseUpstream(archiveFile.Name())
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse chart archive as upstream")
+	}
+
+	upstream.URI = u.String()
+	upstream.Name = path.Base(ociUpstream.Repository)
+	upstream.UpdateCursor = chartVersion
+	upstream.VersionLabel = chartVersion
+
+	return upstream, nil
+}
+
+func parseOCIHelmURL(u *url.URL) (*OCIHelmUpstream, error) {
+	if u.Scheme != "oci" {
+		return nil, errors.Errorf("unexpected scheme %q", u.Scheme)
+	}
+
+	ociUpstream := OCIHelmUpstream{
+		Registry: u.Host,
+	}
+
+	repository := strings.Trim(u.Path, "/")
+	if idx := strings.LastIndex(repository, "@"); idx != -1 {
+		ociUpstream.Version = repository[idx+1:]
+		repository = repository[:idx]
+	}
+	ociUpstream.Repository = repository
+
+	if ociUpstream.Registry == "" || ociUpstream.Repository == "" {
+		return nil, errors.New("expected oci://registry/repository")
+	}
+
+	return &ociUpstream, nil
+}
+
+// listVersions returns all semver tags in the repository, sorted from lowest to highest
+func (o *OCIHelmUpstream) listVersions() ([]*semver.Version, error) {
+	username, password, err := registry.LoadAuthForRegistry(o.Registry)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to load registry auth for %q", o.Registry)
+	}
+
+	tags, err := registry.ListTags(o.Registry, o.Repository, username, password)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list tags")
+	}
+
+	versions := []*semver.Version{}
+	for _, tag := range tags {
+		v, err := semver.NewVersion(strings.Replace(tag, "_", "+", -1))
+		if err != nil {
+			continue
+		}
+		versions = append(versions, v)
+	}
+	sort.Sort(semver.Collection(versions))
+
+	return versions, nil
+}
+
+// pullChart returns the chart archive and the version that was pulled
+func (o *OCIHelmUpstream) pullChart() ([]byte, string, error) {
+	chartVersion := o.Version
+	if chartVersion == "" {
+		versions, err := o.listVersions()
+		if err != nil {
+			return nil, "", errors.Wrap(err, "failed to list chart versions")
+		}
+		if len(versions) == 0 {
+			return nil, "", errors.Errorf("no versions found for %s/%s", o.Registry, o.Repository)
+		}
+		chartVersion = versions[len(versions)-1].Original()
+	}
+
+	username, password, err := registry.LoadAuthForRegistry(o.Registry)
+	if err != nil {
+		return nil, "", errors.Wrapf(err, "failed to load registry auth for %q", o.Registry)
+	}
+
+	// helm replaces "+" in versions with "_" because "+" isn't allowed in tags
+	tag := strings.Replace(chartVersion, "+", "_", -1)
+	archive, err := registry.PullHelmChart(o.Registry, o.Repository, tag, username, password)
+	if err != nil {
+		return nil, "", errors.Wrapf(err, "failed to pull %s/%s:%s", o.Registry, o.Repository, tag)
+	}
+
+	return archive, chartVersion, nil
+}
+
+// pullOCIHelmChartsInRelease downloads the archives of all HelmChart kinds in the release
+// that reference an oci:// repository. Charts that are already in the release are skipped,
+// so airgap bundles that include the archive don't need access to the registry.
+// The archives are written to stagingDir.
+func pullOCIHelmChartsInRelease(release *Release, stagingDir string) ([]types.UpstreamFile, error) {
+	existingArchives := map[string]bool{}
+	for filename := range release.Manifests {
+		existingArchives[path.Base(filename)] = true
+	}
+	for filename := range release.StagedFiles {
+		existingArchives[path.Base(filename)] = true
+	}
+
+	chartFiles := []types.UpstreamFile{}
+	for _, content := range release.Manifests {
+		decode := scheme.Codecs.UniversalDeserializer().Decode
+		obj, gvk, err := decode(content, nil, nil)
+		if err != nil {
+			continue
+		}
+
+		if gvk.Group != "kots.io" || gvk.Version != "v1beta1" || gvk.Kind != "HelmChart" {
+			continue
+		}
+
+		chart := obj.(*kotsv1beta1.HelmChart).Spec.Chart
+		if !strings.HasPrefix(chart.Repository, "oci://") {
+			continue
+		}
+
+		// the archive is found in the release by its chart version when the chart is rendered
+		if chart.ChartVersion == "" {
+			return nil, errors.Errorf("chart %s from %s has no chartVersion", chart.Name, chart.Repository)
+		}
+
+		archiveName := fmt.Sprintf("%s-%s.tgz", chart.Name, chart.ChartVersion)
+		if existingArchives[archiveName] {
+			continue
+		}
+
+		u, err := url.Parse(fmt.Sprintf("%s/%s@%s", strings.TrimSuffix(chart.Repository, "/"), chart.Name, chart.ChartVersion))
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to parse repository for chart %s", chart.Name)
+		}
+
+		ociUpstream, err := parseOCIHelmURL(u)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to parse repository for chart %s", chart.Name)
+		}
+
+		archive, _, err := ociUpstream.pullChart()
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to pull chart %s", chart.Name)
+		}
+
+		stagedPath, err := stageFile(stagingDir, archiveName, bytes.NewReader(archive))
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to stage chart %s", chart.Name)
+		}
+
+		chartFiles = append(chartFiles, types.UpstreamFile{
+			Path:      archiveName,
+			LocalPath: stagedPath,
+		})
+		existingArchives[archiveName] = true
+	}
+
+	return chartFiles, nil
+}
@@ -0,0 +1,272 @@
+package upstream
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestOCIRegistry serves the given chart archives (keyed by tag) from the
+// "charts/mychart" repository, requiring an anonymous bearer token like most public registries
+func newTestOCIRegistry(t *testing.T, charts map[string][]byte) *httptest.Server {
+	var server *httptest.Server
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "repository:charts/mychart:pull", r.URL.Query().Get("scope"))
+		json.NewEncoder(w).Encode(map[string]string{"token": "test-token"})
+	})
+	mux.HandleFunc("/v2/", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer test-token" {
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="%s/token",service="test"`, server.URL))
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		p := strings.TrimPrefix(r.URL.Path, "/v2/charts/mychart/")
+		switch {
+		case p == "tags/list":
+			tags := []string{"latest"}
+			for tag := range charts {
+				tags = append(tags, tag)
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{"name": "charts/mychart", "tags": tags})
+
+		case strings.HasPrefix(p, "manifests/"):
+			archive, ok := charts[strings.TrimPrefix(p, "manifests/")]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			sum := sha256.Sum256(archive)
+			json.NewEncoder(w).Encode(ociManifestForTest("sha256:" + hex.EncodeToString(sum[:])))
+
+		case strings.HasPrefix(p, "blobs/"):
+			for _, archive := range charts {
+				sum := sha256.Sum256(archive)
+				if strings.TrimPrefix(p, "blobs/") == "sha256:"+hex.EncodeToString(sum[:]) {
+					w.Write(archive)
+					return
+				}
+			}
+			w.WriteHeader(http.StatusNotFound)
+
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+
+	server = httptest.NewServer(mux)
+	return server
+}
+
+func ociManifestForTest(layerDigest string) map[string]interface{} {
+	return map[string]interface{}{
+		"schemaVersion": 2,
+		"config": map[string]interface{}{
+			"mediaType": "application/vnd.cncf.helm.config.v1+json",
+			"digest":    "sha256:0000",
+		},
+		"layers": []map[string]interface{}{
+			{
+				"mediaType": "application/vnd.cncf.helm.chart.content.v1.tar+gzip",
+				"digest":    layerDigest,
+			},
+		},
+	}
+}
+
+func Test_parseOCIHelmURL(t *testing.T) {
+	tests := []struct {
+		name     string
+		uri      string
+		expected OCIHelmUpstream
+	}{
+		{
+			name: "with version",
+			uri:  "oci://registry.example.com/org/chart@1.2.3",
+			expected: OCIHelmUpstream{
+				Registry:   "registry.example.com",
+				Repository: "org/chart",
+				Version:    "1.2.3",
+			},
+		},
+		{
+			name: "without version",
+			uri:  "oci://localhost:5000/chart",
+			expected: OCIHelmUpstream{
+				Registry:   "localhost:5000",
+				Repository: "chart",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := require.New(t)
+
+			u, err := url.Parse(test.uri)
+			req.NoError(err)
+
+			actual, err := parseOCIHelmURL(u)
+			req.NoError(err)
+			assert.Equal(t, test.expected, *actual)
+		})
+	}
+}
+
+func Test_downloadOCIHelm(t *testing.T) {
+	req := require.New(t)
+
+	charts := map[string][]byte{
+		"1.0.0": createTestTarGz(t, map[string]string{
+			"mychart/Chart.yaml":  "apiVersion: v1\nname: mychart\nversion: 1.0.0\n",
+			"mychart/values.yaml": "replicas: 1\n",
+		}),
+		"1.1.0": createTestTarGz(t, map[string]string{
+			"mychart/Chart.yaml":  "apiVersion: v1\nname: mychart\nversion: 1.1.0\n",
+			"mychart/values.yaml": "replicas: 2\n",
+		}),
+	}
+	server := newTestOCIRegistry(t, charts)
+	defer server.Close()
+
+	registryHost := strings.TrimPrefix(server.URL, "http://")
+
+	tests := []struct {
+		name            string
+		uri             string
+		expectedVersion string
+	}{
+		{
+			name:            "pinned",
+			uri:             fmt.Sprintf("oci://%s/charts/mychart@1.0.0", registryHost),
+			expectedVersion: "1.0.0",
+		},
+		{
+			name:            "latest",
+			uri:             fmt.Sprintf("oci://%s/charts/mychart", registryHost),
+			expectedVersion: "1.1.0",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			u, err := url.Parse(test.uri)
+			req.NoError(err)
+
+			upstream, err := downloadOCIHelm(u)
+			req.NoError(err)
+
+			assert.Equal(t, "helm", upstream.Type)
+			assert.Equal(t, "mychart", upstream.Name)
+			assert.Equal(t, test.expectedVersion, upstream.UpdateCursor)
+			assert.Equal(t, test.expectedVersion, upstream.VersionLabel)
+
+			paths := []string{}
+			for _, f := range upstream.Files {
+				paths = append(paths, f.Path)
+			}
+			assert.ElementsMatch(t, []string{"Chart.yaml", "values.yaml"}, paths)
+		})
+	}
+}
+
+func Test_getUpdatesOCIHelm(t *testing.T) {
+	req := require.New(t)
+
+	charts := map[string][]byte{
+		"1.0.0":  {},
+		"1.10.0": {},
+		"1.2.0":  {},
+	}
+	server := newTestOCIRegistry(t, charts)
+	defer server.Close()
+
+	u, err := url.Parse(fmt.Sprintf("oci://%s/charts/mychart", strings.TrimPrefix(server.URL, "http://")))
+	req.NoError(err)
+
+	updates, err := getUpdatesOCIHelm(u, "1.0.0")
+	req.NoError(err)
+	assert.Equal(t, []Update{
+		{Cursor: "1.2.0", VersionLabel: "1.2.0"},
+		{Cursor: "1.10.0", VersionLabel: "1.10.0"},
+	}, updates)
+
+	updates, err = getUpdatesOCIHelm(u, "")
+	req.NoError(err)
+	assert.Len(t, updates, 3)
+}
+
+func Test_pullOCIHelmChartsInRelease(t *testing.T) {
+	req := require.New(t)
+
+	archive := createTestTarGz(t, map[string]string{
+		"mychart/Chart.yaml": "apiVersion: v1\nname: mychart\nversion: 1.0.0\n",
+	})
+	server := newTestOCIRegistry(t, map[string][]byte{"1.0.0": archive})
+	defer server.Close()
+
+	helmChart := fmt.Sprintf(`apiVersion: kots.io/v1beta1
+kind: HelmChart
+metadata:
+  name: mychart
+spec:
+  chart:
+    name: mychart
+    chartVersion: 1.0.0
+    repository: oci://%s/charts
+`, strings.TrimPrefix(server.URL, "http://"))
+
+	release := &Release{
+		Manifests: map[string][]byte{
+			"manifests/mychart.yaml": []byte(helmChart),
+		},
+	}
+
+	stagingDir, err := ioutil.TempDir("", "kots")
+	req.NoError(err)
+	defer os.RemoveAll(stagingDir)
+
+	files, err := pullOCIHelmChartsInRelease(release, stagingDir)
+	req.NoError(err)
+	req.Len(files, 1)
+	assert.Equal(t, "mychart-1.0.0.tgz", files[0].Path)
+	assert.Equal(t, stagingDir, filepath.Dir(files[0].LocalPath))
+	content, err := files[0].ReadContent()
+	req.NoError(err)
+	assert.Equal(t, archive, content)
+
+	// the archive is already part of the release, as it is in airgap bundles
+	release.StagedFiles = map[string]string{"manifests/mychart-1.0.0.tgz": files[0].LocalPath}
+	files, err = pullOCIHelmChartsInRelease(release, stagingDir)
+	req.NoError(err)
+	assert.Empty(t, files)
+
+	release.StagedFiles = nil
+	release.Manifests["manifests/mychart-1.0.0.tgz"] = archive
+	files, err = pullOCIHelmChartsInRelease(release, stagingDir)
+	req.NoError(err)
+	assert.Empty(t, files)
+
+	// a chart without a version can't be found in the release when it's rendered
+	release.Manifests = map[string][]byte{
+		"manifests/mychart.yaml": []byte(strings.Replace(helmChart, "    chartVersion: 1.0.0\n", "", 1)),
+	}
+	_, err = pullOCIHelmChartsInRelease(release, stagingDir)
+	req.Error(err)
+	assert.Contains(t, err.Error(), "chart mychart from oci://")
+	assert.Contains(t, err.Error(), "has no chartVersion")
+}
@@ -1,11 +1,20 @@
 package upstream
 
 import (
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
 	"net/url"
+	"os"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"k8s.io/helm/cmd/helm/search"
+	"k8s.io/helm/pkg/proto/hapi/chart"
+	"k8s.io/helm/pkg/repo"
 )
 
 func Test_parseHelmURL(t *testing.T) {
@@ -23,6 +32,13 @@ func Test_parseHelmURL(t *testing.T) {
 			expectedChartName:    "mysql",
 			expectedChartVersion: "",
 		},
+		{
+			name:                 "stable/redis@>=2.0 <3.0",
+			uri:                  "helm://stable/redis@>=2.0 <3.0",
+			expectedRepo:         "stable",
+			expectedChartName:    "redis",
+			expectedChartVersion: ">=2.0 <3.0",
+		},
 		{
 			name:                 "stable/mysql@1.3.1",
 			uri:                  "helm://stable/mysql@1.3.1",
@@ -47,3 +63,152 @@ func Test_parseHelmURL(t *testing.T) {
 		})
 	}
 }
+
+func Test_downloadHelmWithAuthAndCA(t *testing.T) {
+	req := require.New(t)
+
+	archive := createTestTarGz(t, map[string]string{
+		"mychart/Chart.yaml":  "apiVersion: v1\nname: mychart\nversion: 0.2.0\n",
+		"mychart/values.yaml": "replicas: 1\n",
+	})
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/charts/index.yaml", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `apiVersion: v1
+entries:
+  mychart:
+  - name: mychart
+    version: 0.1.0
+    urls:
+    - mychart-0.1.0.tgz
+  - name: mychart
+    version: 0.2.0
+    appVersion: 5.0.1
+    description: my chart
+    urls:
+    - mychart-0.2.0.tgz
+`)
+	})
+	mux.HandleFunc("/charts/mychart-0.2.0.tgz", func(w http.ResponseWriter, r *http.Request) {
+		w.Write(archive)
+	})
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		username, password, ok := r.BasicAuth()
+		if !ok || username != "user" || password != "pass" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		mux.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+
+	caFile, err := ioutil.TempFile("", "ca")
+	req.NoError(err)
+	defer os.Remove(caFile.Name())
+	err = pem.Encode(caFile, &pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	req.NoError(err)
+	req.NoError(caFile.Close())
+
+	u, err := url.ParseRequestURI("helm://private/mychart")
+	req.NoError(err)
+
+	_, err = downloadHelm(u, server.URL+"/charts", HelmRepoOptions{CAFile: caFile.Name()}, nil)
+	req.Error(err, "expected unauthorized without credentials")
+
+	repoOptions := HelmRepoOptions{
+		Username: "user",
+		Password: "pass",
+		CAFile:   caFile.Name(),
+	}
+
+	upstream, err := downloadHelm(u, server.URL+"/charts", repoOptions, nil)
+	req.NoError(err)
+	assert.Equal(t, "mychart", upstream.Name)
+	assert.Equal(t, "0.2.0", upstream.UpdateCursor)
+
+	updates, err := getUpdatesHelm(u, server.URL+"/charts", repoOptions, "")
+	req.NoError(err)
+	assert.Len(t, updates, 2)
+
+	updates, err = getUpdatesHelm(u, server.URL+"/charts", repoOptions, "0.1.0")
+	req.NoError(err)
+	assert.Equal(t, []Update{{Cursor: "0.2.0", VersionLabel: "0.2.0", AppVersion: "5.0.1", Description: "my chart"}}, updates)
+}
+
+func Test_helmChartVersions(t *testing.T) {
+	ind := repo.NewIndexFile()
+	for _, v := range []string{"10.4.0", "10.5.1", "10.5.3", "10.6.0-rc.1", "10.6.0", "2.1.0", "3.0.0", "not-semver"} {
+		ind.Add(&chart.Metadata{Name: "redis", Version: v, AppVersion: "app-" + v}, "redis-"+v+".tgz", "https://charts.example.com", "")
+	}
+	ind.Add(&chart.Metadata{Name: "mysql", Version: "10.5.2"}, "mysql-10.5.2.tgz", "https://charts.example.com", "")
+
+	i := search.NewIndex()
+	i.AddRepo("stable", ind, true)
+
+	tests := []struct {
+		name       string
+		constraint string
+		expected   []string
+		expectErr  bool
+	}{
+		{
+			name:       "all, sorted, without pre-releases",
+			constraint: "",
+			expected:   []string{"2.1.0", "3.0.0", "10.4.0", "10.5.1", "10.5.3", "10.6.0"},
+		},
+		{
+			name:       "tilde",
+			constraint: "~10.5",
+			expected:   []string{"10.5.1", "10.5.3"},
+		},
+		{
+			name:       "range",
+			constraint: ">=2.0 <3.0",
+			expected:   []string{"2.1.0"},
+		},
+		{
+			name:       "pre-releases when the constraint has one",
+			constraint: ">=10.6.0-0",
+			expected:   []string{"10.6.0-rc.1", "10.6.0"},
+		},
+		{
+			name:       "exact pre-release",
+			constraint: "10.6.0-rc.1",
+			expected:   []string{"10.6.0-rc.1"},
+		},
+		{
+			name:       "exact non-semver",
+			constraint: "not-semver",
+			expected:   []string{"not-semver"},
+		},
+		{
+			name:       "no match",
+			constraint: ">=11",
+			expected:   []string{},
+		},
+		{
+			name:       "invalid constraint",
+			constraint: "~~nope",
+			expectErr:  true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := require.New(t)
+
+			chartVersions, err := helmChartVersions(i, "redis", test.constraint)
+			if test.expectErr {
+				req.Error(err)
+				return
+			}
+			req.NoError(err)
+
+			actual := []string{}
+			for _, chartVersion := range chartVersions {
+				actual = append(actual, chartVersion.GetVersion())
+			}
+			assert.Equal(t, test.expected, actual)
+		})
+	}
+}
@@ -1,10 +1,265 @@
 package upstream
 
 import (
+	"archive/zip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+
 	"github.com/pkg/errors"
 	"github.com/replicatedhq/kots/pkg/upstream/types"
+	"github.com/replicatedhq/kots/pkg/version"
 )
 
-func downloadHttp(httpURI string) (*types.Upstream, error) {
-	return nil, errors.New("downloadHttp not implemented")
+var versionSuffixRegex = regexp.MustCompile(`[-_]v?[0-9]+(\.[0-9]+)*([-+].*)?$`)
+
+// HttpUpstream is a parsed http(s):// upstream uri pointing to a .tar.gz, .tgz or .zip
+// archive of manifests. The archive can be pinned by adding #sha256=<hex digest> to the uri.
+type HttpUpstream struct {
+	URL    string
+	SHA256 string
+}
+
+func getUpdatesHttp(upstreamURI string, currentCursor string) ([]Update, error) {
+	httpUpstream, err := parseHttpURL(upstreamURI)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse http uri")
+	}
+
+	// a pinned archive can never change without failing the checksum
+	if httpUpstream.SHA256 != "" {
+		return []Update{}, nil
+	}
+
+	req, err := httpUpstream.getRequest("HEAD")
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create http request")
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to execute head request")
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		return nil, errors.Errorf("unexpected result from head request: %d", resp.StatusCode)
+	}
+
+	cursor := httpCursorFromHeaders(resp.Header)
+	if cursor == "" {
+		return nil, errors.New("server did not return an ETag or Last-Modified header")
+	}
+
+	if cursor == currentCursor {
+		return []Update{}, nil
+	}
+
+	return []Update{{Cursor: cursor, VersionLabel: httpUpstream.versionLabel()}}, nil
+}
+
+func downloadHttp(upstreamURI string) (*types.Upstream, error) {
+	httpUpstream, err := parseHttpURL(upstreamURI)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse http uri")
+	}
+
+	archiveFormat := httpUpstream.archiveFormat()
+	if archiveFormat == "" {
+		return nil, errors.Errorf("unsupported archive type in %s, expected .tar.gz, .tgz or .zip", httpUpstream.URL)
+	}
+
+	req, err := httpUpstream.getRequest("GET")
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create http request")
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to execute get request")
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		return nil, errors.Errorf("unexpected result from get request: %d", resp.StatusCode)
+	}
+
+	archiveFile, err := ioutil.TempFile("", "kots")
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create temp file")
+	}
+	defer os.Remove(archiveFile.Name())
+	defer archiveFile.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(archiveFile, h), resp.Body); err != nil {
+		return nil, errors.Wrap(err, "failed to download archive")
+	}
+	checksum := hex.EncodeToString(h.Sum(nil))
+
+	if httpUpstream.SHA256 != "" && !strings.EqualFold(httpUpstream.SHA256, checksum) {
+		return nil, errors.Errorf("checksum mismatch: expected sha256 %s, got %s", httpUpstream.SHA256, checksum)
+	}
+
+	var files []types.UpstreamFile
+	if archiveFormat == "zip" {
+		files, err = readZip(archiveFile.Name())
+	} else {
+		files, err = readTarGz(archiveFile.Name())
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read archive")
+	}
+
+	updateCursor := httpCursorFromHeaders(resp.Header)
+	if updateCursor == "" {
+		updateCursor = checksum
+	}
+
+	upstream := &types.Upstream{
+		URI:          upstreamURI,
+		Name:         httpUpstream.name(),
+		Type:         "http",
+		Files:        files,
+		UpdateCursor: updateCursor,
+		VersionLabel: httpUpstream.versionLabel(),
+	}
+
+	return upstream, nil
+}
+
+func parseHttpURL(upstreamURI string) (*HttpUpstream, error) {
+	// url.ParseRequestURI does not split the fragment
+	u, err := url.Parse(upstreamURI)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse uri")
+	}
+
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, errors.Errorf("unexpected scheme %q", u.Scheme)
+	}
+
+	httpUpstream := HttpUpstream{}
+
+	if u.Fragment != "" {
+		if !strings.HasPrefix(u.Fragment, "sha256=") {
+			return nil, errors.Errorf("unsupported fragment %q, expected sha256=<digest>", u.Fragment)
+		}
+		httpUpstream.SHA256 = strings.TrimPrefix(u.Fragment, "sha256=")
+		u.Fragment = ""
+	}
+
+	httpUpstream.URL = u.String()
+
+	return &httpUpstream, nil
+}
+
+func (h *HttpUpstream) getRequest(method string) (*http.Request, error) {
+	req, err := http.NewRequest(method, h.URL, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to call newrequest")
+	}
+
+	req.Header.Add("User-Agent", fmt.Sprintf("KOTS/%s", version.Version()))
+
+	return req, nil
+}
+
+func (h *HttpUpstream) archiveFormat() string {
+	u, err := url.Parse(h.URL)
+	if err != nil {
+		return ""
+	}
+
+	return archiveFormatFromPath(u.Path)
+}
+
+// versionLabel is the archive file name without the extension, such as "app-1.2.3"
+func (h *HttpUpstream) versionLabel() string {
+	u, err := url.Parse(h.URL)
+	if err != nil {
+		return ""
+	}
+
+	return archiveVersionLabel(u.Path)
+}
+
+// name is the version label without a trailing version, so that it's stable between releases
+func (h *HttpUpstream) name() string {
+	return archiveName(h.versionLabel())
+}
+
+func archiveFormatFromPath(p string) string {
+	p = strings.ToLower(p)
+	if strings.HasSuffix(p, ".tar.gz") || strings.HasSuffix(p, ".tgz") {
+		return "tar.gz"
+	}
+	if strings.HasSuffix(p, ".zip") {
+		return "zip"
+	}
+
+	return ""
+}
+
+func archiveVersionLabel(p string) string {
+	name := path.Base(p)
+	for _, ext := range []string{".tar.gz", ".tgz", ".zip"} {
+		if strings.HasSuffix(strings.ToLower(name), ext) {
+			return name[:len(name)-len(ext)]
+		}
+	}
+
+	return name
+}
+
+func archiveName(versionLabel string) string {
+	return versionSuffixRegex.ReplaceAllString(versionLabel, "")
+}
+
+func httpCursorFromHeaders(header http.Header) string {
+	if etag := header.Get("ETag"); etag != "" {
+		return etag
+	}
+
+	return header.Get("Last-Modified")
+}
+
+func readZip(source string) ([]types.UpstreamFile, error) {
+	r, err := zip.OpenReader(source)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to open archive")
+	}
+	defer r.Close()
+
+	upstreamFiles := []types.UpstreamFile{}
+	for _, f := range r.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+
+		rc, err := f.Open()
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to open %s in zip archive", f.Name)
+		}
+		content, err := ioutil.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to read %s from zip archive", f.Name)
+		}
+
+		upstreamFile := types.UpstreamFile{
+			Path:    f.Name,
+			Content: content,
+		}
+
+		upstreamFiles = append(upstreamFiles, upstreamFile)
+	}
+
+	return stripCommonPrefix(upstreamFiles), nil
 }
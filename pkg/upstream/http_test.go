@@ -0,0 +1,182 @@
+package upstream
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testArchiveFiles = map[string]string{
+	"app-1.2.3/manifests/deployment.yaml": "apiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: web\n",
+	"app-1.2.3/manifests/service.yaml":    "apiVersion: v1\nkind: Service\nmetadata:\n  name: web\n",
+}
+
+func Test_parseHttpURL(t *testing.T) {
+	tests := []struct {
+		name     string
+		uri      string
+		expected HttpUpstream
+	}{
+		{
+			name: "tar.gz",
+			uri:  "https://example.com/releases/app-1.2.3.tar.gz",
+			expected: HttpUpstream{
+				URL: "https://example.com/releases/app-1.2.3.tar.gz",
+			},
+		},
+		{
+			name: "zip with checksum",
+			uri:  "https://example.com/releases/app.zip?token=abc#sha256=0123abcd",
+			expected: HttpUpstream{
+				URL:    "https://example.com/releases/app.zip?token=abc",
+				SHA256: "0123abcd",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := require.New(t)
+
+			actual, err := parseHttpURL(test.uri)
+			req.NoError(err)
+			assert.Equal(t, test.expected, *actual)
+		})
+	}
+}
+
+func Test_downloadHttp(t *testing.T) {
+	req := require.New(t)
+
+	tarGz := createTestTarGz(t, testArchiveFiles)
+	zipArchive := createTestZip(t, testArchiveFiles)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/app-1.2.3.tar.gz", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"v1"`)
+		w.Write(tarGz)
+	})
+	mux.HandleFunc("/app-1.2.3.zip", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Last-Modified", "Wed, 21 Oct 2015 07:28:00 GMT")
+		w.Write(zipArchive)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	tarGzSum := sha256.Sum256(tarGz)
+
+	tests := []struct {
+		name           string
+		uri            string
+		expectedCursor string
+		expectErr      bool
+	}{
+		{
+			name:           "tar.gz",
+			uri:            server.URL + "/app-1.2.3.tar.gz",
+			expectedCursor: `"v1"`,
+		},
+		{
+			name:           "zip",
+			uri:            server.URL + "/app-1.2.3.zip",
+			expectedCursor: "Wed, 21 Oct 2015 07:28:00 GMT",
+		},
+		{
+			name:           "pinned",
+			uri:            server.URL + "/app-1.2.3.tar.gz#sha256=" + hex.EncodeToString(tarGzSum[:]),
+			expectedCursor: `"v1"`,
+		},
+		{
+			name:      "checksum mismatch",
+			uri:       server.URL + "/app-1.2.3.tar.gz#sha256=0000",
+			expectErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			u, err := downloadHttp(test.uri)
+			if test.expectErr {
+				req.Error(err)
+				return
+			}
+			req.NoError(err)
+
+			assert.Equal(t, "http", u.Type)
+			assert.Equal(t, "app", u.Name)
+			assert.Equal(t, "app-1.2.3", u.VersionLabel)
+			assert.Equal(t, test.expectedCursor, u.UpdateCursor)
+
+			paths := []string{}
+			for _, f := range u.Files {
+				paths = append(paths, f.Path)
+			}
+			assert.ElementsMatch(t, []string{"deployment.yaml", "service.yaml"}, paths)
+		})
+	}
+}
+
+func Test_getUpdatesHttp(t *testing.T) {
+	req := require.New(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"v2"`)
+	}))
+	defer server.Close()
+
+	updates, err := getUpdatesHttp(server.URL+"/app.tgz", `"v1"`)
+	req.NoError(err)
+	assert.Equal(t, []Update{{Cursor: `"v2"`, VersionLabel: "app"}}, updates)
+
+	updates, err = getUpdatesHttp(server.URL+"/app.tgz", `"v2"`)
+	req.NoError(err)
+	assert.Empty(t, updates)
+}
+
+func createTestTarGz(t *testing.T, files map[string]string) []byte {
+	req := require.New(t)
+
+	var b bytes.Buffer
+	gw := gzip.NewWriter(&b)
+	tw := tar.NewWriter(gw)
+	for name, content := range files {
+		err := tw.WriteHeader(&tar.Header{
+			Name:     name,
+			Mode:     0644,
+			Size:     int64(len(content)),
+			Typeflag: tar.TypeReg,
+		})
+		req.NoError(err)
+		_, err = tw.Write([]byte(content))
+		req.NoError(err)
+	}
+	req.NoError(tw.Close())
+	req.NoError(gw.Close())
+
+	return b.Bytes()
+}
+
+func createTestZip(t *testing.T, files map[string]string) []byte {
+	req := require.New(t)
+
+	var b bytes.Buffer
+	zw := zip.NewWriter(&b)
+	for name, content := range files {
+		w, err := zw.Create(name)
+		req.NoError(err)
+		_, err = w.Write([]byte(content))
+		req.NoError(err)
+	}
+	req.NoError(zw.Close())
+
+	return b.Bytes()
+}
@@ -0,0 +1,236 @@
+package upstream
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	defaultHTTPTimeout    = 30 * time.Second
+	defaultHTTPMaxRetries = 4
+	maxHTTPRetryBackoff   = 30 * time.Second
+)
+
+// httpRetryBackoff is the wait before the first retry, it doubles with every retry
+var httpRetryBackoff = time.Second
+
+// HTTPClientOptions configure the client used for the replicated.app API. Proxies are read from
+// the HTTPS_PROXY, HTTP_PROXY and NO_PROXY environment variables.
+type HTTPClientOptions struct {
+	// Timeout is the time to wait for a connection, for the response headers, and for each read
+	// of the response body. Downloads are not limited, as long as data keeps arriving. Defaults
+	// to 30 seconds.
+	Timeout time.Duration
+	// MaxRetries is the number of retries for 5xx and 429 responses and failed connections.
+	// Defaults to 4, a negative value disables retries.
+	MaxRetries int
+	// CAFile is a pem bundle that's trusted in addition to the system roots,
+	// such as the ca of a tls intercepting proxy
+	CAFile                string
+	InsecureSkipTLSVerify bool
+}
+
+// HTTPClientOptionsFromEnv reads the options from the same KOTS_ environment variables that
+// can be used instead of the cli flags, for callers that don't have flags
+func HTTPClientOptionsFromEnv() HTTPClientOptions {
+	options := HTTPClientOptions{
+		CAFile: os.Getenv("KOTS_UPSTREAM_CA_FILE"),
+	}
+
+	if timeout, err := time.ParseDuration(os.Getenv("KOTS_UPSTREAM_TIMEOUT")); err == nil {
+		options.Timeout = timeout
+	}
+	if insecure, err := strconv.ParseBool(os.Getenv("KOTS_INSECURE_SKIP_TLS_VERIFY")); err == nil {
+		options.InsecureSkipTLSVerify = insecure
+	}
+
+	return options
+}
+
+// newHTTPClient creates a client that retries with exponential backoff
+func newHTTPClient(options HTTPClientOptions) (*http.Client, error) {
+	timeout := options.Timeout
+	if timeout == 0 {
+		timeout = defaultHTTPTimeout
+	}
+
+	maxRetries := options.MaxRetries
+	if maxRetries == 0 {
+		maxRetries = defaultHTTPMaxRetries
+	} else if maxRetries < 0 {
+		maxRetries = 0
+	}
+
+	tlsConfig := &tls.Config{
+		InsecureSkipVerify: options.InsecureSkipTLSVerify,
+	}
+	if options.CAFile != "" {
+		caCert, err := ioutil.ReadFile(options.CAFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to read ca file")
+		}
+
+		rootCAs, err := x509.SystemCertPool()
+		if err != nil || rootCAs == nil {
+			rootCAs = x509.NewCertPool()
+		}
+		if !rootCAs.AppendCertsFromPEM(caCert) {
+			return nil, errors.Errorf("no certificates found in %s", options.CAFile)
+		}
+		tlsConfig.RootCAs = rootCAs
+	}
+
+	transport := &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   timeout,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		TLSClientConfig:       tlsConfig,
+		TLSHandshakeTimeout:   timeout,
+		ResponseHeaderTimeout: timeout,
+		ExpectContinueTimeout: time.Second,
+		IdleConnTimeout:       90 * time.Second,
+		MaxIdleConns:          100,
+	}
+
+	return &http.Client{
+		Transport: &retryTransport{
+			transport:   transport,
+			maxRetries:  maxRetries,
+			readTimeout: timeout,
+		},
+	}, nil
+}
+
+type retryTransport struct {
+	transport  http.RoundTripper
+	maxRetries int
+	// readTimeout is the longest wait for data from the response body, after which the request
+	// is canceled so that a stalled download doesn't hang forever
+	readTimeout time.Duration
+}
+
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx, cancel := context.WithCancel(req.Context())
+	resp, err := t.roundTripWithRetries(req.WithContext(ctx))
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	resp.Body = newReadTimeoutBody(resp.Body, t.readTimeout, cancel)
+	return resp, nil
+}
+
+func (t *retryTransport) roundTripWithRetries(req *http.Request) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		if attempt > 0 && req.Body != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, errors.Wrap(err, "failed to get request body to retry")
+			}
+			retryReq := *req
+			retryReq.Body = body
+			req = &retryReq
+		}
+
+		resp, err := t.transport.RoundTrip(req)
+		if attempt >= t.maxRetries || !shouldRetry(req, resp, err) {
+			return resp, err
+		}
+
+		wait := retryBackoff(attempt, resp)
+		if resp != nil {
+			io.Copy(ioutil.Discard, io.LimitReader(resp.Body, 4096))
+			resp.Body.Close()
+		}
+
+		select {
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		case <-time.After(wait):
+		}
+	}
+}
+
+// readTimeoutBody cancels the request when a read of the body doesn't return within the timeout
+type readTimeoutBody struct {
+	body     io.ReadCloser
+	timeout  time.Duration
+	timer    *time.Timer
+	cancel   context.CancelFunc
+	timedOut int32
+}
+
+func newReadTimeoutBody(body io.ReadCloser, timeout time.Duration, cancel context.CancelFunc) *readTimeoutBody {
+	b := &readTimeoutBody{
+		body:    body,
+		timeout: timeout,
+		cancel:  cancel,
+	}
+	b.timer = time.AfterFunc(timeout, func() {
+		atomic.StoreInt32(&b.timedOut, 1)
+		cancel()
+	})
+	return b
+}
+
+func (b *readTimeoutBody) Read(p []byte) (int, error) {
+	n, err := b.body.Read(p)
+	if err != nil && atomic.LoadInt32(&b.timedOut) == 1 {
+		return n, errors.Errorf("no data was received from the upstream for %s", b.timeout)
+	}
+	b.timer.Reset(b.timeout)
+	return n, err
+}
+
+func (b *readTimeoutBody) Close() error {
+	b.timer.Stop()
+	b.cancel()
+	return b.body.Close()
+}
+
+func shouldRetry(req *http.Request, resp *http.Response, err error) bool {
+	// requests with a body that can't be replayed can't be retried
+	if req.Body != nil && req.GetBody == nil {
+		return false
+	}
+
+	if err != nil {
+		return req.Context().Err() == nil
+	}
+
+	return resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+}
+
+// retryBackoff doubles the wait with every attempt, but respects the Retry-After header
+// when the server sends one
+func retryBackoff(attempt int, resp *http.Response) time.Duration {
+	if resp != nil {
+		if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && seconds >= 0 {
+			wait := time.Duration(seconds) * time.Second
+			if wait > maxHTTPRetryBackoff {
+				wait = maxHTTPRetryBackoff
+			}
+			return wait
+		}
+	}
+
+	wait := httpRetryBackoff << uint(attempt)
+	if wait > maxHTTPRetryBackoff || wait <= 0 {
+		wait = maxHTTPRetryBackoff
+	}
+	return wait
+}
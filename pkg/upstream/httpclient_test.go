@@ -0,0 +1,183 @@
+package upstream
+
+import (
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_newHTTPClientRetries(t *testing.T) {
+	httpRetryBackoff = time.Millisecond
+	defer func() {
+		httpRetryBackoff = time.Second
+	}()
+
+	tests := []struct {
+		name             string
+		responses        []int
+		maxRetries       int
+		expectedStatus   int
+		expectedRequests int
+	}{
+		{
+			name:             "retries 5xx",
+			responses:        []int{http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusOK},
+			expectedStatus:   http.StatusOK,
+			expectedRequests: 3,
+		},
+		{
+			name:             "retries 429",
+			responses:        []int{http.StatusTooManyRequests, http.StatusOK},
+			expectedStatus:   http.StatusOK,
+			expectedRequests: 2,
+		},
+		{
+			name:             "does not retry 4xx",
+			responses:        []int{http.StatusUnauthorized, http.StatusOK},
+			expectedStatus:   http.StatusUnauthorized,
+			expectedRequests: 1,
+		},
+		{
+			name:             "gives up after max retries",
+			responses:        []int{http.StatusInternalServerError, http.StatusInternalServerError, http.StatusInternalServerError},
+			maxRetries:       1,
+			expectedStatus:   http.StatusInternalServerError,
+			expectedRequests: 2,
+		},
+		{
+			name:             "retries can be disabled",
+			responses:        []int{http.StatusInternalServerError, http.StatusOK},
+			maxRetries:       -1,
+			expectedStatus:   http.StatusInternalServerError,
+			expectedRequests: 1,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := require.New(t)
+
+			requests := 0
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				status := test.responses[requests]
+				requests++
+				if status == http.StatusTooManyRequests {
+					w.Header().Set("Retry-After", "0")
+				}
+				w.WriteHeader(status)
+			}))
+			defer server.Close()
+
+			client, err := newHTTPClient(HTTPClientOptions{MaxRetries: test.maxRetries})
+			req.NoError(err)
+
+			resp, err := client.Get(server.URL)
+			req.NoError(err)
+			resp.Body.Close()
+
+			assert.Equal(t, test.expectedStatus, resp.StatusCode)
+			assert.Equal(t, test.expectedRequests, requests)
+		})
+	}
+}
+
+func Test_newHTTPClientTimeout(t *testing.T) {
+	req := require.New(t)
+
+	done := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-done
+	}))
+	defer server.Close()
+	defer close(done)
+
+	client, err := newHTTPClient(HTTPClientOptions{Timeout: 50 * time.Millisecond, MaxRetries: -1})
+	req.NoError(err)
+
+	_, err = client.Get(server.URL)
+	assert.Error(t, err)
+}
+
+func Test_newHTTPClientStalledBody(t *testing.T) {
+	req := require.New(t)
+
+	done := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("partial"))
+		w.(http.Flusher).Flush()
+		<-done
+	}))
+	defer server.Close()
+	defer close(done)
+
+	client, err := newHTTPClient(HTTPClientOptions{Timeout: 50 * time.Millisecond, MaxRetries: -1})
+	req.NoError(err)
+
+	resp, err := client.Get(server.URL)
+	req.NoError(err)
+	defer resp.Body.Close()
+
+	_, err = ioutil.ReadAll(resp.Body)
+	req.Error(err)
+	assert.Contains(t, err.Error(), "no data was received from the upstream for 50ms")
+}
+
+func Test_newHTTPClientTLS(t *testing.T) {
+	req := require.New(t)
+
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	caFile, err := ioutil.TempFile("", "ca")
+	req.NoError(err)
+	defer os.Remove(caFile.Name())
+	req.NoError(pem.Encode(caFile, &pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}))
+	req.NoError(caFile.Close())
+
+	tests := []struct {
+		name        string
+		options     HTTPClientOptions
+		expectError bool
+	}{
+		{
+			name:        "untrusted certificate",
+			options:     HTTPClientOptions{MaxRetries: -1},
+			expectError: true,
+		},
+		{
+			name:    "ca file",
+			options: HTTPClientOptions{CAFile: caFile.Name(), MaxRetries: -1},
+		},
+		{
+			name:    "insecure skip tls verify",
+			options: HTTPClientOptions{InsecureSkipTLSVerify: true, MaxRetries: -1},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := require.New(t)
+
+			client, err := newHTTPClient(test.options)
+			req.NoError(err)
+
+			resp, err := client.Get(server.URL)
+			if test.expectError {
+				assert.Error(t, err)
+				return
+			}
+			req.NoError(err)
+			resp.Body.Close()
+			assert.Equal(t, http.StatusOK, resp.StatusCode)
+		})
+	}
+}
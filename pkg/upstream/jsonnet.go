@@ -0,0 +1,42 @@
+package upstream
+
+import (
+	"github.com/pkg/errors"
+	"github.com/replicatedhq/kots/pkg/upstream/types"
+)
+
+// parseJsonnetURL parses a jsonnet:// upstream uri, which has the same format as a kustomize:// uri:
+// jsonnet://host/org/repo//path/to/main.jsonnet?ref=v1.2.0. The path after the // is the file that's
+// evaluated, or a dir with a main.jsonnet.
+func parseJsonnetURL(upstreamURI string) (*GitUpstream, error) {
+	return parseRemoteBaseURL(upstreamURI, "jsonnet")
+}
+
+func getUpdatesJsonnet(upstreamURI string, currentCursor string) ([]Update, error) {
+	gitUpstream, err := parseJsonnetURL(upstreamURI)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse jsonnet uri")
+	}
+
+	return getUpdatesGitRepo(gitUpstream, currentCursor)
+}
+
+// downloadJsonnet fetches the whole repo at the ref, so that vendored libraries can be imported.
+// The entrypoint is evaluated when the base is rendered.
+func downloadJsonnet(upstreamURI string) (*types.Upstream, error) {
+	gitUpstream, err := parseJsonnetURL(upstreamURI)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse jsonnet uri")
+	}
+
+	upstream, err := downloadGitRepoAtRef(gitUpstream)
+	if err != nil {
+		return nil, err
+	}
+
+	upstream.URI = upstreamURI
+	upstream.Type = "jsonnet"
+	upstream.JsonnetEntrypoint = gitUpstream.Subdir
+
+	return upstream, nil
+}
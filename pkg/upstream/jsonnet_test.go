@@ -0,0 +1,27 @@
+package upstream
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_downloadJsonnet(t *testing.T) {
+	req := require.New(t)
+
+	bareDir, commits, cleanup := createTestGitRepo(t)
+	defer cleanup()
+
+	upstream, err := downloadJsonnet("jsonnet://" + bareDir + "//manifests/main.jsonnet?ref=v1.0.0")
+	req.NoError(err)
+
+	assert.Equal(t, "jsonnet", upstream.Type)
+	assert.Equal(t, commits[0].String(), upstream.UpdateCursor)
+	assert.Equal(t, "v1.0.0", upstream.VersionLabel)
+	assert.Equal(t, "manifests/main.jsonnet", upstream.JsonnetEntrypoint)
+	assert.Len(t, upstream.Files, 2)
+
+	_, err = downloadJsonnet("kustomize://" + bareDir + "//manifests")
+	req.Error(err)
+}
@@ -0,0 +1,123 @@
+package upstream
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/replicatedhq/kots/pkg/upstream/types"
+)
+
+// parseKustomizeURL parses a kustomize:// upstream uri, which has the same format as a kustomize
+// remote base: kustomize://host/org/repo//path/to/kustomization?ref=v1.2.0. The repo is cloned
+// over https, or read from the local filesystem when the host is empty.
+func parseKustomizeURL(upstreamURI string) (*GitUpstream, error) {
+	return parseRemoteBaseURL(upstreamURI, "kustomize")
+}
+
+// parseRemoteBaseURL parses an upstream uri in the format of a kustomize remote base,
+// with the scheme of the upstream type
+func parseRemoteBaseURL(upstreamURI string, scheme string) (*GitUpstream, error) {
+	u, err := url.ParseRequestURI(upstreamURI)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse uri")
+	}
+
+	if u.Scheme != scheme {
+		return nil, errors.Errorf("unexpected scheme %q", u.Scheme)
+	}
+
+	gitUpstream := GitUpstream{
+		Ref: u.Query().Get("ref"),
+	}
+
+	repoPath := u.Path
+	if idx := strings.Index(strings.TrimPrefix(repoPath, "/"), "//"); idx != -1 {
+		idx++
+		gitUpstream.Subdir = strings.Trim(repoPath[idx+2:], "/")
+		repoPath = repoPath[:idx]
+	}
+
+	repoPath = strings.TrimSuffix(repoPath, "/")
+	if repoPath == "" {
+		return nil, errors.New("missing repo path")
+	}
+
+	if u.Host == "" {
+		gitUpstream.RepoURI = repoPath
+	} else {
+		gitUpstream.RepoURI = "https://" + u.Host + repoPath
+	}
+
+	return &gitUpstream, nil
+}
+
+func getUpdatesKustomize(upstreamURI string, currentCursor string) ([]Update, error) {
+	gitUpstream, err := parseKustomizeURL(upstreamURI)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse kustomize uri")
+	}
+
+	return getUpdatesGitRepo(gitUpstream, currentCursor)
+}
+
+// downloadKustomize fetches the whole repo at the ref, since the kustomization can include
+// bases and patches from anywhere in it. The kustomization is built when the base is rendered.
+func downloadKustomize(upstreamURI string) (*types.Upstream, error) {
+	gitUpstream, err := parseKustomizeURL(upstreamURI)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse kustomize uri")
+	}
+
+	upstream, err := downloadGitRepoAtRef(gitUpstream)
+	if err != nil {
+		return nil, err
+	}
+
+	upstream.URI = upstreamURI
+	upstream.Type = "kustomize"
+	upstream.KustomizationDir = gitUpstream.Subdir
+
+	return upstream, nil
+}
+
+// downloadGitRepoAtRef returns all of the files in the repo at the ref of the upstream
+func downloadGitRepoAtRef(gitUpstream *GitUpstream) (*types.Upstream, error) {
+	repo, err := cloneGitRepo(gitUpstream)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to clone git repo")
+	}
+
+	revision := "HEAD"
+	if gitUpstream.Ref != "" {
+		revision = gitUpstream.Ref
+	}
+	hash, err := resolveGitRevision(repo, revision)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to resolve ref %q", revision)
+	}
+
+	commit, err := repo.CommitObject(*hash)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get commit")
+	}
+
+	files, err := gitCommitToFiles(commit, "")
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read files from commit")
+	}
+
+	tags, err := gitTagsByCommit(repo)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list tags")
+	}
+
+	upstream := &types.Upstream{
+		Name:         gitUpstream.name(),
+		Files:        files,
+		UpdateCursor: hash.String(),
+		VersionLabel: gitVersionLabel(*hash, tags),
+	}
+
+	return upstream, nil
+}
@@ -0,0 +1,73 @@
+package upstream
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_parseKustomizeURL(t *testing.T) {
+	tests := []struct {
+		name     string
+		uri      string
+		expected GitUpstream
+	}{
+		{
+			name: "remote base with ref",
+			uri:  "kustomize://github.com/org/repo//config/default?ref=v1.2",
+			expected: GitUpstream{
+				RepoURI: "https://github.com/org/repo",
+				Subdir:  "config/default",
+				Ref:     "v1.2",
+			},
+		},
+		{
+			name: "repo root",
+			uri:  "kustomize://github.com/org/repo",
+			expected: GitUpstream{
+				RepoURI: "https://github.com/org/repo",
+			},
+		},
+		{
+			name: "local repo",
+			uri:  "kustomize:///tmp/repo.git//overlays/prod",
+			expected: GitUpstream{
+				RepoURI: "/tmp/repo.git",
+				Subdir:  "overlays/prod",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := require.New(t)
+
+			actual, err := parseKustomizeURL(test.uri)
+			req.NoError(err)
+			assert.Equal(t, test.expected, *actual)
+		})
+	}
+}
+
+func Test_downloadKustomize(t *testing.T) {
+	req := require.New(t)
+
+	bareDir, commits, cleanup := createTestGitRepo(t)
+	defer cleanup()
+
+	upstream, err := downloadKustomize("kustomize://" + bareDir + "//manifests?ref=v1.0.0")
+	req.NoError(err)
+
+	assert.Equal(t, "kustomize", upstream.Type)
+	assert.Equal(t, commits[0].String(), upstream.UpdateCursor)
+	assert.Equal(t, "v1.0.0", upstream.VersionLabel)
+	assert.Equal(t, "manifests", upstream.KustomizationDir)
+
+	// the whole repo is fetched, so the kustomization can refer to files outside of its dir
+	paths := []string{}
+	for _, file := range upstream.Files {
+		paths = append(paths, file.Path)
+	}
+	assert.ElementsMatch(t, []string{"README.md", "manifests/deployment.yaml"}, paths)
+}
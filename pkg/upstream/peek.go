@@ -1,15 +1,24 @@
 package upstream
 
 import (
-	"net/url"
-
 	"github.com/pkg/errors"
 	"github.com/replicatedhq/kots/pkg/util"
 )
 
 type Update struct {
+	// Component is the name of the upstream in a composition that the update is for
+	Component    string `json:"component,omitempty"`
 	Cursor       string `json:"cursor"`
 	VersionLabel string `json:"versionLabel"`
+	AppVersion   string `json:"appVersion,omitempty"`
+	Description  string `json:"description,omitempty"`
+	ReleaseNotes string `json:"releaseNotes,omitempty"`
+	CreatedAt    string `json:"createdAt,omitempty"`
+	ChannelName  string `json:"channelName,omitempty"`
+	// IsRequired is set for releases that can't be skipped when upgrading past them
+	IsRequired bool `json:"isRequired,omitempty"`
+	// IsBlocked is set for releases that can't be installed until an earlier required release is
+	IsBlocked bool `json:"isBlocked,omitempty"`
 }
 
 func GetUpdatesUpstream(upstreamURI string, fetchOptions *FetchOptions) ([]Update, error) {
@@ -22,28 +31,21 @@ func GetUpdatesUpstream(upstreamURI string, fetchOptions *FetchOptions) ([]Updat
 }
 
 func getUpdatesUpstream(upstreamURI string, fetchOptions *FetchOptions) ([]Update, error) {
+	if util.IsLocalPath(upstreamURI) {
+		localPath, err := util.LocalPath(upstreamURI)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to get local path")
+		}
+		return getUpdatesLocal(localPath, fetchOptions.CurrentCursor)
+	}
 	if !util.IsURL(upstreamURI) {
-		return nil, errors.New("not implemented")
+		return nil, errors.Errorf("upstream %q is not a uri, local paths must start with ./, ../, / or ~", upstreamURI)
 	}
 
-	u, err := url.ParseRequestURI(upstreamURI)
+	fetcher, err := fetcherForURI(upstreamURI)
 	if err != nil {
-		return nil, errors.Wrap(err, "parse request uri failed")
-	}
-	if u.Scheme == "helm" {
-		return getUpdatesHelm(u, fetchOptions.HelmRepoURI)
-	}
-	if u.Scheme == "replicated" {
-		return getUpdatesReplicated(u, fetchOptions.LocalPath, fetchOptions.CurrentCursor, fetchOptions.CurrentVersionLabel, fetchOptions.License, fetchOptions.CurrentCursor)
-	}
-	if u.Scheme == "git" {
-		// return getUpdatesGit(upstreamURI)
-		// TODO
-	}
-	if u.Scheme == "http" || u.Scheme == "https" {
-		// return getUpdatesHttp(upstreamURI)
-		// TODO
+		return nil, err
 	}
 
-	return nil, errors.Errorf("unknown protocol scheme %q", u.Scheme)
+	return fetcher.GetUpdates(upstreamURI, fetchOptions)
 }
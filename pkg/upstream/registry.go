@@ -0,0 +1,161 @@
+package upstream
+
+import (
+	"net/url"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/replicatedhq/kots/pkg/crypto"
+	"github.com/replicatedhq/kots/pkg/upstream/types"
+)
+
+// Fetcher lists and downloads the releases of upstreams with a single uri scheme. The
+// upstream returned from Fetch has a Type, which selects the renderer in pkg/base.
+type Fetcher interface {
+	GetUpdates(upstreamURI string, fetchOptions *FetchOptions) ([]Update, error)
+	Fetch(upstreamURI string, fetchOptions *FetchOptions) (*types.Upstream, error)
+}
+
+type fetcherFuncs struct {
+	getUpdates func(u *url.URL, upstreamURI string, fetchOptions *FetchOptions) ([]Update, error)
+	fetch      func(u *url.URL, upstreamURI string, fetchOptions *FetchOptions) (*types.Upstream, error)
+}
+
+func (f fetcherFuncs) GetUpdates(upstreamURI string, fetchOptions *FetchOptions) ([]Update, error) {
+	u, err := url.ParseRequestURI(upstreamURI)
+	if err != nil {
+		return nil, errors.Wrap(err, "parse request uri failed")
+	}
+	return f.getUpdates(u, upstreamURI, fetchOptions)
+}
+
+func (f fetcherFuncs) Fetch(upstreamURI string, fetchOptions *FetchOptions) (*types.Upstream, error) {
+	u, err := url.ParseRequestURI(upstreamURI)
+	if err != nil {
+		return nil, errors.Wrap(err, "parse request uri failed")
+	}
+	return f.fetch(u, upstreamURI, fetchOptions)
+}
+
+var helmFetcher = fetcherFuncs{
+	getUpdates: func(u *url.URL, upstreamURI string, fetchOptions *FetchOptions) ([]Update, error) {
+		return getUpdatesHelm(u, fetchOptions.HelmRepoURI, fetchOptions.HelmRepoOptions, fetchOptions.CurrentCursor)
+	},
+	fetch: func(u *url.URL, upstreamURI string, fetchOptions *FetchOptions) (*types.Upstream, error) {
+		return downloadHelm(u, fetchOptions.HelmRepoURI, fetchOptions.HelmRepoOptions, fetchOptions.Cache)
+	},
+}
+
+var ociHelmFetcher = fetcherFuncs{
+	getUpdates: func(u *url.URL, upstreamURI string, fetchOptions *FetchOptions) ([]Update, error) {
+		return getUpdatesOCIHelm(u, fetchOptions.CurrentCursor)
+	},
+	fetch: func(u *url.URL, upstreamURI string, fetchOptions *FetchOptions) (*types.Upstream, error) {
+		return downloadOCIHelm(u)
+	},
+}
+
+var replicatedFetcher = fetcherFuncs{
+	getUpdates: func(u *url.URL, upstreamURI string, fetchOptions *FetchOptions) ([]Update, error) {
+		return getUpdatesReplicated(u, fetchOptions.LocalPath, fetchOptions.CurrentCursor, fetchOptions.CurrentVersionLabel, fetchOptions.License, fetchOptions.CurrentCursor, fetchOptions.HTTPClientOptions)
+	},
+	fetch: func(u *url.URL, upstreamURI string, fetchOptions *FetchOptions) (*types.Upstream, error) {
+		var cipher *crypto.AESCipher
+		if fetchOptions.EncryptionKey != "" {
+			c, err := crypto.AESCipherFromString(fetchOptions.EncryptionKey)
+			if err != nil {
+				return nil, errors.Wrap(err, "failed to create cipher")
+			}
+			cipher = c
+		}
+
+		return downloadReplicated(u, fetchOptions.LocalPath, fetchOptions.RootDir, fetchOptions.UseAppDir, fetchOptions.License, fetchOptions.ConfigValues, pickCursor(fetchOptions), pickVersionLabel(fetchOptions), cipher, fetchOptions.HTTPClientOptions, fetchOptions.Cache, pickReleaseVerifier(fetchOptions))
+	},
+}
+
+var gitFetcher = fetcherFuncs{
+	getUpdates: func(u *url.URL, upstreamURI string, fetchOptions *FetchOptions) ([]Update, error) {
+		return getUpdatesGit(upstreamURI, fetchOptions.CurrentCursor)
+	},
+	fetch: func(u *url.URL, upstreamURI string, fetchOptions *FetchOptions) (*types.Upstream, error) {
+		return downloadGit(upstreamURI)
+	},
+}
+
+var kustomizeFetcher = fetcherFuncs{
+	getUpdates: func(u *url.URL, upstreamURI string, fetchOptions *FetchOptions) ([]Update, error) {
+		return getUpdatesKustomize(upstreamURI, fetchOptions.CurrentCursor)
+	},
+	fetch: func(u *url.URL, upstreamURI string, fetchOptions *FetchOptions) (*types.Upstream, error) {
+		return downloadKustomize(upstreamURI)
+	},
+}
+
+var jsonnetFetcher = fetcherFuncs{
+	getUpdates: func(u *url.URL, upstreamURI string, fetchOptions *FetchOptions) ([]Update, error) {
+		return getUpdatesJsonnet(upstreamURI, fetchOptions.CurrentCursor)
+	},
+	fetch: func(u *url.URL, upstreamURI string, fetchOptions *FetchOptions) (*types.Upstream, error) {
+		return downloadJsonnet(upstreamURI)
+	},
+}
+
+var s3Fetcher = fetcherFuncs{
+	getUpdates: func(u *url.URL, upstreamURI string, fetchOptions *FetchOptions) ([]Update, error) {
+		return getUpdatesS3(upstreamURI, fetchOptions.CurrentCursor)
+	},
+	fetch: func(u *url.URL, upstreamURI string, fetchOptions *FetchOptions) (*types.Upstream, error) {
+		return downloadS3(upstreamURI, pickCursor(fetchOptions))
+	},
+}
+
+var httpFetcher = fetcherFuncs{
+	getUpdates: func(u *url.URL, upstreamURI string, fetchOptions *FetchOptions) ([]Update, error) {
+		return getUpdatesHttp(upstreamURI, fetchOptions.CurrentCursor)
+	},
+	fetch: func(u *url.URL, upstreamURI string, fetchOptions *FetchOptions) (*types.Upstream, error) {
+		return downloadHttp(upstreamURI)
+	},
+}
+
+var (
+	fetchersMu sync.RWMutex
+	fetchers   = map[string]Fetcher{
+		"helm":       helmFetcher,
+		"oci":        ociHelmFetcher,
+		"replicated": replicatedFetcher,
+		"git":        gitFetcher,
+		"kustomize":  kustomizeFetcher,
+		"jsonnet":    jsonnetFetcher,
+		"s3":         s3Fetcher,
+		"http":       httpFetcher,
+		"https":      httpFetcher,
+	}
+)
+
+// RegisterFetcher makes a fetcher available for upstream uris with the scheme. Registering
+// a fetcher for a scheme that already has one replaces it.
+func RegisterFetcher(scheme string, fetcher Fetcher) {
+	if fetcher == nil {
+		panic("upstream: RegisterFetcher fetcher is nil")
+	}
+
+	fetchersMu.Lock()
+	defer fetchersMu.Unlock()
+	fetchers[scheme] = fetcher
+}
+
+func fetcherForURI(upstreamURI string) (Fetcher, error) {
+	u, err := url.ParseRequestURI(upstreamURI)
+	if err != nil {
+		return nil, errors.Wrap(err, "parse request uri failed")
+	}
+
+	fetchersMu.RLock()
+	defer fetchersMu.RUnlock()
+	fetcher, ok := fetchers[u.Scheme]
+	if !ok {
+		return nil, errors.Errorf("unknown protocol scheme %q", u.Scheme)
+	}
+	return fetcher, nil
+}
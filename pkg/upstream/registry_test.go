@@ -0,0 +1,48 @@
+package upstream
+
+import (
+	"testing"
+
+	"github.com/replicatedhq/kots/pkg/upstream/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testFetcher struct{}
+
+func (testFetcher) GetUpdates(upstreamURI string, fetchOptions *FetchOptions) ([]Update, error) {
+	return []Update{{Cursor: "2", VersionLabel: "2.0.0"}}, nil
+}
+
+func (testFetcher) Fetch(upstreamURI string, fetchOptions *FetchOptions) (*types.Upstream, error) {
+	return &types.Upstream{
+		URI:          upstreamURI,
+		Name:         "myapp",
+		Type:         "custom",
+		UpdateCursor: fetchOptions.CurrentCursor,
+	}, nil
+}
+
+func Test_RegisterFetcher(t *testing.T) {
+	req := require.New(t)
+
+	_, err := FetchUpstream("custom://myapp", &FetchOptions{})
+	req.Error(err)
+
+	RegisterFetcher("custom", testFetcher{})
+	defer func() {
+		fetchersMu.Lock()
+		delete(fetchers, "custom")
+		fetchersMu.Unlock()
+	}()
+
+	u, err := FetchUpstream("custom://myapp", &FetchOptions{CurrentCursor: "1"})
+	req.NoError(err)
+	assert.Equal(t, "custom", u.Type)
+	assert.Equal(t, "custom://myapp", u.URI)
+	assert.Equal(t, "1", u.UpdateCursor)
+
+	updates, err := GetUpdatesUpstream("custom://myapp", &FetchOptions{})
+	req.NoError(err)
+	assert.Equal(t, []Update{{Cursor: "2", VersionLabel: "2.0.0"}}, updates)
+}
@@ -2,6 +2,7 @@ package upstream
 
 import (
 	"archive/tar"
+	"bufio"
 	"bytes"
 	"compress/gzip"
 	"encoding/base64"
@@ -21,6 +22,7 @@ import (
 	kotsv1beta1 "github.com/replicatedhq/kots/kotskinds/apis/kots/v1beta1"
 	"github.com/replicatedhq/kots/pkg/crypto"
 	"github.com/replicatedhq/kots/pkg/template"
+	"github.com/replicatedhq/kots/pkg/upstream/cache"
 	"github.com/replicatedhq/kots/pkg/upstream/types"
 	"github.com/replicatedhq/kots/pkg/util"
 	"github.com/replicatedhq/kots/pkg/version"
@@ -55,6 +57,8 @@ type Release struct {
 	VersionLabel string
 	ReleaseNotes string
 	Manifests    map[string][]byte
+	// StagedFiles are the files that are not held in memory, mapped to their path on disk
+	StagedFiles map[string]string
 }
 
 type ChannelRelease struct {
@@ -62,16 +66,19 @@ type ChannelRelease struct {
 	ReleaseSequence int    `json:"releaseSequence"`
 	VersionLabel    string `json:"versionLabel"`
 	CreatedAt       string `json:"createdAt"`
+	ReleaseNotes    string `json:"releaseNotes"`
+	IsRequired      bool   `json:"isRequired"`
+	ChannelName     string `json:"channelName"`
 }
 
-func getUpdatesReplicated(u *url.URL, localPath string, currentCursor, versionLabel string, license *kotsv1beta1.License, channelSequence string) ([]Update, error) {
+func getUpdatesReplicated(u *url.URL, localPath string, currentCursor, versionLabel string, license *kotsv1beta1.License, channelSequence string, httpClientOptions HTTPClientOptions) ([]Update, error) {
 	if localPath != "" {
 		parsedLocalRelease, err := readReplicatedAppFromLocalPath(localPath, currentCursor, versionLabel)
 		if err != nil {
 			return nil, errors.Wrap(err, "failed to read replicated app from local path")
 		}
 
-		return []Update{{Cursor: parsedLocalRelease.UpdateCursor, VersionLabel: versionLabel}}, nil
+		return []Update{{Cursor: parsedLocalRelease.UpdateCursor, VersionLabel: versionLabel, ReleaseNotes: parsedLocalRelease.ReleaseNotes}}, nil
 	}
 
 	// A license file is required to be set for this to succeed
@@ -84,27 +91,64 @@ func getUpdatesReplicated(u *url.URL, localPath string, currentCursor, versionLa
 		return nil, errors.Wrap(err, "failed to parse replicated upstream")
 	}
 
-	remoteLicense, err := getSuccessfulHeadResponse(replicatedUpstream, license)
+	httpClient, err := newHTTPClient(httpClientOptions)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create http client")
+	}
+
+	remoteLicense, err := getSuccessfulHeadResponse(httpClient, replicatedUpstream, license)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to get successful head response")
 	}
 
-	pendingReleases, err := listPendingChannelReleases(replicatedUpstream, remoteLicense, channelSequence)
+	pendingReleases, err := listPendingChannelReleases(httpClient, replicatedUpstream, remoteLicense, channelSequence)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to list replicated app releases")
 	}
 
+	return pendingReleasesToUpdates(pendingReleases, remoteLicense.Spec.ChannelName), nil
+}
+
+// pendingReleasesToUpdates marks the releases after the first required release as blocked,
+// since they can't be installed until it is
+func pendingReleasesToUpdates(pendingReleases []ChannelRelease, channelName string) []Update {
+	firstRequiredSequence := -1
+	for _, pendingRelease := range pendingReleases {
+		if pendingRelease.IsRequired && (firstRequiredSequence == -1 || pendingRelease.ChannelSequence < firstRequiredSequence) {
+			firstRequiredSequence = pendingRelease.ChannelSequence
+		}
+	}
+
 	updates := []Update{}
 	for _, pendingRelease := range pendingReleases {
-		updates = append(updates, Update{
+		update := Update{
 			Cursor:       strconv.Itoa(pendingRelease.ChannelSequence),
 			VersionLabel: pendingRelease.VersionLabel,
-		})
+			ReleaseNotes: pendingRelease.ReleaseNotes,
+			CreatedAt:    pendingRelease.CreatedAt,
+			ChannelName:  pendingRelease.ChannelName,
+			IsRequired:   pendingRelease.IsRequired,
+			IsBlocked:    firstRequiredSequence != -1 && pendingRelease.ChannelSequence > firstRequiredSequence,
+		}
+		if update.ChannelName == "" {
+			update.ChannelName = channelName
+		}
+		updates = append(updates, update)
 	}
-	return updates, nil
+	return updates
 }
 
-func downloadReplicated(u *url.URL, localPath string, rootDir string, useAppDir bool, license *kotsv1beta1.License, existingConfigValues *kotsv1beta1.ConfigValues, updateCursor, versionLabel string, cipher *crypto.AESCipher) (*types.Upstream, error) {
+func downloadReplicated(u *url.URL, localPath string, rootDir string, useAppDir bool, license *kotsv1beta1.License, existingConfigValues *kotsv1beta1.ConfigValues, updateCursor, versionLabel string, cipher *crypto.AESCipher, httpClientOptions HTTPClientOptions, upstreamCache *cache.Cache, verifyRelease releaseVerifier) (_ *types.Upstream, finalErr error) {
+	stagingDir, err := ioutil.TempDir("", "kots-upstream")
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create staging dir")
+	}
+	defer func() {
+		if finalErr != nil {
+			os.RemoveAll(stagingDir)
+		}
+	}()
+
 	var release *Release
 
 	if localPath != "" {
@@ -128,17 +172,42 @@ func downloadReplicated(u *url.URL, localPath string, rootDir string, useAppDir
 			return nil, errors.Wrap(err, "failed to parse replicated upstream")
 		}
 
-		remoteLicense, err := getSuccessfulHeadResponse(replicatedUpstream, license)
-		if err != nil {
-			return nil, errors.Wrap(err, "failed to get successful head response")
+		// a release that was fetched before can be rendered again without a connection
+		if upstreamCache != nil && updateCursor != "" {
+			cachedRelease, err := readCachedReplicatedRelease(upstreamCache, replicatedUpstream, license, updateCursor, stagingDir, verifyRelease)
+			if err != nil {
+				return nil, errors.Wrap(err, "failed to read release from cache")
+			}
+			release = cachedRelease
 		}
 
-		downloadedRelease, err := downloadReplicatedApp(replicatedUpstream, remoteLicense, updateCursor)
-		if err != nil {
-			return nil, errors.Wrap(err, "failed to download replicated app")
-		}
+		if release == nil {
+			httpClient, err := newHTTPClient(httpClientOptions)
+			if err != nil {
+				return nil, errors.Wrap(err, "failed to create http client")
+			}
 
-		release = downloadedRelease
+			remoteLicense, err := getSuccessfulHeadResponse(httpClient, replicatedUpstream, license)
+			if err != nil {
+				return nil, errors.Wrap(err, "failed to get successful head response")
+			}
+
+			// an explicit cursor is the release that's being updated to, and takes precedence over the pinned version
+			if updateCursor == "" && replicatedUpstream.VersionLabel != nil {
+				channelSequence, err := resolveVersionLabel(httpClient, replicatedUpstream, remoteLicense, *replicatedUpstream.VersionLabel)
+				if err != nil {
+					return nil, errors.Wrap(err, "failed to resolve version label")
+				}
+				updateCursor = channelSequence
+			}
+
+			downloadedRelease, err := downloadReplicatedApp(httpClient, replicatedUpstream, remoteLicense, updateCursor, stagingDir, upstreamCache, verifyRelease)
+			if err != nil {
+				return nil, errors.Wrap(err, "failed to download replicated app")
+			}
+
+			release = downloadedRelease
+		}
 	}
 
 	// Find the config in the upstream and write out default values
@@ -157,7 +226,6 @@ func downloadReplicated(u *url.URL, localPath string, rootDir string, useAppDir
 		} else {
 			prevConfigFile = filepath.Join(rootDir, "upstream", "userdata", "config.yaml")
 		}
-		var err error
 		existingConfigValues, err = findConfigValuesInFile(prevConfigFile)
 		if err != nil {
 			return nil, errors.Wrap(err, "failed to load existing config values")
@@ -189,6 +257,13 @@ func downloadReplicated(u *url.URL, localPath string, rootDir string, useAppDir
 		return nil, errors.Wrap(err, "failed to get files from release")
 	}
 
+	// these are added after the common prefix was removed from the release files
+	ociChartFiles, err := pullOCIHelmChartsInRelease(release, stagingDir)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to pull oci helm charts")
+	}
+	files = append(files, ociChartFiles...)
+
 	upstream := &types.Upstream{
 		URI:           u.RequestURI(),
 		Name:          application.Name,
@@ -198,6 +273,7 @@ func downloadReplicated(u *url.URL, localPath string, rootDir string, useAppDir
 		VersionLabel:  release.VersionLabel,
 		ReleaseNotes:  release.ReleaseNotes,
 		EncryptionKey: cipher.ToString(),
+		StagingDir:    stagingDir,
 	}
 
 	return upstream, nil
@@ -253,12 +329,12 @@ func parseReplicatedURL(u *url.URL) (*ReplicatedUpstream, error) {
 	return &replicatedUpstream, nil
 }
 
-func getSuccessfulHeadResponse(replicatedUpstream *ReplicatedUpstream, license *kotsv1beta1.License) (*kotsv1beta1.License, error) {
+func getSuccessfulHeadResponse(httpClient *http.Client, replicatedUpstream *ReplicatedUpstream, license *kotsv1beta1.License) (*kotsv1beta1.License, error) {
 	headReq, err := replicatedUpstream.getRequest("HEAD", license, "")
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to create http request")
 	}
-	headResp, err := http.DefaultClient.Do(headReq)
+	headResp, err := httpClient.Do(headReq)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to execute head request")
 	}
@@ -278,6 +354,7 @@ func getSuccessfulHeadResponse(replicatedUpstream *ReplicatedUpstream, license *
 func readReplicatedAppFromLocalPath(localPath, localCursor, versionLabel string) (*Release, error) {
 	release := Release{
 		Manifests:    make(map[string][]byte),
+		StagedFiles:  make(map[string]string),
 		UpdateCursor: localCursor,
 		VersionLabel: versionLabel,
 	}
@@ -292,14 +369,27 @@ func readReplicatedAppFromLocalPath(localPath, localCursor, versionLabel string)
 				return nil
 			}
 
-			contents, err := ioutil.ReadFile(path)
+			// remove localpath prefix
+			appPath := strings.TrimPrefix(path, localPath)
+			appPath = strings.TrimLeft(appPath, string(os.PathSeparator))
+
+			f, err := os.Open(path)
 			if err != nil {
 				return err
 			}
+			defer f.Close()
 
-			// remove localpath prefix
-			appPath := strings.TrimPrefix(path, localPath)
-			appPath = strings.TrimLeft(appPath, string(os.PathSeparator))
+			// files that would be staged are read from the local path when they're needed
+			r := bufio.NewReader(f)
+			if shouldStageFile(r, info.Size()) {
+				release.StagedFiles[appPath] = path
+				return nil
+			}
+
+			contents, err := ioutil.ReadAll(r)
+			if err != nil {
+				return err
+			}
 
 			release.Manifests[appPath] = contents
 
@@ -312,12 +402,14 @@ func readReplicatedAppFromLocalPath(localPath, localCursor, versionLabel string)
 	return &release, nil
 }
 
-func downloadReplicatedApp(replicatedUpstream *ReplicatedUpstream, license *kotsv1beta1.License, channelSequence string) (*Release, error) {
+// downloadReplicatedApp streams the release archive to disk, and verifies its signature before it's
+// read or added to the cache. The signature is not checked when verifyRelease is nil.
+func downloadReplicatedApp(httpClient *http.Client, replicatedUpstream *ReplicatedUpstream, license *kotsv1beta1.License, channelSequence string, stagingDir string, upstreamCache *cache.Cache, verifyRelease releaseVerifier) (*Release, error) {
 	getReq, err := replicatedUpstream.getRequest("GET", license, channelSequence)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to create http request")
 	}
-	getResp, err := http.DefaultClient.Do(getReq)
+	getResp, err := httpClient.Do(getReq)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to execute get request")
 	}
@@ -330,16 +422,126 @@ func downloadReplicatedApp(replicatedUpstream *ReplicatedUpstream, license *kots
 	updateCursor := getResp.Header.Get("X-Replicated-ChannelSequence")
 	versionLabel := getResp.Header.Get("X-Replicated-VersionLabel")
 
-	gzf, err := gzip.NewReader(getResp.Body)
+	// the signature is cached with the release so that it can be verified later, but a malformed
+	// signature only fails the download when it's verified
+	signature, decodeErr := base64.StdEncoding.DecodeString(getResp.Header.Get("X-Replicated-Signature"))
+	if decodeErr != nil {
+		signature = nil
+	}
+
+	archivePath, digest, err := stageArchive(stagingDir, getResp.Body)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to download release archive")
+	}
+	defer os.Remove(archivePath)
+
+	if verifyRelease != nil {
+		if decodeErr != nil {
+			return nil, errors.Wrap(crypto.ErrSignatureInvalid, "failed to decode release signature")
+		}
+		if err := verifyRelease(digest, signature); err != nil {
+			return nil, errors.Wrap(err, "failed to verify release signature")
+		}
+	}
+
+	archive, err := os.Open(archivePath)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to open release archive")
+	}
+	defer archive.Close()
+
+	if upstreamCache != nil && updateCursor != "" {
+		entry := cache.Entry{
+			Key:          replicatedCacheKey(replicatedUpstream, license, updateCursor),
+			URI:          replicatedCacheURI(replicatedUpstream),
+			Cursor:       updateCursor,
+			VersionLabel: versionLabel,
+			Signature:    signature,
+		}
+		if _, _, err := upstreamCache.Put(entry, archive); err != nil {
+			return nil, errors.Wrap(err, "failed to cache release")
+		}
+		if _, err := archive.Seek(0, io.SeekStart); err != nil {
+			return nil, errors.Wrap(err, "failed to rewind release archive")
+		}
+	}
+
+	release, err := readReplicatedReleaseArchive(archive, stagingDir)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read release archive")
+	}
+	release.UpdateCursor = updateCursor
+	release.VersionLabel = versionLabel
+	// NOTE: release notes come from Application spec
+
+	return release, nil
+}
+
+// readCachedReplicatedRelease returns the cached release for the cursor, or nil when it's not in the
+// cache. An archive that doesn't match its digest, or that was cached without a signature while
+// signatures are verified, is treated as missing, so it's downloaded again.
+func readCachedReplicatedRelease(upstreamCache *cache.Cache, replicatedUpstream *ReplicatedUpstream, license *kotsv1beta1.License, updateCursor string, stagingDir string, verifyRelease releaseVerifier) (*Release, error) {
+	entry, archivePath, err := upstreamCache.Get(replicatedCacheKey(replicatedUpstream, license, updateCursor))
+	if err == cache.ErrDigestMismatch {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get cache entry")
+	}
+	if entry == nil {
+		return nil, nil
+	}
+
+	if verifyRelease != nil {
+		if len(entry.Signature) == 0 {
+			return nil, nil
+		}
+		if err := verifyRelease(entry.Digest, entry.Signature); err != nil {
+			return nil, errors.Wrap(err, "failed to verify cached release signature")
+		}
+	}
+
+	f, err := os.Open(archivePath)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to open cached release")
+	}
+	defer f.Close()
+
+	release, err := readReplicatedReleaseArchive(f, stagingDir)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read cached release")
+	}
+	release.UpdateCursor = entry.Cursor
+	release.VersionLabel = entry.VersionLabel
+
+	return release, nil
+}
+
+// replicatedCacheKey includes the license, because the same channel sequence
+// refers to different releases on different channels
+func replicatedCacheKey(replicatedUpstream *ReplicatedUpstream, license *kotsv1beta1.License, updateCursor string) string {
+	return cache.Key("replicated", license.Spec.Endpoint, replicatedCacheURI(replicatedUpstream), license.Spec.LicenseID, updateCursor)
+}
+
+func replicatedCacheURI(replicatedUpstream *ReplicatedUpstream) string {
+	uri := fmt.Sprintf("replicated://%s", replicatedUpstream.AppSlug)
+	if replicatedUpstream.Channel != nil {
+		uri = fmt.Sprintf("%s/%s", uri, *replicatedUpstream.Channel)
+	}
+	return uri
+}
+
+// readReplicatedReleaseArchive reads a release tar.gz. Manifests are held in memory, archives and
+// large files are written to stagingDir as they are read.
+func readReplicatedReleaseArchive(archive io.Reader, stagingDir string) (*Release, error) {
+	gzf, err := gzip.NewReader(archive)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to create new gzip reader")
 	}
 
 	release := Release{
-		Manifests:    make(map[string][]byte),
-		UpdateCursor: updateCursor,
-		VersionLabel: versionLabel,
-		// NOTE: release notes come from Application spec
+		Manifests:   make(map[string][]byte),
+		StagedFiles: make(map[string]string),
 	}
 	tarReader := tar.NewReader(gzf)
 	i := 0
@@ -359,7 +561,18 @@ func downloadReplicatedApp(replicatedUpstream *ReplicatedUpstream, license *kots
 		case tar.TypeDir:
 			continue
 		case tar.TypeReg:
-			content, err := ioutil.ReadAll(tarReader)
+			r := bufio.NewReader(tarReader)
+			if shouldStageFile(r, header.Size) {
+				stagedPath, err := stageFile(stagingDir, name, r)
+				if err != nil {
+					return nil, errors.Wrapf(err, "failed to stage %s", name)
+				}
+
+				release.StagedFiles[name] = stagedPath
+				continue
+			}
+
+			content, err := ioutil.ReadAll(r)
 			if err != nil {
 				return nil, errors.Wrap(err, "failed to read file from tar")
 			}
@@ -373,7 +586,7 @@ func downloadReplicatedApp(replicatedUpstream *ReplicatedUpstream, license *kots
 	return &release, nil
 }
 
-func listPendingChannelReleases(replicatedUpstream *ReplicatedUpstream, license *kotsv1beta1.License, channelSequence string) ([]ChannelRelease, error) {
+func listPendingChannelReleases(httpClient *http.Client, replicatedUpstream *ReplicatedUpstream, license *kotsv1beta1.License, channelSequence string) ([]ChannelRelease, error) {
 	u, err := url.Parse(license.Spec.Endpoint)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to parse endpoint from license")
@@ -394,7 +607,7 @@ func listPendingChannelReleases(replicatedUpstream *ReplicatedUpstream, license
 	req.Header.Add("User-Agent", fmt.Sprintf("KOTS/%s", version.Version()))
 	req.Header.Set("Authorization", fmt.Sprintf("Basic %s", base64.StdEncoding.EncodeToString([]byte(fmt.Sprintf("%s:%s", license.Spec.LicenseID, license.Spec.LicenseID)))))
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := httpClient.Do(req)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to execute get request")
 	}
@@ -419,6 +632,32 @@ func listPendingChannelReleases(replicatedUpstream *ReplicatedUpstream, license
 	return channelReleases.ChannelReleases, nil
 }
 
+// resolveVersionLabel returns the channel sequence of the release with the version label on the licensed channel.
+// If the label was used by more than one release, the latest of them is used.
+func resolveVersionLabel(httpClient *http.Client, replicatedUpstream *ReplicatedUpstream, license *kotsv1beta1.License, versionLabel string) (string, error) {
+	channelReleases, err := listPendingChannelReleases(httpClient, replicatedUpstream, license, "")
+	if err != nil {
+		return "", errors.Wrap(err, "failed to list replicated app releases")
+	}
+
+	channelSequence := -1
+	for _, channelRelease := range channelReleases {
+		if channelRelease.VersionLabel == versionLabel && channelRelease.ChannelSequence > channelSequence {
+			channelSequence = channelRelease.ChannelSequence
+		}
+	}
+
+	if channelSequence == -1 {
+		channelName := license.Spec.ChannelName
+		if channelName == "" {
+			channelName = "licensed"
+		}
+		return "", errors.Errorf("version %s was not found on the %s channel", versionLabel, channelName)
+	}
+
+	return strconv.Itoa(channelSequence), nil
+}
+
 func MustMarshalLicense(license *kotsv1beta1.License) []byte {
 	s := serializer.NewYAMLSerializer(serializer.DefaultMetaFactory, scheme.Scheme, scheme.Scheme)
 
@@ -476,6 +715,7 @@ func createConfigValues(applicationName string, config *kotsv1beta1.Config, exis
 
 	builder := template.Builder{}
 	builder.AddCtx(template.StaticCtx{})
+	builder.AddCtx(template.ClusterCtx{})
 
 	configCtx, err := builder.NewConfigContext(config.Spec.Groups, templateContextValues, cipher)
 	if err != nil {
@@ -631,6 +871,14 @@ func releaseToFiles(release *Release) ([]types.UpstreamFile, error) {
 
 		upstreamFiles = append(upstreamFiles, upstreamFile)
 	}
+	for filename, localPath := range release.StagedFiles {
+		upstreamFile := types.UpstreamFile{
+			Path:      filename,
+			LocalPath: localPath,
+		}
+
+		upstreamFiles = append(upstreamFiles, upstreamFile)
+	}
 
 	// Stash the user data for this search (we will readd at the end)
 	userdataFiles := []types.UpstreamFile{}
@@ -682,14 +930,19 @@ func releaseToFiles(release *Release) ([]types.UpstreamFile, error) {
 // GetApplicationMetadata will return any available application yaml from
 // the upstream. If there is no application.yaml, it will return
 // a placeholder one
-func GetApplicationMetadata(upstream *url.URL) ([]byte, error) {
-	metadata, err := getApplicationMetadataFromHost("replicated.app", upstream)
+func GetApplicationMetadata(upstream *url.URL, httpClientOptions HTTPClientOptions) ([]byte, error) {
+	httpClient, err := newHTTPClient(httpClientOptions)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create http client")
+	}
+
+	metadata, err := getApplicationMetadataFromHost(httpClient, "replicated.app", upstream)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to get metadata from replicated.app")
 	}
 
 	if metadata == nil {
-		otherMetadata, err := getApplicationMetadataFromHost("staging.replicated.app", upstream)
+		otherMetadata, err := getApplicationMetadataFromHost(httpClient, "staging.replicated.app", upstream)
 		if err != nil {
 			return nil, errors.Wrap(err, "failed to get metadata from staging.replicated.app")
 		}
@@ -704,7 +957,7 @@ func GetApplicationMetadata(upstream *url.URL) ([]byte, error) {
 	return metadata, nil
 }
 
-func getApplicationMetadataFromHost(host string, upstream *url.URL) ([]byte, error) {
+func getApplicationMetadataFromHost(httpClient *http.Client, host string, upstream *url.URL) ([]byte, error) {
 	r, err := parseReplicatedURL(upstream)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to parse replicated upstream")
@@ -723,7 +976,7 @@ func getApplicationMetadataFromHost(host string, upstream *url.URL) ([]byte, err
 
 	getReq.Header.Add("User-Agent", fmt.Sprintf("KOTS/%s", version.Version()))
 
-	getResp, err := http.DefaultClient.Do(getReq)
+	getResp, err := httpClient.Do(getReq)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to execute get request")
 	}
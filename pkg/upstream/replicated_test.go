@@ -1,11 +1,31 @@
 package upstream
 
 import (
+	"crypto"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
 	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
+	"github.com/pkg/errors"
 	kotsv1beta1 "github.com/replicatedhq/kots/kotskinds/apis/kots/v1beta1"
 	"github.com/replicatedhq/kots/kotskinds/multitype"
+	kotscrypto "github.com/replicatedhq/kots/pkg/crypto"
+	"github.com/replicatedhq/kots/pkg/upstream/cache"
 	"github.com/replicatedhq/kots/pkg/upstream/types"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -330,3 +350,402 @@ func Test_getRequest(t *testing.T) {
 		assert.Equal(t, test.expectedURL, request.URL.String())
 	}
 }
+
+// newTestReplicatedAppServer serves the pending releases of the licensed channel, and the release
+// archive for each channel sequence
+func newTestReplicatedAppServer(t *testing.T, channelReleases []ChannelRelease) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/release/app/pending":
+			json.NewEncoder(w).Encode(map[string]interface{}{"channelReleases": channelReleases})
+
+		case "/release/app":
+			channelSequence := r.URL.Query().Get("channelSequence")
+			if channelSequence == "" {
+				channelSequence = fmt.Sprint(channelReleases[len(channelReleases)-1].ChannelSequence)
+			}
+			for _, channelRelease := range channelReleases {
+				if fmt.Sprint(channelRelease.ChannelSequence) != channelSequence {
+					continue
+				}
+
+				w.Header().Set("X-Replicated-ChannelSequence", channelSequence)
+				w.Header().Set("X-Replicated-VersionLabel", channelRelease.VersionLabel)
+				if r.Method == "HEAD" {
+					return
+				}
+				w.Write(createTestTarGz(t, map[string]string{
+					"manifests/release.yaml": fmt.Sprintf("version: %s\n", channelRelease.VersionLabel),
+				}))
+				return
+			}
+			w.WriteHeader(http.StatusNotFound)
+
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+}
+
+func Test_downloadReplicatedPinnedVersion(t *testing.T) {
+	server := newTestReplicatedAppServer(t, []ChannelRelease{
+		{ChannelSequence: 1, VersionLabel: "1.4.1"},
+		{ChannelSequence: 2, VersionLabel: "1.4.2"},
+		{ChannelSequence: 3, VersionLabel: "1.4.2"},
+		{ChannelSequence: 4, VersionLabel: "1.5.0"},
+	})
+	defer server.Close()
+
+	license := &kotsv1beta1.License{
+		Spec: kotsv1beta1.LicenseSpec{
+			Endpoint:    server.URL,
+			AppSlug:     "app",
+			LicenseID:   "license-id",
+			ChannelName: "Stable",
+		},
+	}
+
+	tests := []struct {
+		name                 string
+		uri                  string
+		updateCursor         string
+		expectedCursor       string
+		expectedVersionLabel string
+		expectedErr          string
+	}{
+		{
+			name:                 "channel head",
+			uri:                  "replicated://app",
+			expectedCursor:       "4",
+			expectedVersionLabel: "1.5.0",
+		},
+		{
+			name:                 "pinned version",
+			uri:                  "replicated://app@1.4.1",
+			expectedCursor:       "1",
+			expectedVersionLabel: "1.4.1",
+		},
+		{
+			name:                 "pinned version that was released twice",
+			uri:                  "replicated://app@1.4.2",
+			expectedCursor:       "3",
+			expectedVersionLabel: "1.4.2",
+		},
+		{
+			name:                 "update cursor takes precedence",
+			uri:                  "replicated://app@1.4.1",
+			updateCursor:         "4",
+			expectedCursor:       "4",
+			expectedVersionLabel: "1.5.0",
+		},
+		{
+			name:        "missing version",
+			uri:         "replicated://app@2.0.0",
+			expectedErr: "version 2.0.0 was not found on the Stable channel",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := require.New(t)
+
+			rootDir, err := ioutil.TempDir("", "kots")
+			req.NoError(err)
+			defer os.RemoveAll(rootDir)
+
+			u, err := url.Parse(test.uri)
+			req.NoError(err)
+
+			upstream, err := downloadReplicated(u, "", rootDir, false, license, nil, test.updateCursor, "", nil, HTTPClientOptions{}, nil, nil)
+			if test.expectedErr != "" {
+				req.Error(err)
+				assert.Contains(t, err.Error(), test.expectedErr)
+				return
+			}
+			req.NoError(err)
+
+			assert.Equal(t, test.expectedCursor, upstream.UpdateCursor)
+			assert.Equal(t, test.expectedVersionLabel, upstream.VersionLabel)
+		})
+	}
+}
+
+func Test_downloadReplicatedStagesArchives(t *testing.T) {
+	req := require.New(t)
+
+	defer func(size int64) { maxInMemoryFileSize = size }(maxInMemoryFileSize)
+	maxInMemoryFileSize = 64
+
+	chartArchive := string(createTestTarGz(t, map[string]string{
+		"mychart/Chart.yaml": "apiVersion: v1\nname: mychart\nversion: 1.0.0\n",
+	}))
+	largeManifest := "kind: ConfigMap\ndata:\n  value: " + strings.Repeat("x", 128) + "\n"
+	releaseArchive := createTestTarGz(t, map[string]string{
+		"manifests/deployment.yaml":   "kind: Deployment\n",
+		"manifests/mychart-1.0.0.tgz": chartArchive,
+		"manifests/large.yaml":        largeManifest,
+	})
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Replicated-ChannelSequence", "1")
+		w.Write(releaseArchive)
+	}))
+	defer server.Close()
+
+	license := &kotsv1beta1.License{
+		Spec: kotsv1beta1.LicenseSpec{
+			Endpoint:  server.URL,
+			AppSlug:   "app",
+			LicenseID: "license-id",
+		},
+	}
+
+	rootDir, err := ioutil.TempDir("", "kots")
+	req.NoError(err)
+	defer os.RemoveAll(rootDir)
+
+	u, err := url.Parse("replicated://app")
+	req.NoError(err)
+
+	upstream, err := downloadReplicated(u, "", rootDir, false, license, nil, "", "", nil, HTTPClientOptions{}, nil, nil)
+	req.NoError(err)
+	req.NotEmpty(upstream.StagingDir)
+
+	staged := map[string]bool{}
+	for _, file := range upstream.Files {
+		if file.LocalPath != "" {
+			assert.Empty(t, file.Content)
+			assert.Equal(t, upstream.StagingDir, filepath.Dir(file.LocalPath))
+			staged[file.Path] = true
+		}
+	}
+	assert.Equal(t, map[string]bool{"mychart-1.0.0.tgz": true, "large.yaml": true}, staged)
+
+	req.NoError(WriteUpstream(upstream, types.WriteOptions{RootDir: rootDir}))
+
+	expected := map[string]string{
+		"deployment.yaml":   "kind: Deployment\n",
+		"mychart-1.0.0.tgz": chartArchive,
+		"large.yaml":        largeManifest,
+	}
+	for filename, content := range expected {
+		actual, err := ioutil.ReadFile(filepath.Join(rootDir, "upstream", filename))
+		req.NoError(err)
+		assert.Equal(t, content, string(actual), filename)
+	}
+
+	req.NoError(upstream.Cleanup())
+	_, err = os.Stat(upstream.StagingDir)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func Test_downloadReplicatedFromCache(t *testing.T) {
+	req := require.New(t)
+
+	server := newTestReplicatedAppServer(t, []ChannelRelease{
+		{ChannelSequence: 1, VersionLabel: "1.0.0"},
+		{ChannelSequence: 2, VersionLabel: "1.1.0"},
+	})
+
+	license := &kotsv1beta1.License{
+		Spec: kotsv1beta1.LicenseSpec{
+			Endpoint:  server.URL,
+			AppSlug:   "app",
+			LicenseID: "license-id",
+		},
+	}
+
+	rootDir, err := ioutil.TempDir("", "kots")
+	req.NoError(err)
+	defer os.RemoveAll(rootDir)
+
+	cacheDir, err := ioutil.TempDir("", "kots-cache")
+	req.NoError(err)
+	defer os.RemoveAll(cacheDir)
+	upstreamCache := cache.New(cacheDir, 0)
+
+	u, err := url.Parse("replicated://app")
+	req.NoError(err)
+
+	downloaded, err := downloadReplicated(u, "", rootDir, false, license, nil, "", "", nil, HTTPClientOptions{}, upstreamCache, nil)
+	req.NoError(err)
+	defer downloaded.Cleanup()
+	assert.Equal(t, "2", downloaded.UpdateCursor)
+
+	// the release for the cursor is rendered again without a connection
+	server.Close()
+
+	cached, err := downloadReplicated(u, "", rootDir, false, license, nil, "2", "", nil, HTTPClientOptions{}, upstreamCache, nil)
+	req.NoError(err)
+	defer cached.Cleanup()
+	assert.Equal(t, "2", cached.UpdateCursor)
+	assert.Equal(t, "1.1.0", cached.VersionLabel)
+	assert.ElementsMatch(t, downloaded.Files, cached.Files)
+
+	// a cursor that isn't cached still needs the server
+	_, err = downloadReplicated(u, "", rootDir, false, license, nil, "1", "", nil, HTTPClientOptions{MaxRetries: -1}, upstreamCache, nil)
+	req.Error(err)
+}
+
+func Test_downloadReplicatedVerifiesSignature(t *testing.T) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	publicKeyDER, err := x509.MarshalPKIXPublicKey(&privateKey.PublicKey)
+	require.NoError(t, err)
+	appPublicKey := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicKeyDER})
+
+	releaseArchive := createTestTarGz(t, map[string]string{
+		"manifests/release.yaml": "version: 1.0.0\n",
+	})
+	sign := func(archive []byte) string {
+		digest := sha256.Sum256(archive)
+		hashed := md5.Sum([]byte(hex.EncodeToString(digest[:])))
+		signature, err := rsa.SignPSS(rand.Reader, privateKey, crypto.MD5, hashed[:], &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthAuto})
+		require.NoError(t, err)
+		return base64.StdEncoding.EncodeToString(signature)
+	}
+
+	tests := []struct {
+		name            string
+		signature       string
+		appPublicKey    []byte
+		appPublicKeyErr error
+		skip            bool
+		expectedCause   error
+		expectedErr     string
+	}{
+		{
+			name:         "valid signature",
+			signature:    sign(releaseArchive),
+			appPublicKey: appPublicKey,
+		},
+		{
+			name:          "tampered release",
+			signature:     sign(append([]byte("tampered"), releaseArchive...)),
+			appPublicKey:  appPublicKey,
+			expectedCause: kotscrypto.ErrSignatureInvalid,
+		},
+		{
+			name:          "unsigned release",
+			appPublicKey:  appPublicKey,
+			expectedCause: kotscrypto.ErrSignatureMissing,
+		},
+		{
+			name:        "license without a key",
+			signature:   sign(releaseArchive),
+			expectedErr: "license does not include an app public key",
+		},
+		{
+			name:            "license with a key that can't be read",
+			signature:       sign(releaseArchive),
+			appPublicKeyErr: errors.New("failed to unmarshal license outer signature"),
+			expectedErr:     "failed to get the app public key from the license to verify the release signature: failed to unmarshal license outer signature",
+		},
+		{
+			name:            "skipped with a key that can't be read",
+			signature:       sign(releaseArchive),
+			appPublicKeyErr: errors.New("failed to unmarshal license outer signature"),
+			skip:            true,
+		},
+		{
+			name:          "malformed signature",
+			signature:     "not base64!",
+			appPublicKey:  appPublicKey,
+			expectedCause: kotscrypto.ErrSignatureInvalid,
+		},
+		{
+			name:      "skipped",
+			signature: sign(append([]byte("tampered"), releaseArchive...)),
+			skip:      true,
+		},
+		{
+			name:      "skipped with a malformed signature",
+			signature: "not base64!",
+			skip:      true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := require.New(t)
+
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("X-Replicated-ChannelSequence", "1")
+				if test.signature != "" {
+					w.Header().Set("X-Replicated-Signature", test.signature)
+				}
+				w.Write(releaseArchive)
+			}))
+			defer server.Close()
+
+			license := &kotsv1beta1.License{
+				Spec: kotsv1beta1.LicenseSpec{
+					Endpoint:  server.URL,
+					AppSlug:   "app",
+					LicenseID: "license-id",
+				},
+			}
+
+			rootDir, err := ioutil.TempDir("", "kots")
+			req.NoError(err)
+			defer os.RemoveAll(rootDir)
+
+			fetchOptions := &FetchOptions{
+				RootDir:              rootDir,
+				License:              license,
+				AppPublicKey:         test.appPublicKey,
+				AppPublicKeyErr:      test.appPublicKeyErr,
+				SkipReleaseSignature: test.skip,
+				HTTPClientOptions:    HTTPClientOptions{MaxRetries: -1},
+			}
+			upstream, err := downloadUpstream("replicated://app", fetchOptions)
+			if test.expectedCause != nil {
+				req.Error(err)
+				assert.Equal(t, test.expectedCause, errors.Cause(err))
+				return
+			}
+			if test.expectedErr != "" {
+				req.Error(err)
+				assert.Contains(t, err.Error(), test.expectedErr)
+				return
+			}
+			req.NoError(err)
+			defer upstream.Cleanup()
+
+			assert.Equal(t, "1", upstream.UpdateCursor)
+		})
+	}
+}
+
+func Test_getUpdatesReplicated(t *testing.T) {
+	req := require.New(t)
+
+	server := newTestReplicatedAppServer(t, []ChannelRelease{
+		{ChannelSequence: 2, VersionLabel: "1.1.0", CreatedAt: "2020-03-01T00:00:00Z", ReleaseNotes: "fixes"},
+		{ChannelSequence: 3, VersionLabel: "1.2.0", CreatedAt: "2020-03-02T00:00:00Z", IsRequired: true},
+		{ChannelSequence: 4, VersionLabel: "1.3.0", CreatedAt: "2020-03-03T00:00:00Z", ChannelName: "Beta"},
+	})
+	defer server.Close()
+
+	license := &kotsv1beta1.License{
+		Spec: kotsv1beta1.LicenseSpec{
+			Endpoint:    server.URL,
+			AppSlug:     "app",
+			LicenseID:   "license-id",
+			ChannelName: "Stable",
+		},
+	}
+
+	u, err := url.Parse("replicated://app")
+	req.NoError(err)
+
+	updates, err := getUpdatesReplicated(u, "", "1", "", license, "1", HTTPClientOptions{})
+	req.NoError(err)
+
+	expected := []Update{
+		{Cursor: "2", VersionLabel: "1.1.0", CreatedAt: "2020-03-01T00:00:00Z", ReleaseNotes: "fixes", ChannelName: "Stable"},
+		{Cursor: "3", VersionLabel: "1.2.0", CreatedAt: "2020-03-02T00:00:00Z", ChannelName: "Stable", IsRequired: true},
+		{Cursor: "4", VersionLabel: "1.3.0", CreatedAt: "2020-03-03T00:00:00Z", ChannelName: "Beta", IsBlocked: true},
+	}
+	assert.Equal(t, expected, updates)
+}
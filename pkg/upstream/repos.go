@@ -1,9 +1,109 @@
 package upstream
 
+import (
+	"io/ioutil"
+
+	"github.com/ghodss/yaml"
+	"github.com/pkg/errors"
+)
+
+// KnownRepos are the repo aliases that can be used in helm:// uris when no
+// repositories config file is provided
 var KnownRepos = map[string]string{
-	"stable":  "https://kubernetes-charts.storage.googleapis.com",
+	"stable":  "https://charts.helm.sh/stable",
 	"local":   "http://127.0.0.1:8879",
 	"elastic": "https://helm.elastic.co",
 	"gomods":  "https://athens.blob.core.windows.net/charts",
 	"harbor":  "https://helm.goharbor.io",
 }
+
+// HelmRepo is a chart repository and the credentials and tls files needed to reach it
+type HelmRepo struct {
+	Name     string `json:"name"`
+	URL      string `json:"url"`
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+	CertFile string `json:"certFile,omitempty"`
+	KeyFile  string `json:"keyFile,omitempty"`
+	CAFile   string `json:"caFile,omitempty"`
+}
+
+// HelmRepoConfig is a list of repo aliases. The format is compatible with helm's
+// repositories.yaml, so an existing helm config can be used as is.
+type HelmRepoConfig struct {
+	Repositories []HelmRepo `json:"repositories"`
+}
+
+// HelmRepoOptions are the user provided settings for helm repos. The credentials and
+// tls files apply to the repo in the helm:// uri and override the ones in the config file.
+type HelmRepoOptions struct {
+	ConfigFile string
+	Username   string
+	Password   string
+	CertFile   string
+	KeyFile    string
+	CAFile     string
+}
+
+func LoadHelmRepoConfig(filename string) (*HelmRepoConfig, error) {
+	content, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read repositories config")
+	}
+
+	helmRepoConfig := HelmRepoConfig{}
+	if err := yaml.Unmarshal(content, &helmRepoConfig); err != nil {
+		return nil, errors.Wrap(err, "failed to unmarshal repositories config")
+	}
+
+	return &helmRepoConfig, nil
+}
+
+// resolveHelmRepo finds the repo for repoName. When a config file is provided, its aliases
+// replace KnownRepos. An explicit repoURI always takes precedence over the alias.
+func resolveHelmRepo(repoName string, repoURI string, repoOptions HelmRepoOptions) (*HelmRepo, error) {
+	helmRepo := HelmRepo{
+		Name: repoName,
+	}
+
+	if repoOptions.ConfigFile != "" {
+		helmRepoConfig, err := LoadHelmRepoConfig(repoOptions.ConfigFile)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to load %s", repoOptions.ConfigFile)
+		}
+
+		for _, r := range helmRepoConfig.Repositories {
+			if r.Name == repoName {
+				helmRepo = r
+				break
+			}
+		}
+	} else {
+		helmRepo.URL = getKnownHelmRepoURI(repoName)
+	}
+
+	if repoURI != "" {
+		helmRepo.URL = repoURI
+	}
+	if helmRepo.URL == "" {
+		return nil, errors.Errorf("unknown helm repo %q, try passing the repo uri", repoName)
+	}
+
+	if repoOptions.Username != "" {
+		helmRepo.Username = repoOptions.Username
+	}
+	if repoOptions.Password != "" {
+		helmRepo.Password = repoOptions.Password
+	}
+	if repoOptions.CertFile != "" {
+		helmRepo.CertFile = repoOptions.CertFile
+	}
+	if repoOptions.KeyFile != "" {
+		helmRepo.KeyFile = repoOptions.KeyFile
+	}
+	if repoOptions.CAFile != "" {
+		helmRepo.CAFile = repoOptions.CAFile
+	}
+
+	return &helmRepo, nil
+}
@@ -0,0 +1,106 @@
+package upstream
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_resolveHelmRepo(t *testing.T) {
+	req := require.New(t)
+
+	configFile, err := ioutil.TempFile("", "repositories")
+	req.NoError(err)
+	defer os.Remove(configFile.Name())
+
+	_, err = configFile.WriteString(`apiVersion: v1
+repositories:
+- name: private
+  url: https://charts.example.com
+  username: user
+  password: pass
+  caFile: /etc/ssl/private-ca.pem
+`)
+	req.NoError(err)
+	req.NoError(configFile.Close())
+
+	tests := []struct {
+		name        string
+		repoName    string
+		repoURI     string
+		repoOptions HelmRepoOptions
+		expected    *HelmRepo
+		expectErr   bool
+	}{
+		{
+			name:     "known repo",
+			repoName: "stable",
+			expected: &HelmRepo{
+				Name: "stable",
+				URL:  "https://charts.helm.sh/stable",
+			},
+		},
+		{
+			name:     "explicit uri with credentials",
+			repoName: "mine",
+			repoURI:  "https://mine.example.com",
+			repoOptions: HelmRepoOptions{
+				Username: "me",
+				Password: "secret",
+				CAFile:   "/tmp/ca.pem",
+			},
+			expected: &HelmRepo{
+				Name:     "mine",
+				URL:      "https://mine.example.com",
+				Username: "me",
+				Password: "secret",
+				CAFile:   "/tmp/ca.pem",
+			},
+		},
+		{
+			name:     "alias from config file",
+			repoName: "private",
+			repoOptions: HelmRepoOptions{
+				ConfigFile: configFile.Name(),
+				Password:   "override",
+			},
+			expected: &HelmRepo{
+				Name:     "private",
+				URL:      "https://charts.example.com",
+				Username: "user",
+				Password: "override",
+				CAFile:   "/etc/ssl/private-ca.pem",
+			},
+		},
+		{
+			name:     "config file replaces known repos",
+			repoName: "stable",
+			repoOptions: HelmRepoOptions{
+				ConfigFile: configFile.Name(),
+			},
+			expectErr: true,
+		},
+		{
+			name:      "unknown repo",
+			repoName:  "unknown",
+			expectErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := require.New(t)
+
+			actual, err := resolveHelmRepo(test.repoName, test.repoURI, test.repoOptions)
+			if test.expectErr {
+				req.Error(err)
+				return
+			}
+			req.NoError(err)
+			assert.Equal(t, test.expected, actual)
+		})
+	}
+}
@@ -0,0 +1,242 @@
+package upstream
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+	"github.com/pkg/errors"
+	"github.com/replicatedhq/kots/pkg/objectstore"
+	"github.com/replicatedhq/kots/pkg/upstream/types"
+)
+
+// newS3Client is replaced in tests with a fake
+var newS3Client = objectstore.NewClient
+
+// s3Release is a version of an s3:// upstream. An upstream with a pattern in the key,
+// such as s3://bucket/releases/app-*.tar.gz, has a release for each matching key.
+// Otherwise, each version of the object is a release.
+type s3Release struct {
+	Key          string
+	VersionID    string
+	Cursor       string
+	LastModified time.Time
+}
+
+func isS3Pattern(key string) bool {
+	return strings.ContainsAny(key, "*?[")
+}
+
+func getUpdatesS3(upstreamURI string, currentCursor string) ([]Update, error) {
+	location, err := objectstore.ParseURL(upstreamURI)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse s3 uri")
+	}
+
+	client, err := newS3Client()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create s3 client")
+	}
+
+	releases, err := listS3Releases(client, location)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list releases")
+	}
+	if len(releases) == 0 {
+		return []Update{}, nil
+	}
+
+	// without a known cursor, only the latest release is interesting
+	pending := releases[len(releases)-1:]
+	for i, release := range releases {
+		if release.Cursor == currentCursor {
+			pending = releases[i+1:]
+		}
+	}
+
+	updates := []Update{}
+	for _, release := range pending {
+		updates = append(updates, Update{
+			Cursor:       release.Cursor,
+			VersionLabel: archiveVersionLabel(release.Key),
+			CreatedAt:    release.LastModified.Format(time.RFC3339),
+		})
+	}
+	return updates, nil
+}
+
+// downloadS3 downloads the release with the cursor, the version id, etag or key that getUpdatesS3
+// lists it with, or the latest release when the cursor is empty
+func downloadS3(upstreamURI string, cursor string) (*types.Upstream, error) {
+	location, err := objectstore.ParseURL(upstreamURI)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse s3 uri")
+	}
+
+	client, err := newS3Client()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create s3 client")
+	}
+
+	releases, err := listS3Releases(client, location)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list releases")
+	}
+	if len(releases) == 0 {
+		return nil, errors.Errorf("no objects found at %s", upstreamURI)
+	}
+	release, err := findS3Release(releases, cursor)
+	if err != nil {
+		return nil, err
+	}
+
+	archiveFormat := archiveFormatFromPath(release.Key)
+	if archiveFormat == "" {
+		return nil, errors.Errorf("unsupported archive type in %s, expected .tar.gz, .tgz or .zip", release.Key)
+	}
+
+	archiveFile, err := ioutil.TempFile("", "kots")
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create temp file")
+	}
+	defer os.Remove(archiveFile.Name())
+	defer archiveFile.Close()
+
+	if err := objectstore.Download(client, location.Bucket, release.Key, release.VersionID, archiveFile); err != nil {
+		return nil, errors.Wrap(err, "failed to download archive")
+	}
+
+	var files []types.UpstreamFile
+	if archiveFormat == "zip" {
+		files, err = readZip(archiveFile.Name())
+	} else {
+		files, err = readTarGz(archiveFile.Name())
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read archive")
+	}
+
+	upstream := &types.Upstream{
+		URI:          upstreamURI,
+		Name:         archiveName(archiveVersionLabel(release.Key)),
+		Type:         "s3",
+		Files:        files,
+		UpdateCursor: release.Cursor,
+		VersionLabel: archiveVersionLabel(release.Key),
+	}
+
+	return upstream, nil
+}
+
+func findS3Release(releases []s3Release, cursor string) (s3Release, error) {
+	if cursor == "" {
+		return releases[len(releases)-1], nil
+	}
+
+	for _, release := range releases {
+		if release.Cursor == cursor {
+			return release, nil
+		}
+	}
+	return s3Release{}, errors.Errorf("no release with cursor %q", cursor)
+}
+
+// listS3Releases returns the releases of the upstream, the oldest first
+func listS3Releases(client s3iface.S3API, location *objectstore.Location) ([]s3Release, error) {
+	var releases []s3Release
+	var err error
+	if isS3Pattern(location.Key) {
+		releases, err = listS3MatchingKeys(client, location)
+	} else {
+		releases, err = listS3ObjectVersions(client, location)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(releases, func(i, j int) bool {
+		return releases[i].LastModified.Before(releases[j].LastModified)
+	})
+
+	return releases, nil
+}
+
+func listS3MatchingKeys(client s3iface.S3API, location *objectstore.Location) ([]s3Release, error) {
+	prefix := location.Key[:strings.IndexAny(location.Key, "*?[")]
+
+	releases := []s3Release{}
+	var matchErr error
+	input := &s3.ListObjectsV2Input{
+		Bucket: aws.String(location.Bucket),
+		Prefix: aws.String(prefix),
+	}
+	err := client.ListObjectsV2Pages(input, func(page *s3.ListObjectsV2Output, lastPage bool) bool {
+		for _, object := range page.Contents {
+			key := aws.StringValue(object.Key)
+			matched, err := path.Match(location.Key, key)
+			if err != nil {
+				matchErr = err
+				return false
+			}
+			if !matched {
+				continue
+			}
+
+			releases = append(releases, s3Release{
+				Key:          key,
+				Cursor:       key,
+				LastModified: aws.TimeValue(object.LastModified),
+			})
+		}
+		return true
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list objects")
+	}
+	if matchErr != nil {
+		return nil, errors.Wrap(matchErr, "failed to match key pattern")
+	}
+
+	return releases, nil
+}
+
+// listS3ObjectVersions uses the version id as the cursor, or the etag when versioning is
+// not enabled on the bucket
+func listS3ObjectVersions(client s3iface.S3API, location *objectstore.Location) ([]s3Release, error) {
+	releases := []s3Release{}
+	input := &s3.ListObjectVersionsInput{
+		Bucket: aws.String(location.Bucket),
+		Prefix: aws.String(location.Key),
+	}
+	err := client.ListObjectVersionsPages(input, func(page *s3.ListObjectVersionsOutput, lastPage bool) bool {
+		for _, version := range page.Versions {
+			if aws.StringValue(version.Key) != location.Key {
+				continue
+			}
+
+			release := s3Release{
+				Key:          location.Key,
+				VersionID:    aws.StringValue(version.VersionId),
+				Cursor:       aws.StringValue(version.VersionId),
+				LastModified: aws.TimeValue(version.LastModified),
+			}
+			if release.VersionID == "" || release.VersionID == "null" {
+				release.VersionID = ""
+				release.Cursor = aws.StringValue(version.ETag)
+			}
+			releases = append(releases, release)
+		}
+		return true
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list object versions")
+	}
+
+	return releases, nil
+}
@@ -0,0 +1,262 @@
+package upstream
+
+import (
+	"bytes"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeS3Object struct {
+	Key          string
+	VersionID    string
+	ETag         string
+	LastModified time.Time
+	Content      []byte
+}
+
+// fakeS3 is an in memory bucket, with only the calls used by s3 upstreams implemented
+type fakeS3 struct {
+	s3iface.S3API
+	objects []fakeS3Object
+}
+
+func (f *fakeS3) GetObject(input *s3.GetObjectInput) (*s3.GetObjectOutput, error) {
+	var found *fakeS3Object
+	for i, object := range f.objects {
+		if object.Key != aws.StringValue(input.Key) {
+			continue
+		}
+		if input.VersionId != nil && object.VersionID != aws.StringValue(input.VersionId) {
+			continue
+		}
+		// the last matching object is the latest version
+		found = &f.objects[i]
+	}
+	if found == nil {
+		return nil, awserr.New(s3.ErrCodeNoSuchKey, "not found", nil)
+	}
+
+	return &s3.GetObjectOutput{Body: ioutil.NopCloser(bytes.NewReader(found.Content))}, nil
+}
+
+func (f *fakeS3) ListObjectsV2Pages(input *s3.ListObjectsV2Input, fn func(*s3.ListObjectsV2Output, bool) bool) error {
+	page := &s3.ListObjectsV2Output{}
+	for _, object := range f.objects {
+		if !strings.HasPrefix(object.Key, aws.StringValue(input.Prefix)) {
+			continue
+		}
+		page.Contents = append(page.Contents, &s3.Object{
+			Key:          aws.String(object.Key),
+			ETag:         aws.String(object.ETag),
+			LastModified: aws.Time(object.LastModified),
+		})
+	}
+	fn(page, true)
+	return nil
+}
+
+func (f *fakeS3) ListObjectVersionsPages(input *s3.ListObjectVersionsInput, fn func(*s3.ListObjectVersionsOutput, bool) bool) error {
+	page := &s3.ListObjectVersionsOutput{}
+	for _, object := range f.objects {
+		if !strings.HasPrefix(object.Key, aws.StringValue(input.Prefix)) {
+			continue
+		}
+		page.Versions = append(page.Versions, &s3.ObjectVersion{
+			Key:          aws.String(object.Key),
+			VersionId:    aws.String(object.VersionID),
+			ETag:         aws.String(object.ETag),
+			LastModified: aws.Time(object.LastModified),
+		})
+	}
+	fn(page, true)
+	return nil
+}
+
+func useFakeS3(client *fakeS3) func() {
+	original := newS3Client
+	newS3Client = func() (s3iface.S3API, error) {
+		return client, nil
+	}
+	return func() {
+		newS3Client = original
+	}
+}
+
+func Test_getUpdatesS3(t *testing.T) {
+	first := time.Date(2019, 10, 1, 0, 0, 0, 0, time.UTC)
+	second := first.Add(time.Hour)
+
+	client := &fakeS3{
+		objects: []fakeS3Object{
+			{Key: "releases/app-1.1.0.tar.gz", LastModified: second},
+			{Key: "releases/app-1.0.0.tar.gz", LastModified: first},
+			{Key: "releases/notes.txt", LastModified: second},
+			{Key: "app.tar.gz", VersionID: "v1", LastModified: first},
+			{Key: "app.tar.gz", VersionID: "v2", LastModified: second},
+			{Key: "unversioned.tar.gz", VersionID: "null", ETag: `"abc"`, LastModified: first},
+		},
+	}
+	defer useFakeS3(client)()
+
+	tests := []struct {
+		name          string
+		uri           string
+		currentCursor string
+		expected      []Update
+	}{
+		{
+			name:          "pattern, no cursor",
+			uri:           "s3://bucket/releases/app-*.tar.gz",
+			currentCursor: "",
+			expected: []Update{
+				{Cursor: "releases/app-1.1.0.tar.gz", VersionLabel: "app-1.1.0", CreatedAt: second.Format(time.RFC3339)},
+			},
+		},
+		{
+			name:          "pattern, behind",
+			uri:           "s3://bucket/releases/app-*.tar.gz",
+			currentCursor: "releases/app-1.0.0.tar.gz",
+			expected: []Update{
+				{Cursor: "releases/app-1.1.0.tar.gz", VersionLabel: "app-1.1.0", CreatedAt: second.Format(time.RFC3339)},
+			},
+		},
+		{
+			name:          "pattern, current",
+			uri:           "s3://bucket/releases/app-*.tar.gz",
+			currentCursor: "releases/app-1.1.0.tar.gz",
+			expected:      []Update{},
+		},
+		{
+			name:          "versioned, behind",
+			uri:           "s3://bucket/app.tar.gz",
+			currentCursor: "v1",
+			expected: []Update{
+				{Cursor: "v2", VersionLabel: "app", CreatedAt: second.Format(time.RFC3339)},
+			},
+		},
+		{
+			name:          "unversioned bucket",
+			uri:           "s3://bucket/unversioned.tar.gz",
+			currentCursor: "",
+			expected: []Update{
+				{Cursor: `"abc"`, VersionLabel: "unversioned", CreatedAt: first.Format(time.RFC3339)},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := require.New(t)
+
+			updates, err := getUpdatesS3(test.uri, test.currentCursor)
+			req.NoError(err)
+			assert.Equal(t, test.expected, updates)
+		})
+	}
+}
+
+func Test_downloadS3(t *testing.T) {
+	first := time.Date(2019, 10, 1, 0, 0, 0, 0, time.UTC)
+	second := first.Add(time.Hour)
+
+	client := &fakeS3{
+		objects: []fakeS3Object{
+			{Key: "releases/app-1.0.0.tar.gz", LastModified: first, Content: createTestTarGz(t, map[string]string{"old.yaml": "old"})},
+			{Key: "releases/app-1.1.0.tar.gz", LastModified: second, Content: createTestTarGz(t, testArchiveFiles)},
+			{Key: "app.zip", VersionID: "v1", LastModified: first, Content: createTestZip(t, map[string]string{"old.yaml": "old"})},
+			{Key: "app.zip", VersionID: "v2", LastModified: second, Content: createTestZip(t, testArchiveFiles)},
+			{Key: "app.txt", VersionID: "v1", LastModified: first},
+		},
+	}
+	defer useFakeS3(client)()
+
+	tests := []struct {
+		name                 string
+		uri                  string
+		cursor               string
+		expectedVersionLabel string
+		expectedCursor       string
+		expectedPaths        []string
+		expectErr            bool
+	}{
+		{
+			name:                 "pattern",
+			uri:                  "s3://bucket/releases/app-*.tar.gz",
+			expectedVersionLabel: "app-1.1.0",
+			expectedCursor:       "releases/app-1.1.0.tar.gz",
+			expectedPaths:        []string{"deployment.yaml", "service.yaml"},
+		},
+		{
+			name:                 "pattern at a cursor",
+			uri:                  "s3://bucket/releases/app-*.tar.gz",
+			cursor:               "releases/app-1.0.0.tar.gz",
+			expectedVersionLabel: "app-1.0.0",
+			expectedCursor:       "releases/app-1.0.0.tar.gz",
+			expectedPaths:        []string{"old.yaml"},
+		},
+		{
+			name:                 "versioned",
+			uri:                  "s3://bucket/app.zip",
+			expectedVersionLabel: "app",
+			expectedCursor:       "v2",
+			expectedPaths:        []string{"deployment.yaml", "service.yaml"},
+		},
+		{
+			name:                 "versioned at a cursor",
+			uri:                  "s3://bucket/app.zip",
+			cursor:               "v1",
+			expectedVersionLabel: "app",
+			expectedCursor:       "v1",
+			expectedPaths:        []string{"old.yaml"},
+		},
+		{
+			name:      "unknown cursor",
+			uri:       "s3://bucket/app.zip",
+			cursor:    "v3",
+			expectErr: true,
+		},
+		{
+			name:      "not an archive",
+			uri:       "s3://bucket/app.txt",
+			expectErr: true,
+		},
+		{
+			name:      "missing",
+			uri:       "s3://bucket/missing-*.tar.gz",
+			expectErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := require.New(t)
+
+			u, err := downloadS3(test.uri, test.cursor)
+			if test.expectErr {
+				req.Error(err)
+				return
+			}
+			req.NoError(err)
+
+			assert.Equal(t, "s3", u.Type)
+			assert.Equal(t, "app", u.Name)
+			assert.Equal(t, test.expectedVersionLabel, u.VersionLabel)
+			assert.Equal(t, test.expectedCursor, u.UpdateCursor)
+
+			paths := []string{}
+			for _, f := range u.Files {
+				paths = append(paths, f.Path)
+			}
+			assert.ElementsMatch(t, test.expectedPaths, paths)
+		})
+	}
+}
@@ -0,0 +1,61 @@
+package upstream
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+)
+
+// maxInMemoryFileSize is the size of the largest release file that's held in memory.
+// Larger files, and archives of any size, are staged on disk.
+var maxInMemoryFileSize int64 = 1 << 20
+
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// shouldStageFile is true for files that aren't read as manifests, either because they
+// are archives (charts) or because they are too large
+func shouldStageFile(r *bufio.Reader, size int64) bool {
+	if size > maxInMemoryFileSize {
+		return true
+	}
+
+	magic, err := r.Peek(len(gzipMagic))
+	if err != nil {
+		return false
+	}
+	return bytes.Equal(magic, gzipMagic)
+}
+
+// stageFile streams r to a new file in stagingDir and returns its path
+func stageFile(stagingDir string, name string, r io.Reader) (string, error) {
+	f, err := ioutil.TempFile(stagingDir, filepath.Base(name))
+	if err != nil {
+		return "", errors.Wrap(err, "failed to create staged file")
+	}
+	defer f.Close()
+
+	if _, err := io.Copy(f, r); err != nil {
+		os.Remove(f.Name())
+		return "", errors.Wrap(err, "failed to write staged file")
+	}
+
+	return f.Name(), nil
+}
+
+// stageArchive streams r to a new file in stagingDir, and returns its path and sha256 digest
+func stageArchive(stagingDir string, r io.Reader) (string, string, error) {
+	h := sha256.New()
+	archivePath, err := stageFile(stagingDir, "release.tar.gz", io.TeeReader(r, h))
+	if err != nil {
+		return "", "", err
+	}
+
+	return archivePath, hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -1,8 +1,14 @@
 package types
 
 import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
 	"path"
 
+	"github.com/pkg/errors"
+
 	kotsscheme "github.com/replicatedhq/kots/kotskinds/client/kotsclientset/scheme"
 	"k8s.io/client-go/kubernetes/scheme"
 )
@@ -14,17 +20,95 @@ func init() {
 type UpstreamFile struct {
 	Path    string
 	Content []byte
+	// LocalPath is set instead of Content for files that are too large to be held in memory,
+	// such as chart archives. The content is read from disk when it's needed.
+	LocalPath string
+}
+
+// Open returns a reader for the content of the file, whether it's in memory or on disk
+func (f UpstreamFile) Open() (io.ReadCloser, error) {
+	if f.LocalPath == "" {
+		return ioutil.NopCloser(bytes.NewReader(f.Content)), nil
+	}
+
+	file, err := os.Open(f.LocalPath)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to open staged file")
+	}
+	return file, nil
+}
+
+// ReadContent returns the content of the file, reading it from disk if it was staged
+func (f UpstreamFile) ReadContent() ([]byte, error) {
+	if f.LocalPath == "" {
+		return f.Content, nil
+	}
+
+	content, err := ioutil.ReadFile(f.LocalPath)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read staged file")
+	}
+	return content, nil
+}
+
+// WriteFile writes the content of the file to filename. Staged files are copied
+// without reading them into memory.
+func (f UpstreamFile) WriteFile(filename string) error {
+	if f.LocalPath == "" {
+		return ioutil.WriteFile(filename, f.Content, 0644)
+	}
+
+	src, err := os.Open(f.LocalPath)
+	if err != nil {
+		return errors.Wrap(err, "failed to open staged file")
+	}
+	defer src.Close()
+
+	dest, err := os.OpenFile(filename, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return errors.Wrap(err, "failed to create file")
+	}
+	defer dest.Close()
+
+	if _, err := io.Copy(dest, src); err != nil {
+		return errors.Wrap(err, "failed to copy staged file")
+	}
+	return dest.Close()
 }
 
 type Upstream struct {
-	URI           string
-	Name          string
+	URI  string
+	Name string
+	// Path is the directory that a component of a composed application is written to,
+	// under the app's upstream dir. It's empty for an application with a single upstream.
+	Path          string
 	Type          string
 	Files         []UpstreamFile
 	UpdateCursor  string
 	VersionLabel  string
 	ReleaseNotes  string
 	EncryptionKey string
+	// KustomizationDir is the dir of the kustomization that's built for kustomize upstreams,
+	// relative to the root of the files
+	KustomizationDir string
+	// JsonnetEntrypoint is the file that's evaluated for jsonnet upstreams, or a dir with a
+	// main.jsonnet, relative to the root of the files
+	JsonnetEntrypoint string
+	// StagingDir holds the files that were streamed to disk while fetching the upstream.
+	// It has to be removed with Cleanup once the upstream has been written and rendered.
+	StagingDir string
+}
+
+// Cleanup removes the files that were staged on disk while fetching the upstream
+func (u *Upstream) Cleanup() error {
+	if u.StagingDir == "" {
+		return nil
+	}
+
+	if err := os.RemoveAll(u.StagingDir); err != nil {
+		return errors.Wrap(err, "failed to remove staging dir")
+	}
+	return nil
 }
 
 type WriteOptions struct {
@@ -32,6 +116,10 @@ type WriteOptions struct {
 	CreateAppDir        bool
 	IncludeAdminConsole bool
 	SharedPassword      string
+	// KubernetesVersion and APIVersions are kept in the installation, so that the upstream is
+	// rendered for the same cluster when it's rewritten
+	KubernetesVersion string
+	APIVersions       []string
 }
 
 func (u *Upstream) GetBaseDir(options WriteOptions) string {
@@ -2,6 +2,7 @@ package upstream
 
 import (
 	"bytes"
+	"encoding/base64"
 	"io/ioutil"
 	"os"
 	"path"
@@ -21,7 +22,7 @@ func WriteUpstream(u *types.Upstream, options types.WriteOptions) error {
 		renderDir = path.Join(renderDir, u.Name)
 	}
 
-	renderDir = path.Join(renderDir, "upstream")
+	renderDir = path.Join(renderDir, "upstream", u.Path)
 
 	if options.IncludeAdminConsole {
 		adminConsoleFiles, err := generateAdminConsoleFiles(renderDir, options.SharedPassword)
@@ -71,7 +72,7 @@ func WriteUpstream(u *types.Upstream, options types.WriteOptions) error {
 			}
 		}
 
-		if err := ioutil.WriteFile(fileRenderPath, file.Content, 0644); err != nil {
+		if err := file.WriteFile(fileRenderPath); err != nil {
 			return errors.Wrap(err, "failed to write upstream file")
 		}
 	}
@@ -105,6 +106,22 @@ func WriteUpstream(u *types.Upstream, options types.WriteOptions) error {
 	if err != nil {
 		return errors.Wrap(err, "failed to get encryption key")
 	}
+	for i, f := range u.Files {
+		if f.Path == path.Join("userdata", "config.yaml") {
+			migratedValues, err := migrateConfigValuesCiphertext(f.Content, encryptionKey)
+			if err != nil {
+				return errors.Wrap(err, "failed to migrate encrypted config values")
+			}
+
+			err = ioutil.WriteFile(path.Join(renderDir, "userdata", "config.yaml"), migratedValues, 0644)
+			if err != nil {
+				return errors.Wrap(err, "failed to write migrated config values")
+			}
+
+			u.Files[i].Content = migratedValues
+		}
+	}
+
 	installation := kotsv1beta1.Installation{
 		TypeMeta: metav1.TypeMeta{
 			APIVersion: "kots.io/v1beta1",
@@ -114,10 +131,12 @@ func WriteUpstream(u *types.Upstream, options types.WriteOptions) error {
 			Name: u.Name,
 		},
 		Spec: kotsv1beta1.InstallationSpec{
-			UpdateCursor:  u.UpdateCursor,
-			VersionLabel:  u.VersionLabel,
-			ReleaseNotes:  u.ReleaseNotes,
-			EncryptionKey: encryptionKey,
+			UpdateCursor:      u.UpdateCursor,
+			VersionLabel:      u.VersionLabel,
+			ReleaseNotes:      u.ReleaseNotes,
+			EncryptionKey:     encryptionKey,
+			KubernetesVersion: options.KubernetesVersion,
+			APIVersions:       options.APIVersions,
 		},
 	}
 	if _, err := os.Stat(path.Join(renderDir, "userdata")); os.IsNotExist(err) {
@@ -186,6 +205,56 @@ func mergeValues(previousValues []byte, applicationDeliveredValues []byte) ([]by
 	return b.Bytes(), nil
 }
 
+// migrateConfigValuesCiphertext re-encrypts the values that were encrypted with the legacy fixed nonce,
+// so that all password values use the versioned ciphertext with a nonce per message
+func migrateConfigValuesCiphertext(valuesContent []byte, encryptionKey string) ([]byte, error) {
+	if encryptionKey == "" {
+		return valuesContent, nil
+	}
+
+	cipher, err := crypto.AESCipherFromString(encryptionKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create cipher")
+	}
+
+	decode := scheme.Codecs.UniversalDeserializer().Decode
+	obj, _, err := decode(valuesContent, nil, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to decode values")
+	}
+	values := obj.(*kotsv1beta1.ConfigValues)
+
+	migrated := false
+	for name, value := range values.Spec.Values {
+		decoded, err := base64.StdEncoding.DecodeString(value.Value)
+		if err != nil || !cipher.IsLegacyCiphertext(decoded) {
+			continue
+		}
+
+		decrypted, err := cipher.Decrypt(decoded)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to decrypt %s", name)
+		}
+
+		value.Value = base64.StdEncoding.EncodeToString(cipher.Encrypt(decrypted))
+		values.Spec.Values[name] = value
+		migrated = true
+	}
+
+	if !migrated {
+		return valuesContent, nil
+	}
+
+	s := json.NewYAMLSerializer(json.DefaultMetaFactory, scheme.Scheme, scheme.Scheme)
+
+	var b bytes.Buffer
+	if err := s.Encode(values, &b); err != nil {
+		return nil, errors.Wrap(err, "failed to encode migrated values")
+	}
+
+	return b.Bytes(), nil
+}
+
 func mustMarshalInstallation(installation *kotsv1beta1.Installation) []byte {
 	s := json.NewYAMLSerializer(json.DefaultMetaFactory, scheme.Scheme, scheme.Scheme)
 
@@ -0,0 +1,110 @@
+package upstream
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/base64"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+
+	kotsv1beta1 "github.com/replicatedhq/kots/kotskinds/apis/kots/v1beta1"
+	"github.com/replicatedhq/kots/pkg/crypto"
+	"github.com/replicatedhq/kots/pkg/upstream/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+)
+
+// legacyEncryptForTest encrypts the way ciphers did before ciphertext was versioned,
+// with the nonce that's stored in the key string
+func legacyEncryptForTest(t *testing.T, encryptionKey string, message string) string {
+	req := require.New(t)
+
+	decoded, err := base64.StdEncoding.DecodeString(encryptionKey)
+	req.NoError(err)
+
+	block, err := aes.NewCipher(decoded[:24])
+	req.NoError(err)
+	gcm, err := cipher.NewGCM(block)
+	req.NoError(err)
+
+	return base64.StdEncoding.EncodeToString(gcm.Seal(nil, decoded[24:], []byte(message), nil))
+}
+
+func Test_WriteUpstreamMigratesLegacyCiphertext(t *testing.T) {
+	req := require.New(t)
+
+	aesCipher, err := crypto.NewAESCipher()
+	req.NoError(err)
+	encryptionKey := aesCipher.ToString()
+
+	rootDir, err := ioutil.TempDir("", "kots")
+	req.NoError(err)
+	defer os.RemoveAll(rootDir)
+
+	legacyPassword := legacyEncryptForTest(t, encryptionKey, "secret")
+	configValues := &kotsv1beta1.ConfigValues{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "kots.io/v1beta1",
+			Kind:       "ConfigValues",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "app",
+		},
+		Spec: kotsv1beta1.ConfigValuesSpec{
+			Values: map[string]kotsv1beta1.ConfigValue{
+				"password": {Value: legacyPassword},
+				"hostname": {Value: "example.com"},
+			},
+		},
+	}
+
+	// a previous install that used the same encryption key
+	userdataDir := path.Join(rootDir, "app", "upstream", "userdata")
+	req.NoError(os.MkdirAll(userdataDir, 0755))
+	installation := &kotsv1beta1.Installation{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "kots.io/v1beta1",
+			Kind:       "Installation",
+		},
+		Spec: kotsv1beta1.InstallationSpec{
+			EncryptionKey: encryptionKey,
+		},
+	}
+	req.NoError(ioutil.WriteFile(path.Join(userdataDir, "installation.yaml"), mustMarshalInstallation(installation), 0644))
+	req.NoError(ioutil.WriteFile(path.Join(userdataDir, "config.yaml"), mustMarshalConfigValues(configValues), 0644))
+
+	u := &types.Upstream{
+		Name: "app",
+		Files: []types.UpstreamFile{
+			{
+				Path:    "userdata/config.yaml",
+				Content: mustMarshalConfigValues(configValues),
+			},
+		},
+	}
+	err = WriteUpstream(u, types.WriteOptions{RootDir: rootDir, CreateAppDir: true})
+	req.NoError(err)
+
+	content, err := ioutil.ReadFile(path.Join(userdataDir, "config.yaml"))
+	req.NoError(err)
+	assert.Equal(t, content, u.Files[0].Content)
+
+	obj, _, err := scheme.Codecs.UniversalDeserializer().Decode(content, nil, nil)
+	req.NoError(err)
+	values := obj.(*kotsv1beta1.ConfigValues).Spec.Values
+
+	assert.Equal(t, "example.com", values["hostname"].Value)
+	assert.NotEqual(t, legacyPassword, values["password"].Value)
+
+	encrypted, err := base64.StdEncoding.DecodeString(values["password"].Value)
+	req.NoError(err)
+	assert.False(t, aesCipher.IsLegacyCiphertext(encrypted))
+
+	decrypted, err := aesCipher.Decrypt(encrypted)
+	req.NoError(err)
+	assert.Equal(t, "secret", string(decrypted))
+}
@@ -3,6 +3,9 @@ package util
 import (
 	"bytes"
 	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
 )
 
 func IsURL(str string) bool {
@@ -14,6 +17,41 @@ func IsURL(str string) bool {
 	return true
 }
 
+// IsLocalPath returns true if str is written as a path on the local filesystem, a file:// uri or a
+// path that starts with ./, ../, / or ~. Other names are not paths even when a directory with the
+// name exists, so that an app slug isn't read from the dir it was pulled to. Absolute paths are
+// also valid request uris, so this should be checked before IsURL.
+func IsLocalPath(str string) bool {
+	if strings.HasPrefix(str, "file://") {
+		return true
+	}
+	if str == "." || str == ".." || str == "~" {
+		return true
+	}
+	for _, prefix := range []string{"./", "../", "/", "~/"} {
+		if strings.HasPrefix(str, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// LocalPath returns the absolute path of a local path, without the file:// scheme and with ~
+// expanded to the home dir
+func LocalPath(str string) (string, error) {
+	str = strings.TrimPrefix(str, "file://")
+
+	if str == "~" || strings.HasPrefix(str, "~/") {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		str = filepath.Join(home, str[1:])
+	}
+
+	return filepath.Abs(str)
+}
+
 func CommonSlicePrefix(first []string, second []string) []string {
 	common := []string{}
 
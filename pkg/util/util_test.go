@@ -1,6 +1,8 @@
 package util
 
 import (
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -116,3 +118,55 @@ func TestIntPointer(t *testing.T) {
 		})
 	}
 }
+
+func Test_IsLocalPath(t *testing.T) {
+	tests := []struct {
+		str      string
+		expected bool
+	}{
+		{str: ".", expected: true},
+		{str: "./manifests", expected: true},
+		{str: "../manifests", expected: true},
+		{str: "/path/to/manifests", expected: true},
+		{str: "~/manifests", expected: true},
+		{str: "file:///path/to/manifests", expected: true},
+		{str: "app-slug", expected: false},
+		{str: "app-slug/beta", expected: false},
+		{str: "replicated://app-slug", expected: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.str, func(t *testing.T) {
+			assert.Equal(t, test.expected, IsLocalPath(test.str))
+		})
+	}
+}
+
+func Test_LocalPath(t *testing.T) {
+	req := require.New(t)
+
+	home, err := os.UserHomeDir()
+	req.NoError(err)
+	cwd, err := os.Getwd()
+	req.NoError(err)
+
+	tests := []struct {
+		str      string
+		expected string
+	}{
+		{str: "./manifests", expected: filepath.Join(cwd, "manifests")},
+		{str: "/path/to/manifests", expected: "/path/to/manifests"},
+		{str: "~/manifests", expected: filepath.Join(home, "manifests")},
+		{str: "file:///path/to/manifests", expected: "/path/to/manifests"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.str, func(t *testing.T) {
+			req := require.New(t)
+
+			actual, err := LocalPath(test.str)
+			req.NoError(err)
+			assert.Equal(t, test.expected, actual)
+		})
+	}
+}
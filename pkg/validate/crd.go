@@ -0,0 +1,152 @@
+package validate
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/replicatedhq/kots/pkg/base"
+)
+
+// findCRDs returns the custom resource definitions in the files
+func findCRDs(files []base.BaseFile) []map[string]interface{} {
+	crds := []map[string]interface{}{}
+	for _, file := range files {
+		for _, doc := range base.SplitYAMLDocuments(file.Content) {
+			obj, err := unmarshalDocument(doc)
+			if err != nil || obj == nil {
+				continue
+			}
+
+			apiVersion, _ := obj["apiVersion"].(string)
+			kind, _ := obj["kind"].(string)
+			if strings.HasPrefix(apiVersion, "apiextensions.k8s.io/") && kind == "CustomResourceDefinition" {
+				crds = append(crds, obj)
+			}
+		}
+	}
+	return crds
+}
+
+// crdDefinitions converts the openapi v3 schemas of each version of a crd into swagger definitions,
+// the same way that the api server publishes them
+func crdDefinitions(crd map[string]interface{}, hasObjectMeta bool) map[string]interface{} {
+	spec, _ := crd["spec"].(map[string]interface{})
+	group, _ := spec["group"].(string)
+	names, _ := spec["names"].(map[string]interface{})
+	kind, _ := names["kind"].(string)
+	if group == "" || kind == "" {
+		return nil
+	}
+
+	// the schema in spec.validation is used for versions that don't have their own
+	commonSchema := nestedMap(spec, "validation", "openAPIV3Schema")
+
+	versionSchemas := map[string]map[string]interface{}{}
+	if versions, ok := spec["versions"].([]interface{}); ok {
+		for _, item := range versions {
+			version, _ := item.(map[string]interface{})
+			name, _ := version["name"].(string)
+			if name == "" {
+				continue
+			}
+
+			versionSchema := nestedMap(version, "schema", "openAPIV3Schema")
+			if versionSchema == nil {
+				versionSchema = commonSchema
+			}
+			versionSchemas[name] = versionSchema
+		}
+	}
+	if version, ok := spec["version"].(string); ok && version != "" && versionSchemas[version] == nil {
+		versionSchemas[version] = commonSchema
+	}
+
+	definitions := map[string]interface{}{}
+	for version, openAPIV3Schema := range versionSchemas {
+		if openAPIV3Schema == nil {
+			continue
+		}
+
+		definition := swaggerSchema(openAPIV3Schema)
+		if properties, ok := definition["properties"].(map[string]interface{}); ok {
+			properties["apiVersion"] = map[string]interface{}{"type": "string"}
+			properties["kind"] = map[string]interface{}{"type": "string"}
+			if hasObjectMeta {
+				properties["metadata"] = map[string]interface{}{"$ref": "#/definitions/" + objectMetaDefinition}
+			} else {
+				properties["metadata"] = map[string]interface{}{"type": "object"}
+			}
+		}
+		definition["x-kubernetes-group-version-kind"] = []interface{}{
+			map[string]interface{}{
+				"group":   group,
+				"version": version,
+				"kind":    kind,
+			},
+		}
+
+		definitions[fmt.Sprintf("crd.%s.%s.%s", group, version, kind)] = definition
+	}
+
+	return definitions
+}
+
+// swaggerSchema converts an openapi v3 schema into a swagger schema. Parts of the schema that don't
+// exist in swagger, such as oneOf and int-or-string, are left untyped so that any value is valid.
+func swaggerSchema(s map[string]interface{}) map[string]interface{} {
+	converted := map[string]interface{}{}
+	if description, ok := s["description"].(string); ok {
+		converted["description"] = description
+	}
+	if format, ok := s["format"].(string); ok {
+		converted["format"] = format
+	}
+
+	preserveUnknownFields, _ := s["x-kubernetes-preserve-unknown-fields"].(bool)
+
+	t, _ := s["type"].(string)
+	switch t {
+	case "object":
+		converted["type"] = "object"
+
+		properties, _ := s["properties"].(map[string]interface{})
+		if len(properties) > 0 && !preserveUnknownFields {
+			convertedProperties := map[string]interface{}{}
+			for name, property := range properties {
+				if p, ok := property.(map[string]interface{}); ok {
+					convertedProperties[name] = swaggerSchema(p)
+				}
+			}
+			converted["properties"] = convertedProperties
+
+			if required, ok := s["required"].([]interface{}); ok && len(required) > 0 {
+				converted["required"] = required
+			}
+		} else if additionalProperties, ok := s["additionalProperties"].(map[string]interface{}); ok {
+			converted["additionalProperties"] = swaggerSchema(additionalProperties)
+		}
+
+	case "array":
+		if items, ok := s["items"].(map[string]interface{}); ok {
+			converted["type"] = "array"
+			converted["items"] = swaggerSchema(items)
+		}
+
+	case "string", "integer", "number", "boolean":
+		converted["type"] = t
+	}
+
+	return converted
+}
+
+func nestedMap(obj map[string]interface{}, fields ...string) map[string]interface{} {
+	current := obj
+	for _, field := range fields {
+		next, ok := current[field].(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		current = next
+	}
+	return current
+}
@@ -0,0 +1,368 @@
+package validate
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	semver "github.com/Masterminds/semver/v3"
+	openapi_v2 "github.com/googleapis/gnostic/OpenAPIv2"
+	"github.com/googleapis/gnostic/compiler"
+	"github.com/pkg/errors"
+	"github.com/replicatedhq/kots/pkg/base"
+	"gopkg.in/yaml.v2"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/kube-openapi/pkg/util/proto"
+	"k8s.io/kube-openapi/pkg/util/proto/validation"
+	k8syaml "sigs.k8s.io/yaml"
+)
+
+const objectMetaDefinition = "io.k8s.apimachinery.pkg.apis.meta.v1.ObjectMeta"
+
+type Options struct {
+	// SchemaDir has a dir for each kubernetes version, with the swagger.json that the api server
+	// serves at /openapi/v2, such as v1.16.2/swagger.json
+	SchemaDir         string
+	KubernetesVersion string
+}
+
+// Error is a document that doesn't match its schema
+type Error struct {
+	// Path is the path of the upstream file that the document is in
+	Path      string
+	DocIndex  int
+	FieldPath string
+	Message   string
+}
+
+func (e Error) Error() string {
+	if e.FieldPath == "" {
+		return fmt.Sprintf("%s (document %d): %s", e.Path, e.DocIndex, e.Message)
+	}
+	return fmt.Sprintf("%s (document %d): %s: %s", e.Path, e.DocIndex, e.FieldPath, e.Message)
+}
+
+// DefaultSchemaDir is the schema dir from the KOTS_SCHEMA_DIR environment variable, or .kots/schemas
+// in the user's home dir
+func DefaultSchemaDir() string {
+	if dir := os.Getenv("KOTS_SCHEMA_DIR"); dir != "" {
+		return dir
+	}
+
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return filepath.Join(os.TempDir(), "kots-schemas")
+	}
+	return filepath.Join(homeDir, ".kots", "schemas")
+}
+
+// ValidateBase validates the resources in the base and its sub-bases
+func ValidateBase(b *base.Base, options Options) ([]Error, error) {
+	return ValidateFiles(baseFiles(b, ""), options)
+}
+
+// ValidateFiles validates each of the resources in the files against the openapi schema of the
+// kubernetes version, and the schemas of the crds in the files. Resources of kinds that aren't in
+// either, such as kots kinds, are not validated.
+func ValidateFiles(files []base.BaseFile, options Options) ([]Error, error) {
+	v, err := newValidator(files, options)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to load schemas")
+	}
+
+	validationErrors := []Error{}
+	for _, file := range files {
+		validationErrors = append(validationErrors, v.validateFile(file)...)
+	}
+
+	return validationErrors, nil
+}
+
+func baseFiles(b *base.Base, dir string) []base.BaseFile {
+	files := []base.BaseFile{}
+	for _, file := range b.Files {
+		file.Path = path.Join(dir, file.Path)
+		if file.UpstreamPath != "" {
+			file.UpstreamPath = path.Join(dir, file.UpstreamPath)
+		}
+		files = append(files, file)
+	}
+	for _, subBase := range b.Bases {
+		files = append(files, baseFiles(&subBase, path.Join(dir, subBase.Path))...)
+	}
+	return files
+}
+
+type validator struct {
+	kubernetesVersion string
+	resources         map[schema.GroupVersionKind]proto.Schema
+	// groups are the api groups in the schema, kinds in these groups that aren't in the schema
+	// aren't available in the kubernetes version
+	groups map[string]bool
+}
+
+func newValidator(files []base.BaseFile, options Options) (*validator, error) {
+	schemaFile, err := findSchemaFile(options.SchemaDir, options.KubernetesVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	content, err := ioutil.ReadFile(schemaFile)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read schema")
+	}
+
+	swagger := map[string]interface{}{}
+	if err := json.Unmarshal(content, &swagger); err != nil {
+		return nil, errors.Wrapf(err, "failed to unmarshal %s", schemaFile)
+	}
+	definitions, ok := swagger["definitions"].(map[string]interface{})
+	if !ok {
+		return nil, errors.Errorf("%s has no definitions", schemaFile)
+	}
+
+	for _, crd := range findCRDs(files) {
+		for name, definition := range crdDefinitions(crd, definitions[objectMetaDefinition] != nil) {
+			definitions[name] = definition
+		}
+	}
+
+	combined, err := json.Marshal(swagger)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to marshal schema")
+	}
+	var info yaml.MapSlice
+	if err := yaml.Unmarshal(combined, &info); err != nil {
+		return nil, errors.Wrap(err, "failed to unmarshal schema")
+	}
+	doc, err := openapi_v2.NewDocument(info, compiler.NewContext("$root", nil))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse schema")
+	}
+	models, err := proto.NewOpenAPIData(doc)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read models")
+	}
+
+	v := validator{
+		kubernetesVersion: filepath.Base(filepath.Dir(schemaFile)),
+		resources:         map[schema.GroupVersionKind]proto.Schema{},
+		groups:            map[string]bool{},
+	}
+	for _, name := range models.ListModels() {
+		model := models.LookupModel(name)
+		for _, gvk := range groupVersionKinds(model) {
+			v.resources[gvk] = model
+			v.groups[gvk.Group] = true
+		}
+	}
+
+	return &v, nil
+}
+
+// findSchemaFile returns the swagger.json for the kubernetes version, or for the latest patch
+// version of the same minor version
+func findSchemaFile(schemaDir string, kubernetesVersion string) (string, error) {
+	entries, err := ioutil.ReadDir(schemaDir)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to read schema dir %s", schemaDir)
+	}
+
+	available := map[string]*semver.Version{}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(schemaDir, entry.Name(), "swagger.json")); err != nil {
+			continue
+		}
+		version, err := semver.NewVersion(entry.Name())
+		if err != nil {
+			continue
+		}
+		available[entry.Name()] = version
+	}
+
+	if kubernetesVersion == "" {
+		if len(available) != 1 {
+			return "", errors.Errorf("a kubernetes version is required when there are schemas for %d versions in %s", len(available), schemaDir)
+		}
+		for name := range available {
+			return filepath.Join(schemaDir, name, "swagger.json"), nil
+		}
+	}
+
+	wanted, err := semver.NewVersion(kubernetesVersion)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to parse kubernetes version %q", kubernetesVersion)
+	}
+
+	match := ""
+	var matchVersion *semver.Version
+	for name, version := range available {
+		if version.Equal(wanted) {
+			return filepath.Join(schemaDir, name, "swagger.json"), nil
+		}
+		if version.Major() != wanted.Major() || version.Minor() != wanted.Minor() {
+			continue
+		}
+		if matchVersion == nil || version.GreaterThan(matchVersion) {
+			match = name
+			matchVersion = version
+		}
+	}
+	if match == "" {
+		return "", errors.Errorf("no schema for kubernetes %s in %s", kubernetesVersion, schemaDir)
+	}
+
+	return filepath.Join(schemaDir, match, "swagger.json"), nil
+}
+
+// groupVersionKinds returns the kinds that a model is the schema for, from the
+// x-kubernetes-group-version-kind extension
+func groupVersionKinds(model proto.Schema) []schema.GroupVersionKind {
+	extension, ok := model.GetExtensions()["x-kubernetes-group-version-kind"].([]interface{})
+	if !ok {
+		return nil
+	}
+
+	gvks := []schema.GroupVersionKind{}
+	for _, item := range extension {
+		values := map[string]string{}
+		switch m := item.(type) {
+		case map[interface{}]interface{}:
+			for k, v := range m {
+				values[fmt.Sprintf("%v", k)] = fmt.Sprintf("%v", v)
+			}
+		case map[string]interface{}:
+			for k, v := range m {
+				values[k] = fmt.Sprintf("%v", v)
+			}
+		default:
+			continue
+		}
+
+		gvks = append(gvks, schema.GroupVersionKind{
+			Group:   values["group"],
+			Version: values["version"],
+			Kind:    values["kind"],
+		})
+	}
+	return gvks
+}
+
+func (v *validator) validateFile(file base.BaseFile) []Error {
+	ext := path.Ext(file.Path)
+	if ext != ".yaml" && ext != ".yml" && ext != ".json" {
+		return nil
+	}
+
+	upstreamPath := file.Path
+	if file.UpstreamPath != "" {
+		upstreamPath = file.UpstreamPath
+	}
+
+	validationErrors := []Error{}
+	for idx, doc := range base.SplitYAMLDocuments(file.Content) {
+		docIndex := idx
+		if file.UpstreamPath != "" {
+			docIndex = file.UpstreamDocIndex + idx
+		}
+
+		for _, validationError := range v.validateDocument(doc) {
+			validationError.Path = upstreamPath
+			validationError.DocIndex = docIndex
+			validationErrors = append(validationErrors, validationError)
+		}
+	}
+
+	return validationErrors
+}
+
+func (v *validator) validateDocument(doc []byte) []Error {
+	obj, err := unmarshalDocument(doc)
+	if err != nil {
+		return []Error{{Message: err.Error()}}
+	}
+
+	apiVersion, _ := obj["apiVersion"].(string)
+	kind, _ := obj["kind"].(string)
+	if apiVersion == "" || kind == "" {
+		return nil
+	}
+
+	gv, err := schema.ParseGroupVersion(apiVersion)
+	if err != nil {
+		return []Error{{FieldPath: "apiVersion", Message: err.Error()}}
+	}
+
+	model, ok := v.resources[gv.WithKind(kind)]
+	if !ok {
+		if v.groups[gv.Group] {
+			return []Error{{FieldPath: "apiVersion", Message: fmt.Sprintf("%s %s is not available in kubernetes %s", apiVersion, kind, v.kubernetesVersion)}}
+		}
+		return nil
+	}
+
+	validationErrors := []Error{}
+	for _, err := range validation.ValidateModel(obj, model, "") {
+		fieldPath, message := describeValidationError(err)
+		validationErrors = append(validationErrors, Error{
+			FieldPath: fieldPath,
+			Message:   message,
+		})
+	}
+
+	sort.SliceStable(validationErrors, func(i, j int) bool {
+		return validationErrors[i].FieldPath < validationErrors[j].FieldPath
+	})
+	return validationErrors
+}
+
+func unmarshalDocument(doc []byte) (map[string]interface{}, error) {
+	jsonDoc, err := k8syaml.YAMLToJSON(doc)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse yaml")
+	}
+
+	var obj interface{}
+	if err := json.Unmarshal(jsonDoc, &obj); err != nil {
+		return nil, errors.Wrap(err, "failed to parse yaml")
+	}
+
+	m, _ := obj.(map[string]interface{})
+	return m, nil
+}
+
+func describeValidationError(err error) (string, string) {
+	validationError, ok := err.(validation.ValidationError)
+	if !ok {
+		return "", err.Error()
+	}
+
+	fieldPath := strings.TrimPrefix(validationError.Path, ".")
+	switch e := validationError.Err.(type) {
+	case validation.UnknownFieldError:
+		return joinFieldPath(fieldPath, e.Field), "unknown field"
+	case validation.MissingRequiredFieldError:
+		return joinFieldPath(fieldPath, e.Field), "missing required field"
+	case validation.InvalidTypeError:
+		return fieldPath, fmt.Sprintf("invalid type, got %s, expected %s", e.Actual, e.Expected)
+	case validation.InvalidObjectTypeError:
+		return strings.TrimPrefix(e.Path, "."), fmt.Sprintf("invalid type %s", e.Type)
+	}
+
+	return fieldPath, validationError.Err.Error()
+}
+
+func joinFieldPath(fieldPath string, field string) string {
+	if fieldPath == "" {
+		return field
+	}
+	return fieldPath + "." + field
+}
@@ -0,0 +1,250 @@
+package validate
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/replicatedhq/kots/pkg/base"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testSchemaDir = "testdata/schemas"
+
+func Test_ValidateFiles(t *testing.T) {
+	tests := []struct {
+		name     string
+		files    []base.BaseFile
+		expected []Error
+	}{
+		{
+			name: "valid resources",
+			files: []base.BaseFile{
+				{
+					Path: "app.yaml",
+					Content: []byte(`apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: app
+spec:
+  replicas: 2
+  selector:
+    matchLabels:
+      app: app
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: app
+data:
+  key: value`),
+				},
+			},
+			expected: []Error{},
+		},
+		{
+			name: "unknown field, wrong type and missing field",
+			files: []base.BaseFile{
+				{
+					Path: "deployment.yaml",
+					Content: []byte(`apiVersion: apps/v1
+kind: Deployment
+metadata:
+  nmae: app
+spec:
+  replicas: two`),
+				},
+			},
+			expected: []Error{
+				{Path: "deployment.yaml", DocIndex: 0, FieldPath: "metadata.nmae", Message: "unknown field"},
+				{Path: "deployment.yaml", DocIndex: 0, FieldPath: "spec.replicas", Message: "invalid type, got string, expected integer"},
+				{Path: "deployment.yaml", DocIndex: 0, FieldPath: "spec.selector", Message: "missing required field"},
+			},
+		},
+		{
+			name: "reported against the upstream file and document",
+			files: []base.BaseFile{
+				{
+					Path:             "app-configmap-b.yaml",
+					UpstreamPath:     "app.yaml",
+					UpstreamDocIndex: 1,
+					Content: []byte(`apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: b
+  labels: value`),
+				},
+			},
+			expected: []Error{
+				{Path: "app.yaml", DocIndex: 1, FieldPath: "metadata.labels", Message: "invalid type, got string, expected map"},
+			},
+		},
+		{
+			name: "api version that isn't in the kubernetes version",
+			files: []base.BaseFile{
+				{
+					Path: "deployment.yaml",
+					Content: []byte(`apiVersion: apps/v1beta1
+kind: Deployment
+metadata:
+  name: app`),
+				},
+			},
+			expected: []Error{
+				{Path: "deployment.yaml", DocIndex: 0, FieldPath: "apiVersion", Message: "apps/v1beta1 Deployment is not available in kubernetes v1.16.0"},
+			},
+		},
+		{
+			name: "kinds without a schema and files that aren't yaml",
+			files: []base.BaseFile{
+				{
+					Path: "config.yaml",
+					Content: []byte(`apiVersion: kots.io/v1beta1
+kind: Config
+spec:
+  anything: true`),
+				},
+				{
+					Path:    "README.md",
+					Content: []byte("# app\n"),
+				},
+			},
+			expected: []Error{},
+		},
+		{
+			name: "custom resources are validated against their crd",
+			files: []base.BaseFile{
+				{
+					Path: "crd.yaml",
+					Content: []byte(`apiVersion: apiextensions.k8s.io/v1beta1
+kind: CustomResourceDefinition
+metadata:
+  name: databases.example.com
+spec:
+  group: example.com
+  names:
+    kind: Database
+  versions:
+    - name: v1
+      served: true
+      storage: true
+  validation:
+    openAPIV3Schema:
+      type: object
+      properties:
+        spec:
+          type: object
+          required:
+            - engine
+          properties:
+            engine:
+              type: string
+              enum: [postgres, mysql]
+            size:
+              type: integer
+            options:
+              type: object
+              x-kubernetes-preserve-unknown-fields: true
+              properties:
+                known:
+                  type: string`),
+				},
+				{
+					Path: "databases.yaml",
+					Content: []byte(`apiVersion: example.com/v1
+kind: Database
+metadata:
+  name: valid
+spec:
+  engine: postgres
+  options:
+    unknown: true
+---
+apiVersion: example.com/v1
+kind: Database
+metadata:
+  name: invalid
+spec:
+  size: large
+  replicas: 2`),
+				},
+			},
+			expected: []Error{
+				{Path: "databases.yaml", DocIndex: 1, FieldPath: "spec.engine", Message: "missing required field"},
+				{Path: "databases.yaml", DocIndex: 1, FieldPath: "spec.replicas", Message: "unknown field"},
+				{Path: "databases.yaml", DocIndex: 1, FieldPath: "spec.size", Message: "invalid type, got string, expected integer"},
+			},
+		},
+		{
+			name: "invalid yaml",
+			files: []base.BaseFile{
+				{
+					Path:    "broken.yaml",
+					Content: []byte("apiVersion: v1\nkind: [ConfigMap\n"),
+				},
+			},
+			expected: []Error{
+				{Path: "broken.yaml", DocIndex: 0, Message: "failed to parse yaml: yaml: line 2: did not find expected ',' or ']'"},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := require.New(t)
+
+			actual, err := ValidateFiles(test.files, Options{
+				SchemaDir:         testSchemaDir,
+				KubernetesVersion: "1.16.0",
+			})
+			req.NoError(err)
+
+			assert.Equal(t, test.expected, actual)
+		})
+	}
+}
+
+func Test_findSchemaFile(t *testing.T) {
+	tests := []struct {
+		name              string
+		kubernetesVersion string
+		expected          string
+		expectErr         bool
+	}{
+		{
+			name:              "exact version",
+			kubernetesVersion: "v1.16.0",
+			expected:          filepath.Join(testSchemaDir, "v1.16.0", "swagger.json"),
+		},
+		{
+			name:              "another patch version",
+			kubernetesVersion: "1.16.4",
+			expected:          filepath.Join(testSchemaDir, "v1.16.0", "swagger.json"),
+		},
+		{
+			name:              "only version",
+			kubernetesVersion: "",
+			expected:          filepath.Join(testSchemaDir, "v1.16.0", "swagger.json"),
+		},
+		{
+			name:              "missing minor version",
+			kubernetesVersion: "1.17.0",
+			expectErr:         true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := require.New(t)
+
+			actual, err := findSchemaFile(testSchemaDir, test.kubernetesVersion)
+			if test.expectErr {
+				req.Error(err)
+				return
+			}
+			req.NoError(err)
+
+			assert.Equal(t, test.expected, actual)
+		})
+	}
+}